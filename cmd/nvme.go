@@ -91,8 +91,6 @@ func main() {
 
 	d.IdentPrint(os.Stdout)
 
-	buf := make([]byte, 512)
 	d.PrintSMART(os.Stdout)
-	clear(buf)
-	d.PrintFwSlotInfo()
+	d.PrintFwSlotInfo(os.Stdout)
 }
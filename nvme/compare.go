@@ -0,0 +1,28 @@
+package nvme
+
+// Compare compares length logical blocks starting at lba with buf. A mismatch is reported as a
+// StatusError with the Compare Failure status, which IsCompareFailure detects.
+func (n *NVMeNamespace) Compare(lba uint64, length uint16, buf []byte) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if err := n.checkBuffer(length, buf); err != nil {
+		return err
+	}
+
+	if err := n.dev.checkOptionalCommand(ONCS_COMPARE, NVME_NVM_CMD_COMPARE, "Compare"); err != nil {
+		return err
+	}
+
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_COMPARE,
+		Nsid:   n.Nsid,
+		Data:   buf[:n.transferSize(length)],
+		Cdw10:  uint32(lba),
+		Cdw11:  uint32(lba >> 32),
+		Cdw12:  uint32(length - 1),
+	}
+
+	return n.dev.transport.SubmitIO(&cmd)
+}
@@ -0,0 +1,108 @@
+package nvme_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestCompare(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+
+	data := pattern(4*512, 0x3c)
+	if err := n.Write(16, 4, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Compare(16, 4, data); err != nil {
+		t.Errorf("matching data: %v", err)
+	}
+
+	if err := n.Compare(16, 4, pattern(4*512, 0xc3)); !nvme.IsCompareFailure(err) {
+		t.Errorf("mismatching data: got %v, want Compare Failure", err)
+	}
+
+	// Unwritten blocks compare equal to zeroes
+	if err := n.Compare(32, 1, make([]byte, 512)); err != nil {
+		t.Errorf("unwritten block: %v", err)
+	}
+
+	if err := n.Compare(n.Ident.Nsze, 1, make([]byte, 512)); !nvme.IsLbaOutOfRange(err) {
+		t.Errorf("past the end: got %v, want LBA Out of Range", err)
+	}
+	if err := n.Compare(16, 4, data[:512]); err == nil || nvme.IsCompareFailure(err) {
+		t.Errorf("short buffer: got %v", err)
+	}
+}
+
+func TestWriteZeroes(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+
+	for _, p := range []nvme.WriteZeroesParams{{}, {Deallocate: true}, {FUA: true}} {
+		if err := n.Write(0, 4, pattern(4*512, 1)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := n.WriteZeroes(1, 2, p); err != nil {
+			t.Fatalf("%+v: %v", p, err)
+		}
+
+		buf := make([]byte, 4*512)
+		if err := n.Read(0, 4, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[512:3*512], make([]byte, 2*512)) {
+			t.Errorf("%+v: blocks 1-2 not zeroed", p)
+		}
+		if !bytes.Equal(buf[:512], pattern(4*512, 1)[:512]) || !bytes.Equal(buf[3*512:], pattern(4*512, 1)[3*512:]) {
+			t.Errorf("%+v: neighbouring blocks changed", p)
+		}
+	}
+
+	if err := n.WriteZeroes(0, 0, nvme.WriteZeroesParams{}); err == nil {
+		t.Error("zero length accepted")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+
+	if err := n.Write(0, 8, pattern(8*512, 2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Verify(0, 8, nil); err != nil {
+		t.Error(err)
+	}
+	if err := n.Verify(0, 0, nil); err == nil {
+		t.Error("zero length accepted")
+	}
+	if err := n.Verify(n.Ident.Nsze-1, 2, nil); !nvme.IsLbaOutOfRange(err) {
+		t.Errorf("past the end: got %v, want LBA Out of Range", err)
+	}
+
+	// Protection checks need a namespace formatted with protection information
+	if err := n.Verify(0, 1, &nvme.ProtectionInfo{PRINFO: nvme.PRINFO_CHECK_GUARD}); err == nil {
+		t.Error("protection check accepted without protection information")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	d, tr := newCountingDevice(t, nvmesim.DefaultConfig())
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Flush(nvme.NSID_ALL); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.io[nvme.NVME_NVM_CMD_FLUSH]; c != 2 {
+		t.Errorf("%d Flush commands, want 2", c)
+	}
+}
@@ -14,8 +14,12 @@
 
 package nvme
 
+import (
+	"bytes"
+)
+
 // nvmeIdentController is the low-level struct to decode the response of an NVME_ADMIN_IDENTIFY
-// controller ioctl.
+// controller ioctl (cf. NVM Express Base Specification 2.0c, figure 275).
 type NvmeIdentController struct {
 	VendorID     uint16                  // PCI Vendor ID
 	Ssvid        uint16                  // PCI Subsystem Vendor ID
@@ -31,7 +35,18 @@ type NvmeIdentController struct {
 	Rtd3r        uint32                  // RTD3 Resume Latency
 	Rtd3e        uint32                  // RTD3 Entry Latency
 	Oaes         uint32                  // Optional Asynchronous Events Supported
-	Rsvd96       [160]byte               // ...
+	Ctratt       uint32                  // Controller Attributes
+	Rrls         uint16                  // Read Recovery Levels Supported
+	Rsvd102      [9]byte                 // ...
+	Cntrltype    uint8                   // Controller Type
+	Fguid        [16]byte                // FRU Globally Unique Identifier
+	Crdt1        uint16                  // Command Retry Delay Time 1
+	Crdt2        uint16                  // Command Retry Delay Time 2
+	Crdt3        uint16                  // Command Retry Delay Time 3
+	Rsvd134      [119]byte               // ...
+	Nvmsr        uint8                   // NVM Subsystem Report (NVMe-MI)
+	Vwci         uint8                   // VPD Write Cycle Information (NVMe-MI)
+	Mec          uint8                   // Management Endpoint Capabilities (NVMe-MI)
 	Oacs         uint16                  // Optional Admin Command Support
 	Acl          uint8                   // Abort Command Limit
 	Aerl         uint8                   // Asynchronous Event Request Limit
@@ -46,13 +61,33 @@ type NvmeIdentController struct {
 	Mtfa         uint16                  // Maximum Time for Firmware Activation
 	Hmpre        uint32                  // Host Memory Buffer Preferred Size
 	Hmmin        uint32                  // Host Memory Buffer Minimum Size
-	Tnvmcap      [16]byte                // Total NVM Capacity
-	Unvmcap      [16]byte                // Unallocated NVM Capacity
+	Tnvmcap      Uint128                 // Total NVM Capacity
+	Unvmcap      Uint128                 // Unallocated NVM Capacity
 	Rpmbs        uint32                  // Replay Protected Memory Block Support
-	Rsvd316      [196]byte               // ...
+	Edstt        uint16                  // Extended Device Self-test Time
+	Dsto         uint8                   // Device Self-test Options
+	Fwug         uint8                   // Firmware Update Granularity
+	Kas          uint16                  // Keep Alive Support
+	Hctma        uint16                  // Host Controlled Thermal Management Attributes
+	Mntmt        uint16                  // Minimum Thermal Management Temperature
+	Mxtmt        uint16                  // Maximum Thermal Management Temperature
+	Sanicap      uint32                  // Sanitize Capabilities
+	Hmminds      uint32                  // Host Memory Buffer Minimum Descriptor Entry Size
+	Hmmaxd       uint16                  // Host Memory Maximum Descriptors Entries
+	Nsetidmax    uint16                  // NVM Set Identifier Maximum
+	Endgidmax    uint16                  // Endurance Group Identifier Maximum
+	Anatt        uint8                   // ANA Transition Time
+	Anacap       uint8                   // Asymmetric Namespace Access Capabilities
+	Anagrpmax    uint32                  // ANA Group Identifier Maximum
+	Nanagrpid    uint32                  // Number of ANA Group Identifiers
+	Pels         uint32                  // Persistent Event Log Size
+	DomainID     uint16                  // Domain Identifier
+	Rsvd358      [10]byte                // ...
+	Megcap       Uint128                 // Max Endurance Group Capacity
+	Rsvd384      [128]byte               // ...
 	Sqes         uint8                   // Submission Queue Entry Size
 	Cqes         uint8                   // Completion Queue Entry Size
-	Rsvd514      [2]byte                 // (defined in NVMe 1.3 spec)
+	Maxcmd       uint16                  // Maximum Outstanding Commands
 	Nn           uint32                  // Number of Namespaces
 	Oncs         uint16                  // Optional NVM Command Support
 	Fuses        uint16                  // Fused Operation Support
@@ -61,15 +96,238 @@ type NvmeIdentController struct {
 	Awun         uint16                  // Atomic Write Unit Normal
 	Awupf        uint16                  // Atomic Write Unit Power Fail
 	Nvscc        uint8                   // NVM Vendor Specific Command Configuration
-	Rsvd531      uint8                   // ...
+	Nwpc         uint8                   // Namespace Write Protection Capabilities
 	Acwu         uint16                  // Atomic Compare & Write Unit
-	Rsvd534      [2]byte                 // ...
+	Ocfs         uint16                  // Copy Descriptor Formats Supported
 	Sgls         uint32                  // SGL Support
-	Rsvd540      [1508]byte              // ...
+	Mnan         uint32                  // Maximum Number of Allowed Namespaces
+	Maxdna       Uint128                 // Maximum Domain Namespace Attachments
+	Maxcna       uint32                  // Maximum I/O Controller Namespace Attachments
+	Rsvd564      [204]byte               // ...
+	Subnqn       [256]byte               // NVM Subsystem NVMe Qualified Name
+	Rsvd1024     [768]byte               // ...
+	Ioccsz       uint32                  // I/O Queue Command Capsule Supported Size (NVMe over Fabrics)
+	Iorcsz       uint32                  // I/O Queue Response Capsule Supported Size (NVMe over Fabrics)
+	Icdoff       uint16                  // In Capsule Data Offset (NVMe over Fabrics)
+	Fcatt        uint8                   // Fabrics Controller Attributes (NVMe over Fabrics)
+	Msdbd        uint8                   // Maximum SGL Data Block Descriptors (NVMe over Fabrics)
+	Ofcs         uint16                  // Optional Fabric Commands Support (NVMe over Fabrics)
+	Rsvd1806     [242]byte               // ...
 	Psd          [32]nvmeIdentPowerState // Power State Descriptors
 	Vs           [1024]byte              // Vendor Specific
 } // 4096 bytes
 
+const (
+	// Controller Multi-Path I/O and Namespace Sharing Capabilities
+	CMIC_MULTI_PORT uint8 = 1 << 0
+	CMIC_MULTI_CTRL uint8 = 1 << 1
+	CMIC_SRIOV      uint8 = 1 << 2
+	CMIC_ANA        uint8 = 1 << 3
+
+	// Optional Asynchronous Events Supported
+	OAES_NS_ATTR_NOTICE    uint32 = 1 << 8
+	OAES_FW_ACTIVATION     uint32 = 1 << 9
+	OAES_ANA_CHANGE        uint32 = 1 << 11
+	OAES_PLEA              uint32 = 1 << 12
+	OAES_LBA_STATUS        uint32 = 1 << 13
+	OAES_EG_EVENT          uint32 = 1 << 14
+	OAES_NORMAL_SHUTDOWN   uint32 = 1 << 15
+	OAES_ZONE_DESC_CHANGED uint32 = 1 << 27
+	OAES_DISCOVERY_CHANGE  uint32 = 1 << 31
+
+	// Controller Attributes
+	CTRATT_HOST_ID_128        uint32 = 1 << 0
+	CTRATT_NOPSPM             uint32 = 1 << 1
+	CTRATT_NVM_SETS           uint32 = 1 << 2
+	CTRATT_READ_RECOVERY      uint32 = 1 << 3
+	CTRATT_ENDURANCE_GROUPS   uint32 = 1 << 4
+	CTRATT_PREDICTABLE_LAT    uint32 = 1 << 5
+	CTRATT_TBKAS              uint32 = 1 << 6
+	CTRATT_NS_GRANULARITY     uint32 = 1 << 7
+	CTRATT_SQ_ASSOCIATIONS    uint32 = 1 << 8
+	CTRATT_UUID_LIST          uint32 = 1 << 9
+	CTRATT_MULTI_DOMAIN       uint32 = 1 << 10
+	CTRATT_FIXED_CAP_MGMT     uint32 = 1 << 11
+	CTRATT_VARIABLE_CAP_MGMT  uint32 = 1 << 12
+	CTRATT_DELETE_ENDURANCE_G uint32 = 1 << 13
+	CTRATT_DELETE_NVM_SET     uint32 = 1 << 14
+	CTRATT_EXTENDED_LBA_FMT   uint32 = 1 << 15
+
+	// Controller Type
+	CNTRLTYPE_IO        uint8 = 0x1
+	CNTRLTYPE_DISCOVERY uint8 = 0x2
+	CNTRLTYPE_ADMIN     uint8 = 0x3
+
+	// Optional Admin Command Support
+	OACS_SECURITY     uint16 = 1 << 0
+	OACS_FORMAT_NVM   uint16 = 1 << 1
+	OACS_FIRMWARE     uint16 = 1 << 2
+	OACS_NS_MGMT      uint16 = 1 << 3
+	OACS_SELF_TEST    uint16 = 1 << 4
+	OACS_DIRECTIVES   uint16 = 1 << 5
+	OACS_NVME_MI      uint16 = 1 << 6
+	OACS_VIRT_MGMT    uint16 = 1 << 7
+	OACS_DBBUF_CONFIG uint16 = 1 << 8
+	OACS_LBA_STATUS   uint16 = 1 << 9
+	OACS_LOCKDOWN     uint16 = 1 << 10
+
+	// Firmware Updates
+	FRMW_SLOT1_RO       uint8 = 1 << 0
+	FRMW_NO_RESET       uint8 = 1 << 4
+	FRMW_MULTI_UPDATE_D uint8 = 1 << 5
+
+	// Log Page Attributes
+	LPA_SMART_PER_NS    uint8 = 1 << 0
+	LPA_CMD_EFFECTS     uint8 = 1 << 1
+	LPA_EXTENDED_DATA   uint8 = 1 << 2
+	LPA_TELEMETRY       uint8 = 1 << 3
+	LPA_PERSISTENT_EVT  uint8 = 1 << 4
+	LPA_SUPPORTED_LOGS  uint8 = 1 << 5
+	LPA_TELEMETRY_AREA4 uint8 = 1 << 6
+
+	// Device Self-test Options
+	DSTO_ONE_AT_A_TIME uint8 = 1 << 0
+
+	// Host Controlled Thermal Management Attributes
+	HCTMA_SUPPORTED uint16 = 1 << 0
+
+	// Sanitize Capabilities
+	SANICAP_CRYPTO_ERASE    uint32 = 1 << 0
+	SANICAP_BLOCK_ERASE     uint32 = 1 << 1
+	SANICAP_OVERWRITE       uint32 = 1 << 2
+	SANICAP_NO_DEALLOC_INHB uint32 = 1 << 29
+
+	// Asymmetric Namespace Access Capabilities
+	ANACAP_OPTIMIZED     uint8 = 1 << 0
+	ANACAP_NON_OPTIMIZED uint8 = 1 << 1
+	ANACAP_INACCESSIBLE  uint8 = 1 << 2
+	ANACAP_PERSIST_LOSS  uint8 = 1 << 3
+	ANACAP_CHANGE        uint8 = 1 << 4
+	ANACAP_GRPID_STATIC  uint8 = 1 << 6
+	ANACAP_GRPID_NS_MGMT uint8 = 1 << 7
+
+	// Optional NVM Command Support
+	ONCS_COMPARE      uint16 = 1 << 0
+	ONCS_WRITE_UNCORR uint16 = 1 << 1
+	ONCS_DSM          uint16 = 1 << 2
+	ONCS_WRITE_ZEROES uint16 = 1 << 3
+	ONCS_SAVE_SELECT  uint16 = 1 << 4
+	ONCS_RESERVATIONS uint16 = 1 << 5
+	ONCS_TIMESTAMP    uint16 = 1 << 6
+	ONCS_VERIFY       uint16 = 1 << 7
+	ONCS_COPY         uint16 = 1 << 8
+
+	// Format NVM Attributes
+	FNA_FORMAT_ALL_NS   uint8 = 1 << 0
+	FNA_SEC_ERASE_ALL   uint8 = 1 << 1
+	FNA_CRYPTO_ERASE    uint8 = 1 << 2
+	FNA_NO_BROADCAST_NS uint8 = 1 << 3
+
+	// Volatile Write Cache
+	VWC_PRESENT uint8 = 1 << 0
+
+	// Copy Descriptor Formats Supported
+	OCFS_FORMAT0 uint16 = 1 << 0
+	OCFS_FORMAT1 uint16 = 1 << 1
+	OCFS_FORMAT2 uint16 = 1 << 2
+	OCFS_FORMAT3 uint16 = 1 << 3
+)
+
+func (c *NvmeIdentController) HasCmic(mask uint8) bool {
+	return c.Cmic&mask == mask
+}
+
+func (c *NvmeIdentController) HasOaes(mask uint32) bool {
+	return c.Oaes&mask == mask
+}
+
+func (c *NvmeIdentController) HasCtratt(mask uint32) bool {
+	return c.Ctratt&mask == mask
+}
+
+func (c *NvmeIdentController) HasOacs(mask uint16) bool {
+	return c.Oacs&mask == mask
+}
+
+func (c *NvmeIdentController) HasLpa(mask uint8) bool {
+	return c.Lpa&mask == mask
+}
+
+func (c *NvmeIdentController) HasSanicap(mask uint32) bool {
+	return c.Sanicap&mask == mask
+}
+
+func (c *NvmeIdentController) HasAnacap(mask uint8) bool {
+	return c.Anacap&mask == mask
+}
+
+func (c *NvmeIdentController) HasOncs(mask uint16) bool {
+	return c.Oncs&mask == mask
+}
+
+func (c *NvmeIdentController) HasFna(mask uint8) bool {
+	return c.Fna&mask == mask
+}
+
+func (c *NvmeIdentController) HasOcfs(mask uint16) bool {
+	return c.Ocfs&mask == mask
+}
+
+// FirmwareSlots returns the number of firmware slots supported (FRMW bits 3:1).
+func (c *NvmeIdentController) FirmwareSlots() uint8 {
+	return uint8(getBitsValue(uint64(c.Frmw), 1, 3))
+}
+
+func (c *NvmeIdentController) FirmwareSlot1ReadOnly() bool {
+	return c.Frmw&FRMW_SLOT1_RO != 0
+}
+
+// VolatileWriteCachePresent reports whether a volatile write cache is present (VWC bit 0).
+func (c *NvmeIdentController) VolatileWriteCachePresent() bool {
+	return c.Vwc&VWC_PRESENT != 0
+}
+
+// FlushAllSupported reports whether Flush accepts the broadcast NSID FFFFFFFFh (VWC bits 2:1).
+func (c *NvmeIdentController) FlushAllSupported() bool {
+	return getBitsValue(uint64(c.Vwc), 1, 2) == 0x3
+}
+
+// SanitizeNoDeallocModifiesMedia returns the NODMMAS field (SANICAP bits 31:30): 1 if media is
+// not additionally modified after a sanitize with No-Deallocate, 2 if it is, 0 if not reported.
+func (c *NvmeIdentController) SanitizeNoDeallocModifiesMedia() uint8 {
+	return uint8(getBitsValue(uint64(c.Sanicap), 30, 31))
+}
+
+// SqesMin returns the required submission queue entry size as a power of two.
+func (c *NvmeIdentController) SqesMin() uint8 {
+	return uint8(getBitsValue(uint64(c.Sqes), 0, 3))
+}
+
+// SqesMax returns the maximum submission queue entry size as a power of two.
+func (c *NvmeIdentController) SqesMax() uint8 {
+	return uint8(getBitsValue(uint64(c.Sqes), 4, 7))
+}
+
+// CqesMin returns the required completion queue entry size as a power of two.
+func (c *NvmeIdentController) CqesMin() uint8 {
+	return uint8(getBitsValue(uint64(c.Cqes), 0, 3))
+}
+
+// CqesMax returns the maximum completion queue entry size as a power of two.
+func (c *NvmeIdentController) CqesMax() uint8 {
+	return uint8(getBitsValue(uint64(c.Cqes), 4, 7))
+}
+
+// Version returns the major, minor and tertiary version numbers from VER.
+func (c *NvmeIdentController) Version() (major, minor, tertiary uint8) {
+	return uint8(c.Ver >> 16), uint8(c.Ver >> 8), uint8(c.Ver)
+}
+
+// SubsystemNQN returns SUBNQN with its NUL padding removed.
+func (c *NvmeIdentController) SubsystemNQN() string {
+	return string(bytes.TrimRight(c.Subnqn[:], "\x00"))
+}
+
 type nvmeIdentPowerState struct {
 	MaxPower        uint16 // Centiwatts
 	Rsvd2           uint8
@@ -0,0 +1,265 @@
+package nvme
+
+import (
+	"fmt"
+)
+
+const (
+	// Source Range Entries descriptor formats of Copy
+	COPY_DESC_FORMAT0 uint8 = 0x0 // 32 bytes, 16-bit guard protection information
+	COPY_DESC_FORMAT1 uint8 = 0x1 // 40 bytes, 32b/64b guard protection information
+	COPY_DESC_FORMAT2 uint8 = 0x2 // Format 0 with a source namespace
+	COPY_DESC_FORMAT3 uint8 = 0x3 // Format 1 with a source namespace
+
+	// COPY_MAX_RANGE_LENGTH is the largest number of logical blocks a single source range can hold
+	COPY_MAX_RANGE_LENGTH = 1 << 16
+)
+
+var CopyCdw12BitInfo = cdwBitInfo{
+	{
+		name: "NR", bitStart: 0,
+	},
+	{
+		name: "DESFMT", bitStart: 8,
+	},
+	{
+		name: "PRINFOR", bitStart: 12,
+	},
+	{
+		name: "PRINFOW", bitStart: 26,
+	},
+	{
+		name: "FUA", bitStart: 30,
+	},
+	{
+		name: "LR", bitStart: 31,
+	},
+}
+
+type CopyCdw12 struct {
+	NR      uint32
+	DESFMT  uint32
+	PRINFOR uint32
+	PRINFOW uint32
+	FUA     uint32
+	LR      uint32
+}
+
+// CopySourceRange is a range of logical blocks to be copied.
+type CopySourceRange struct {
+	Nsid       uint32 // Source namespace for formats 2 and 3, 0 for the destination namespace
+	Lba        uint64
+	Length     uint32 // In logical blocks
+	RefTag     uint64 // Expected Initial Logical Block Reference Tag, 32 bits for formats 0 and 2
+	AppTag     uint16 // Expected Logical Block Application Tag
+	AppTagMask uint16
+}
+
+// CopyParams selects the descriptor format and the options of Copy.
+type CopyParams struct {
+	Format       uint8           // Descriptor format, COPY_DESC_FORMAT*
+	PRINFOR      uint8           // PRINFO_* bits applied when reading the source ranges
+	Write        *ProtectionInfo // Protection information of the destination, nil for none
+	FUA          bool
+	LimitedRetry bool
+}
+
+// copyDescSize returns the size of a source range descriptor of format f.
+func copyDescSize(f uint8) int {
+	if f == COPY_DESC_FORMAT1 || f == COPY_DESC_FORMAT3 {
+		return 40
+	}
+
+	return 32
+}
+
+// encodeCopyRange encodes r as a descriptor of format f into b. Formats 2 and 3 name the source
+// namespace, which is the destination namespace nsid if r does not set one.
+func encodeCopyRange(f uint8, nsid uint32, r *CopySourceRange, b []byte) {
+	if f == COPY_DESC_FORMAT2 || f == COPY_DESC_FORMAT3 {
+		if r.Nsid != 0 {
+			nsid = r.Nsid
+		}
+		NativeEndian.PutUint32(b[0:], nsid)
+	}
+	NativeEndian.PutUint64(b[8:], r.Lba)
+	NativeEndian.PutUint16(b[16:], uint16(r.Length-1))
+
+	switch f {
+	case COPY_DESC_FORMAT0:
+		NativeEndian.PutUint32(b[20:], uint32(r.RefTag))
+		NativeEndian.PutUint16(b[24:], r.AppTag)
+		NativeEndian.PutUint16(b[26:], r.AppTagMask)
+	case COPY_DESC_FORMAT1:
+		NativeEndian.PutUint64(b[22:], r.RefTag)
+		NativeEndian.PutUint16(b[32:], r.AppTag)
+		NativeEndian.PutUint16(b[34:], r.AppTagMask)
+	case COPY_DESC_FORMAT2:
+		NativeEndian.PutUint32(b[24:], uint32(r.RefTag))
+		NativeEndian.PutUint16(b[28:], r.AppTag)
+		NativeEndian.PutUint16(b[30:], r.AppTagMask)
+	case COPY_DESC_FORMAT3:
+		NativeEndian.PutUint64(b[24:], r.RefTag)
+		NativeEndian.PutUint16(b[34:], r.AppTag)
+		NativeEndian.PutUint16(b[36:], r.AppTagMask)
+	}
+}
+
+// validateCopy checks the descriptor format against OCFS and the ranges against the namespace.
+func (n *NVMeNamespace) validateCopy(dest uint64, ranges []CopySourceRange, p *CopyParams) error {
+	if len(ranges) == 0 {
+		return fmt.Errorf("no copy source ranges")
+	}
+
+	if p.Format > COPY_DESC_FORMAT3 {
+		return fmt.Errorf("invalid copy descriptor format %d", p.Format)
+	}
+
+	if err := n.checkProtection(&ProtectionInfo{PRINFO: p.PRINFOR}); err != nil {
+		return err
+	}
+
+	if err := n.checkProtection(p.Write); err != nil {
+		return err
+	}
+
+	idCtrl, err := n.dev.cachedIdentifyController()
+	if err != nil {
+		return err
+	}
+
+	if !idCtrl.HasOncs(ONCS_COPY) || !idCtrl.HasOcfs(1<<p.Format) {
+		return fmt.Errorf("%w: copy descriptor format %d", ErrNotSupported, p.Format)
+	}
+
+	if err := n.dev.checkIOCommand(NVME_NVM_CMD_COPY, "Copy"); err != nil {
+		return err
+	}
+
+	var total uint64
+	for _, r := range ranges {
+		if r.Length == 0 {
+			return fmt.Errorf("empty copy source range at LBA %d", r.Lba)
+		}
+
+		if r.Nsid != 0 && r.Nsid != n.Nsid && p.Format != COPY_DESC_FORMAT2 && p.Format != COPY_DESC_FORMAT3 {
+			return fmt.Errorf("copy from namespace %d requires descriptor format 2 or 3", r.Nsid)
+		}
+
+		// Ranges of other namespaces are checked by the controller
+		if (r.Nsid == 0 || r.Nsid == n.Nsid) && (r.Lba+uint64(r.Length) > n.Ident.Nsze || r.Lba+uint64(r.Length) < r.Lba) {
+			return fmt.Errorf("source range %d+%d exceeds namespace size %d", r.Lba, r.Length, n.Ident.Nsze)
+		}
+
+		total += uint64(r.Length)
+	}
+
+	if dest+total > n.Ident.Nsze || dest+total < dest {
+		return fmt.Errorf("destination range %d+%d exceeds namespace size %d", dest, total, n.Ident.Nsze)
+	}
+
+	return nil
+}
+
+// splitCopy splits ranges into source range lists that respect MSSRL, MSRC and MCL. A limit of
+// 0 is treated as not reported.
+func (n *NVMeNamespace) splitCopy(ranges []CopySourceRange) [][]CopySourceRange {
+	maxLen := uint32(COPY_MAX_RANGE_LENGTH)
+	if n.Ident.Mssrl != 0 {
+		maxLen = uint32(n.Ident.Mssrl)
+	}
+	if n.Ident.Mcl != 0 && n.Ident.Mcl < maxLen {
+		maxLen = n.Ident.Mcl
+	}
+
+	maxRanges := int(n.Ident.Msrc) + 1
+
+	var cmds [][]CopySourceRange
+	var cur []CopySourceRange
+	var curLen uint32
+
+	for _, r := range ranges {
+		for r.Length > 0 {
+			piece := r
+			piece.Length = min(r.Length, maxLen)
+			if n.Ident.Mcl != 0 && curLen+piece.Length > n.Ident.Mcl || len(cur) == maxRanges {
+				cmds = append(cmds, cur)
+				cur, curLen = nil, 0
+			}
+
+			cur = append(cur, piece)
+			curLen += piece.Length
+
+			r.Lba += uint64(piece.Length)
+			r.RefTag += uint64(piece.Length)
+			r.Length -= piece.Length
+		}
+	}
+
+	return append(cmds, cur)
+}
+
+// Copy copies the logical blocks of ranges, in order, to consecutive logical blocks of the
+// namespace starting at dest. Requests exceeding the namespace's copy limits are split into
+// several commands, which are not atomic as a whole; the destination reference tag advances
+// with the blocks copied by previous commands.
+func (n *NVMeNamespace) Copy(dest uint64, ranges []CopySourceRange, p CopyParams) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if err := n.validateCopy(dest, ranges, &p); err != nil {
+		return err
+	}
+
+	w := ProtectionInfo{}
+	if p.Write != nil {
+		w = *p.Write
+	}
+
+	var fua, lr uint32
+	if p.FUA {
+		fua = 1
+	}
+	if p.LimitedRetry {
+		lr = 1
+	}
+
+	size := copyDescSize(p.Format)
+
+	for _, list := range n.splitCopy(ranges) {
+		buf := make([]byte, len(list)*size)
+		var blocks uint64
+		for i := range list {
+			encodeCopyRange(p.Format, n.Nsid, &list[i], buf[i*size:])
+			blocks += uint64(list[i].Length)
+		}
+
+		cmd := Command{
+			Opcode: NVME_NVM_CMD_COPY,
+			Nsid:   n.Nsid,
+			Data:   buf,
+			Cdw10:  uint32(dest),
+			Cdw11:  uint32(dest >> 32),
+			Cdw12: buildCdw(CopyCdw12BitInfo, CopyCdw12{
+				NR:      uint32(len(list) - 1),
+				DESFMT:  uint32(p.Format),
+				PRINFOR: uint32(p.PRINFOR),
+				PRINFOW: uint32(w.PRINFO),
+				FUA:     fua,
+				LR:      lr,
+			}),
+			Cdw14: w.RefTag,
+			Cdw15: uint32(w.AppTag) | uint32(w.AppTagMask)<<16,
+		}
+
+		if err := n.dev.transport.SubmitIO(&cmd); err != nil {
+			return err
+		}
+
+		dest += blocks
+		w.RefTag += uint32(blocks)
+	}
+
+	return nil
+}
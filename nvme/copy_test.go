@@ -0,0 +1,129 @@
+package nvme_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+// copyConfig returns a controller with two namespaces that supports Copy descriptor formats 0
+// and 2, with at most 2 source ranges of 8 blocks and 16 blocks per command.
+func copyConfig() nvmesim.Config {
+	cfg := nvmesim.DefaultConfig()
+	cfg.CopyFormats = 1<<nvme.COPY_DESC_FORMAT0 | 1<<nvme.COPY_DESC_FORMAT2
+	cfg.CopyMssrl = 8
+	cfg.CopyMcl = 16
+	cfg.CopyMsrc = 1
+	cfg.Namespaces = append(cfg.Namespaces, nvmesim.NamespaceConfig{
+		Nsid:       2,
+		Size:       1 << 16,
+		LbaFormats: []nvme.LbaFormat{{Lbads: 9}},
+	})
+
+	return cfg
+}
+
+func TestCopy(t *testing.T) {
+	d, tr := newCountingDevice(t, copyConfig())
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := pattern(32*512, 7)
+	if err := n.Write(0, 32, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 + 5 blocks split into two commands: 8+8, then 4+5
+	ranges := []nvme.CopySourceRange{{Lba: 0, Length: 20}, {Lba: 27, Length: 5}}
+	if err := n.Copy(100, ranges, nvme.CopyParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.io[nvme.NVME_NVM_CMD_COPY]; c != 2 {
+		t.Errorf("%d Copy commands, want 2", c)
+	}
+
+	buf := make([]byte, 25*512)
+	if err := n.Read(100, 25, buf); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append([]byte(nil), data[:20*512]...), data[27*512:32*512]...)
+	if !bytes.Equal(buf, want) {
+		t.Error("copied data does not match the source ranges")
+	}
+}
+
+func TestCopyFromNamespace(t *testing.T) {
+	d := newSimDevice(t, copyConfig())
+	n1, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n2, err := d.Namespace(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := pattern(4*512, 9)
+	if err := n2.Write(10, 4, data); err != nil {
+		t.Fatal(err)
+	}
+
+	src := []nvme.CopySourceRange{{Nsid: 2, Lba: 10, Length: 4}}
+	if err := n1.Copy(0, src, nvme.CopyParams{}); err == nil {
+		t.Error("copy from another namespace accepted with descriptor format 0")
+	}
+
+	if err := n1.Copy(0, src, nvme.CopyParams{Format: nvme.COPY_DESC_FORMAT2}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 4*512)
+	if err := n1.Read(0, 4, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Error("copied data does not match namespace 2")
+	}
+
+	// A range without a namespace is read from the destination namespace
+	if err := n1.Copy(100, []nvme.CopySourceRange{{Lba: 0, Length: 4}}, nvme.CopyParams{Format: nvme.COPY_DESC_FORMAT2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n1.Read(100, 4, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Error("copied data does not match the destination namespace source range")
+	}
+
+	src[0].Nsid = 3
+	if err := n1.Copy(0, src, nvme.CopyParams{Format: nvme.COPY_DESC_FORMAT2}); !nvme.IsInvalidNamespace(err) {
+		t.Errorf("copy from a missing namespace: got %v, want Invalid Namespace", err)
+	}
+}
+
+func TestCopyInvalid(t *testing.T) {
+	n := newSimNamespace(t, copyConfig())
+
+	if err := n.Copy(0, []nvme.CopySourceRange{{Length: 1}}, nvme.CopyParams{Format: nvme.COPY_DESC_FORMAT1}); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("format 1: got %v, want ErrNotSupported", err)
+	}
+	if err := n.Copy(0, nil, nvme.CopyParams{}); err == nil {
+		t.Error("no ranges accepted")
+	}
+	if err := n.Copy(0, []nvme.CopySourceRange{{Lba: 1}}, nvme.CopyParams{}); err == nil {
+		t.Error("empty range accepted")
+	}
+	if err := n.Copy(n.Ident.Nsze-1, []nvme.CopySourceRange{{Length: 2}}, nvme.CopyParams{}); err == nil {
+		t.Error("destination past the end of the namespace accepted")
+	}
+
+	if err := newSimNamespace(t, nvmesim.DefaultConfig()).Copy(0, []nvme.CopySourceRange{{Length: 1}}, nvme.CopyParams{}); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("no Copy support: got %v, want ErrNotSupported", err)
+	}
+}
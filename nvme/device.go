@@ -15,22 +15,31 @@
 package nvme
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"unsafe"
 
 	"github.com/AaronFei/go-nvme/ioctl"
-
-	"golang.org/x/sys/unix"
 )
 
 var (
 	// Defined in <linux/nvme_ioctl.h>
+	NVME_IOCTL_ID        = ioctl.Io('N', 0x40)
 	NVME_IOCTL_ADMIN_CMD = ioctl.Iowr('N', 0x41, unsafe.Sizeof(nvmeAdminCmd{}))
 	NVME_IOCTL_SUBMIT_IO = ioctl.Iow('N', 0x42, unsafe.Sizeof(nvmeUserIo{}))
 	NVME_IOCTL_IO_CMD    = ioctl.Iowr('N', 0x43, unsafe.Sizeof(nvmePassthruCommand{}))
+	NVME_IOCTL_RESCAN    = ioctl.Io('N', 0x46)
 )
 
+// ErrNotSupported is returned, wrapped, when the controller does not advertise support for an
+// optional command or feature that was requested.
+var ErrNotSupported = errors.New("not supported by controller")
+
+// ErrRescanFailed is returned, wrapped together with the cause, when a command that changes the
+// namespaces completed successfully but the host could not be made to rescan them.
+var ErrRescanFailed = errors.New("namespace rescan failed")
+
 // NVMeController encapsulates the attributes of an NVMe controller.
 type NvmeController struct {
 	VendorID        uint16
@@ -41,23 +50,58 @@ type NvmeController struct {
 	MaxDataXferSize uint
 }
 
+// NVMeDevice is an NVMe controller. It caches controller information between commands and is not
+// safe for concurrent use: a device and its namespace handles must not be used by several
+// goroutines at once.
 type NVMeDevice struct {
 	Name      string
-	fd        int
 	ModelInfo NvmeController
+	transport Transport
+
+	// Cached Supported Log Pages and Commands Supported and Effects logs, cf. loadSupportInfo
+	supportLoaded bool
+	supportedLogs *LogPageSupportedLogs
+	cmdEffects    *LogPageCmdEffects
+
+	// Cached Identify Controller data and its format generation, cf. cachedIdentifyController
+	idCtrl    *NvmeIdentController
+	idCtrlGen uint64
+
+	// Incremented by every Format NVM, so that namespace handles can detect stale Identify data
+	formatGen uint64
 }
 
+// NewNVMeDevice returns a device that talks to the Linux NVMe driver through the device node name.
 func NewNVMeDevice(name string) *NVMeDevice {
-	return &NVMeDevice{Name: name}
+	return &NVMeDevice{Name: name, transport: NewIoctlTransport(name)}
 }
 
-func (d *NVMeDevice) Open() (err error) {
-	d.fd, err = unix.Open(d.Name, unix.O_RDWR, 0600)
-	return err
+// NewNVMeDeviceWithTransport returns a device that dispatches all commands through t.
+func NewNVMeDeviceWithTransport(name string, t Transport) *NVMeDevice {
+	return &NVMeDevice{Name: name, transport: t}
+}
+
+func (d *NVMeDevice) Open() error {
+	return d.transport.Open()
 }
 
 func (d *NVMeDevice) Close() error {
-	return unix.Close(d.fd)
+	return d.transport.Close()
+}
+
+// Transport returns the transport the device dispatches commands through.
+func (d *NVMeDevice) Transport() Transport {
+	return d.transport
+}
+
+// Rescan asks the host to rescan the controller's namespaces. It is a no-op for transports that do
+// not implement Rescanner.
+func (d *NVMeDevice) Rescan() error {
+	if r, ok := d.transport.(Rescanner); ok {
+		return r.Rescan()
+	}
+
+	return nil
 }
 
 // Print outputs the attributes of an NVMe controller in a pretty-print style.
@@ -0,0 +1,129 @@
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// DSM_MAX_RANGES is the number of ranges a single Dataset Management command can carry
+	DSM_MAX_RANGES = 256
+
+	// Attributes of Dataset Management (CDW11)
+	DSM_ATTR_IDR uint32 = 1 << 0 // Integral Dataset for Read
+	DSM_ATTR_IDW uint32 = 1 << 1 // Integral Dataset for Write
+	DSM_ATTR_AD  uint32 = 1 << 2 // Deallocate
+
+	// Access Frequency of the range Context Attributes
+	DSM_CA_AF_NONE                     uint32 = 0x0
+	DSM_CA_AF_TYPICAL                  uint32 = 0x1
+	DSM_CA_AF_INFREQ_WRITE_INFREQ_READ uint32 = 0x2
+	DSM_CA_AF_INFREQ_WRITE_FREQ_READ   uint32 = 0x3
+	DSM_CA_AF_FREQ_WRITE_INFREQ_READ   uint32 = 0x4
+	DSM_CA_AF_FREQ_WRITE_FREQ_READ     uint32 = 0x5
+	DSM_CA_AF_ONE_TIME_READ            uint32 = 0x6
+	DSM_CA_AF_SPECULATIVE_READ         uint32 = 0x7
+	DSM_CA_AF_OVERWRITTEN              uint32 = 0x8
+
+	// Access Latency of the range Context Attributes
+	DSM_CA_AL_NONE   uint32 = 0x0 << 4
+	DSM_CA_AL_IDLE   uint32 = 0x1 << 4
+	DSM_CA_AL_NORMAL uint32 = 0x2 << 4
+	DSM_CA_AL_LOW    uint32 = 0x3 << 4
+
+	DSM_CA_SEQ_READ      uint32 = 1 << 6 // Part of a sequential read range
+	DSM_CA_SEQ_WRITE     uint32 = 1 << 7 // Part of a sequential write range
+	DSM_CA_WRITE_PREPARE uint32 = 1 << 8 // Will be written in the near future
+
+	// DSM_CA_ACCESS_SIZE_SHIFT positions the Command Access Size, the number of logical blocks
+	// expected to be transferred by a single read or write, in bits 31:24
+	DSM_CA_ACCESS_SIZE_SHIFT = 24
+)
+
+var DsmCdw11BitInfo = cdwBitInfo{
+	{
+		name: "IDR", bitStart: 0,
+	},
+	{
+		name: "IDW", bitStart: 1,
+	},
+	{
+		name: "AD", bitStart: 2,
+	},
+}
+
+type DsmCdw11 struct {
+	IDR uint32
+	IDW uint32
+	AD  uint32
+}
+
+// DsmRange is a Dataset Management range definition.
+type DsmRange struct {
+	ContextAttributes uint32 // DSM_CA_* hints, 0 if none
+	Length            uint32 // In logical blocks
+	Lba               uint64 // Starting LBA
+} // 16 bytes
+
+// DatasetManagement applies the DSM_ATTR_* attributes and the per-range context attributes to
+// ranges. Range lists longer than DSM_MAX_RANGES are split over several commands, which are not
+// atomic as a whole.
+func (n *NVMeNamespace) DatasetManagement(ranges []DsmRange, attrs uint32) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if len(ranges) == 0 {
+		return fmt.Errorf("no dataset management ranges")
+	}
+
+	if attrs&^(DSM_ATTR_IDR|DSM_ATTR_IDW|DSM_ATTR_AD) != 0 {
+		return fmt.Errorf("invalid dataset management attributes %#x", attrs)
+	}
+
+	for _, r := range ranges {
+		if r.Lba+uint64(r.Length) > n.Ident.Nsze || r.Lba+uint64(r.Length) < r.Lba {
+			return fmt.Errorf("range %d+%d exceeds namespace size %d", r.Lba, r.Length, n.Ident.Nsze)
+		}
+	}
+
+	if err := n.dev.checkOptionalCommand(ONCS_DSM, NVME_NVM_CMD_DATASET_MANAGEMENT, "Dataset Management"); err != nil {
+		return err
+	}
+
+	for len(ranges) > 0 {
+		batch := ranges[:min(len(ranges), DSM_MAX_RANGES)]
+		ranges = ranges[len(batch):]
+
+		if err := n.dev.datasetManagement(n.Nsid, batch, attrs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deallocate deallocates (trims) ranges, so that they no longer hold data.
+func (n *NVMeNamespace) Deallocate(ranges []DsmRange) error {
+	return n.DatasetManagement(ranges, DSM_ATTR_AD)
+}
+
+func (d *NVMeDevice) datasetManagement(nsid uint32, ranges []DsmRange, attrs uint32) error {
+	var buf bytes.Buffer
+	binary.Write(&buf, NativeEndian, ranges)
+
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_DATASET_MANAGEMENT,
+		Nsid:   nsid,
+		Data:   buf.Bytes(),
+		Cdw10:  uint32(len(ranges) - 1),
+		Cdw11: buildCdw(DsmCdw11BitInfo, DsmCdw11{
+			IDR: attrs & DSM_ATTR_IDR,
+			IDW: (attrs & DSM_ATTR_IDW) >> 1,
+			AD:  (attrs & DSM_ATTR_AD) >> 2,
+		}),
+	}
+
+	return d.transport.SubmitIO(&cmd)
+}
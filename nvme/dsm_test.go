@@ -0,0 +1,129 @@
+package nvme_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestDeallocate(t *testing.T) {
+	d, tr := newCountingDevice(t, nvmesim.DefaultConfig())
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := pattern(8*512, 0x5a)
+	if err := n.Write(0, 8, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// 300 single-block ranges take two commands; only the odd blocks below 8 are written
+	ranges := make([]nvme.DsmRange, 300)
+	for i := range ranges {
+		ranges[i] = nvme.DsmRange{Lba: uint64(2*i + 1), Length: 1}
+	}
+	if err := n.Deallocate(ranges); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.io[nvme.NVME_NVM_CMD_DATASET_MANAGEMENT]; c != 2 {
+		t.Errorf("%d Dataset Management commands, want 2", c)
+	}
+
+	buf := make([]byte, 8*512)
+	if err := n.Read(0, 8, buf); err != nil {
+		t.Fatal(err)
+	}
+	for lba := 0; lba < 8; lba++ {
+		want := data[lba*512 : (lba+1)*512]
+		if lba%2 == 1 {
+			want = make([]byte, 512)
+		}
+		if !bytes.Equal(buf[lba*512:(lba+1)*512], want) {
+			t.Errorf("block %d holds unexpected data after deallocation", lba)
+		}
+	}
+}
+
+func TestDatasetManagementHints(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+
+	data := pattern(512, 1)
+	if err := n.Write(0, 1, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hints without Deallocate leave the data in place
+	r := []nvme.DsmRange{{ContextAttributes: nvme.DSM_CA_AF_ONE_TIME_READ | nvme.DSM_CA_SEQ_READ, Length: 1}}
+	if err := n.DatasetManagement(r, nvme.DSM_ATTR_IDR); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 512)
+	if err := n.Read(0, 1, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Error("data changed by a hint-only Dataset Management command")
+	}
+}
+
+func TestDatasetManagementInvalid(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+
+	if err := n.Deallocate(nil); err == nil {
+		t.Error("no ranges accepted")
+	}
+	if err := n.DatasetManagement([]nvme.DsmRange{{Length: 1}}, 1<<3); err == nil {
+		t.Error("invalid attribute accepted")
+	}
+	if err := n.Deallocate([]nvme.DsmRange{{Lba: n.Ident.Nsze - 1, Length: 2}}); err == nil {
+		t.Error("range past the end of the namespace accepted")
+	}
+}
+
+func TestIOChecksIdentifyOnce(t *testing.T) {
+	d, tr := newCountingDevice(t, nvmesim.DefaultConfig())
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ranges := []nvme.DsmRange{{Lba: 0, Length: 8}}
+	if err := n.Deallocate(ranges); err != nil {
+		t.Fatal(err)
+	}
+
+	// Later checks use the Identify Controller data of the first
+	tr.admin[nvme.NVME_ADMIN_IDENTIFY] = 0
+	for i := 0; i < 3; i++ {
+		if err := n.Deallocate(ranges); err != nil {
+			t.Fatal(err)
+		}
+		if err := n.WriteZeroes(0, 8, nvme.WriteZeroesParams{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := n.Verify(0, 8, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c := tr.admin[nvme.NVME_ADMIN_IDENTIFY]; c != 0 {
+		t.Errorf("%d Identify commands, want none", c)
+	}
+
+	// After a format the checks read Identify Controller again
+	if err := n.Format(nvme.FormatParams{FormatIndex: 1}); err != nil {
+		t.Fatal(err)
+	}
+	tr.admin[nvme.NVME_ADMIN_IDENTIFY] = 0
+	for i := 0; i < 3; i++ {
+		if err := n.Deallocate(ranges); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c := tr.admin[nvme.NVME_ADMIN_IDENTIFY]; c != 1 {
+		t.Errorf("%d Identify commands after a format, want 1", c)
+	}
+}
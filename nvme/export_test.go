@@ -0,0 +1,36 @@
+package nvme
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var CompletionError = completionError
+
+// NewKernelNamespace returns a handle to namespace nsid of the kernel device node path, without
+// opening or identifying it.
+func NewKernelNamespace(path string, nsid uint32) *NVMeNamespace {
+	return &NVMeNamespace{Nsid: nsid, dev: NewNVMeDevice(path)}
+}
+
+// SetHostRoot makes the in-use checks read sysfs and the mount and swap tables below root for
+// the rest of the test. Device paths resolve through links; paths missing from it do not exist.
+func SetHostRoot(t *testing.T, root string, links map[string]string) {
+	files, block, class, subsys, eval := inUseFiles, sysBlock, sysClassNvme, sysClassNvmeSubsys, evalSymlinks
+	t.Cleanup(func() {
+		inUseFiles, sysBlock, sysClassNvme, sysClassNvmeSubsys, evalSymlinks = files, block, class, subsys, eval
+	})
+
+	inUseFiles = []string{filepath.Join(root, "proc/mounts"), filepath.Join(root, "proc/swaps")}
+	sysBlock = filepath.Join(root, "sys/block")
+	sysClassNvme = filepath.Join(root, "sys/class/nvme")
+	sysClassNvmeSubsys = filepath.Join(root, "sys/class/nvme-subsystem")
+	evalSymlinks = func(path string) (string, error) {
+		if resolved, ok := links[path]; ok {
+			return resolved, nil
+		}
+
+		return "", &os.PathError{Op: "lstat", Path: path, Err: os.ErrNotExist}
+	}
+}
@@ -0,0 +1,145 @@
+package nvme
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 317: Feature Identifiers
+	FEATURE_ARBITRATION       uint8 = 0x01
+	FEATURE_POWER_MGMT        uint8 = 0x02
+	FEATURE_LBA_RANGE         uint8 = 0x03
+	FEATURE_TEMP_THRESHOLD    uint8 = 0x04
+	FEATURE_ERROR_RECOVERY    uint8 = 0x05
+	FEATURE_VOLATILE_WC       uint8 = 0x06
+	FEATURE_NUM_QUEUES        uint8 = 0x07
+	FEATURE_IRQ_COALESCE      uint8 = 0x08
+	FEATURE_IRQ_CONFIG        uint8 = 0x09
+	FEATURE_WRITE_ATOMIC      uint8 = 0x0a
+	FEATURE_ASYNC_EVENT       uint8 = 0x0b
+	FEATURE_AUTO_PST          uint8 = 0x0c
+	FEATURE_HOST_MEM_BUF      uint8 = 0x0d
+	FEATURE_TIMESTAMP         uint8 = 0x0e
+	FEATURE_KATO              uint8 = 0x0f
+	FEATURE_HCTM              uint8 = 0x10
+	FEATURE_NOPSC             uint8 = 0x11
+	FEATURE_RRL               uint8 = 0x12
+	FEATURE_PLM_CONFIG        uint8 = 0x13
+	FEATURE_PLM_WINDOW        uint8 = 0x14
+	FEATURE_LBA_STS_INTERVAL  uint8 = 0x15
+	FEATURE_HOST_BEHAVIOR     uint8 = 0x16
+	FEATURE_SANITIZE          uint8 = 0x17
+	FEATURE_ENDURANCE_EVT_CFG uint8 = 0x18
+	FEATURE_IOCS_PROFILE      uint8 = 0x19
+	FEATURE_SPINUP_CONTROL    uint8 = 0x1a
+	FEATURE_ENH_CTRL_METADATA uint8 = 0x7d
+	FEATURE_CTRL_METADATA     uint8 = 0x7e
+	FEATURE_NS_METADATA       uint8 = 0x7f
+	FEATURE_SW_PROGRESS       uint8 = 0x80
+	FEATURE_HOST_ID           uint8 = 0x81
+	FEATURE_RESV_MASK         uint8 = 0x82
+	FEATURE_RESV_PERSIST      uint8 = 0x83
+	FEATURE_NS_WRITE_PROTECT  uint8 = 0x84
+)
+
+const (
+	// Select field of Get Features, cf. figure 315
+	FEATURE_SEL_CURRENT   uint8 = 0x0
+	FEATURE_SEL_DEFAULT   uint8 = 0x1
+	FEATURE_SEL_SAVED     uint8 = 0x2
+	FEATURE_SEL_SUPPORTED uint8 = 0x3
+)
+
+var GetFeaturesCdw10BitInfo = cdwBitInfo{
+	{
+		name: "FID", bitStart: 0,
+	},
+	{
+		name: "SEL", bitStart: 8,
+	},
+}
+
+type GetFeaturesCdw10 struct {
+	FID uint32
+	SEL uint32
+}
+
+var SetFeaturesCdw10BitInfo = cdwBitInfo{
+	{
+		name: "FID", bitStart: 0,
+	},
+	{
+		name: "SV", bitStart: 31,
+	},
+}
+
+type SetFeaturesCdw10 struct {
+	FID uint32
+	SV  uint32
+}
+
+// FeatureCapabilities is the completion dword returned by Get Features with SEL set to supported
+// capabilities.
+type FeatureCapabilities struct {
+	Saveable          bool
+	NamespaceSpecific bool
+	Changeable        bool
+}
+
+// GetFeature issues Get Features for fid and returns completion dword 0. cdw11 carries the
+// feature specific selector, if any, and buf receives the data structure of features that have
+// one (nil otherwise).
+func (d *NVMeDevice) GetFeature(fid uint8, sel uint8, nsid uint32, cdw11 uint32, buf []byte) (uint32, error) {
+	cmd := Command{
+		Opcode: NVME_ADMIN_GET_FEATURES,
+		Nsid:   nsid,
+		Data:   buf,
+		Cdw10: buildCdw(GetFeaturesCdw10BitInfo, GetFeaturesCdw10{
+			FID: uint32(fid),
+			SEL: uint32(sel & 0x7),
+		}),
+		Cdw11: cdw11,
+	}
+
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
+		return 0, err
+	}
+
+	return cmd.Result, nil
+}
+
+// SetFeature issues Set Features for fid and returns completion dword 0. If save is set, the value
+// also becomes the saved value that persists across power cycles and resets.
+func (d *NVMeDevice) SetFeature(fid uint8, nsid uint32, cdw11, cdw12 uint32, save bool, buf []byte) (uint32, error) {
+	var sv uint32
+	if save {
+		sv = 1
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_SET_FEATURES,
+		Nsid:   nsid,
+		Data:   buf,
+		Cdw10: buildCdw(SetFeaturesCdw10BitInfo, SetFeaturesCdw10{
+			FID: uint32(fid),
+			SV:  sv,
+		}),
+		Cdw11: cdw11,
+		Cdw12: cdw12,
+	}
+
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
+		return 0, err
+	}
+
+	return cmd.Result, nil
+}
+
+func (d *NVMeDevice) GetFeatureCapabilities(fid uint8, nsid uint32) (FeatureCapabilities, error) {
+	res, err := d.GetFeature(fid, FEATURE_SEL_SUPPORTED, nsid, 0, nil)
+	if err != nil {
+		return FeatureCapabilities{}, err
+	}
+
+	return FeatureCapabilities{
+		Saveable:          getBitsValue(uint64(res), 0, 0) != 0,
+		NamespaceSpecific: getBitsValue(uint64(res), 1, 1) != 0,
+		Changeable:        getBitsValue(uint64(res), 2, 2) != 0,
+	}, nil
+}
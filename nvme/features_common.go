@@ -0,0 +1,331 @@
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Arbitration (FID 01h)
+type Arbitration struct {
+	AB  uint8 // Arbitration Burst, as a power of two (7 for no limit)
+	LPW uint8 // Low Priority Weight, 0's based
+	MPW uint8 // Medium Priority Weight, 0's based
+	HPW uint8 // High Priority Weight, 0's based
+}
+
+func (d *NVMeDevice) GetArbitration(sel uint8) (Arbitration, error) {
+	res, err := d.GetFeature(FEATURE_ARBITRATION, sel, 0, 0, nil)
+	if err != nil {
+		return Arbitration{}, err
+	}
+
+	return Arbitration{
+		AB:  uint8(getBitsValue(uint64(res), 0, 2)),
+		LPW: uint8(getBitsValue(uint64(res), 8, 15)),
+		MPW: uint8(getBitsValue(uint64(res), 16, 23)),
+		HPW: uint8(getBitsValue(uint64(res), 24, 31)),
+	}, nil
+}
+
+func (d *NVMeDevice) SetArbitration(a Arbitration, save bool) error {
+	cdw11 := uint32(a.AB&0x7) | uint32(a.LPW)<<8 | uint32(a.MPW)<<16 | uint32(a.HPW)<<24
+
+	_, err := d.SetFeature(FEATURE_ARBITRATION, 0, cdw11, 0, save, nil)
+	return err
+}
+
+// PowerManagement (FID 02h)
+type PowerManagement struct {
+	PS uint8 // Power State
+	WH uint8 // Workload Hint
+}
+
+func (d *NVMeDevice) GetPowerManagement(sel uint8) (PowerManagement, error) {
+	res, err := d.GetFeature(FEATURE_POWER_MGMT, sel, 0, 0, nil)
+	if err != nil {
+		return PowerManagement{}, err
+	}
+
+	return PowerManagement{
+		PS: uint8(getBitsValue(uint64(res), 0, 4)),
+		WH: uint8(getBitsValue(uint64(res), 5, 7)),
+	}, nil
+}
+
+func (d *NVMeDevice) SetPowerManagement(p PowerManagement, save bool) error {
+	cdw11 := uint32(p.PS&0x1f) | uint32(p.WH&0x7)<<5
+
+	_, err := d.SetFeature(FEATURE_POWER_MGMT, 0, cdw11, 0, save, nil)
+	return err
+}
+
+const (
+	// Threshold Type Select of the Temperature Threshold feature
+	TEMP_THRESHOLD_OVER  uint8 = 0x0
+	TEMP_THRESHOLD_UNDER uint8 = 0x1
+)
+
+// TemperatureThreshold (FID 04h). TMPSEL 0 selects the Composite Temperature, 1 to 8 select
+// Temperature Sensor 1 to 8 and 0xf selects all sensors (Set Features only).
+type TemperatureThreshold struct {
+	TMPTH  uint16 // Temperature Threshold, in Kelvin
+	TMPSEL uint8  // Threshold Temperature Select
+	THSEL  uint8  // Threshold Type Select
+}
+
+func (t TemperatureThreshold) cdw11() uint32 {
+	return uint32(t.TMPTH) | uint32(t.TMPSEL&0xf)<<16 | uint32(t.THSEL&0x3)<<20
+}
+
+// GetTemperatureThreshold returns the threshold selected by tmpsel and thsel.
+func (d *NVMeDevice) GetTemperatureThreshold(sel uint8, tmpsel uint8, thsel uint8) (TemperatureThreshold, error) {
+	t := TemperatureThreshold{TMPSEL: tmpsel, THSEL: thsel}
+
+	res, err := d.GetFeature(FEATURE_TEMP_THRESHOLD, sel, 0, t.cdw11(), nil)
+	if err != nil {
+		return TemperatureThreshold{}, err
+	}
+
+	t.TMPTH = uint16(getBitsValue(uint64(res), 0, 15))
+
+	return t, nil
+}
+
+func (d *NVMeDevice) SetTemperatureThreshold(t TemperatureThreshold, save bool) error {
+	_, err := d.SetFeature(FEATURE_TEMP_THRESHOLD, 0, t.cdw11(), 0, save, nil)
+	return err
+}
+
+// ErrorRecovery (FID 05h), namespace specific
+type ErrorRecovery struct {
+	TLER  uint16 // Time Limited Error Recovery, in 100 ms units
+	DULBE bool   // Deallocated or Unwritten Logical Block Error Enable
+}
+
+func (d *NVMeDevice) GetErrorRecovery(sel uint8, nsid uint32) (ErrorRecovery, error) {
+	res, err := d.GetFeature(FEATURE_ERROR_RECOVERY, sel, nsid, 0, nil)
+	if err != nil {
+		return ErrorRecovery{}, err
+	}
+
+	return ErrorRecovery{
+		TLER:  uint16(getBitsValue(uint64(res), 0, 15)),
+		DULBE: getBitsValue(uint64(res), 16, 16) != 0,
+	}, nil
+}
+
+func (d *NVMeDevice) SetErrorRecovery(nsid uint32, e ErrorRecovery, save bool) error {
+	cdw11 := uint32(e.TLER)
+	if e.DULBE {
+		cdw11 |= 1 << 16
+	}
+
+	_, err := d.SetFeature(FEATURE_ERROR_RECOVERY, nsid, cdw11, 0, save, nil)
+	return err
+}
+
+// GetVolatileWriteCache reports whether the volatile write cache (FID 06h) is enabled.
+func (d *NVMeDevice) GetVolatileWriteCache(sel uint8) (bool, error) {
+	res, err := d.GetFeature(FEATURE_VOLATILE_WC, sel, 0, 0, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return getBitsValue(uint64(res), 0, 0) != 0, nil
+}
+
+func (d *NVMeDevice) SetVolatileWriteCache(enable bool, save bool) error {
+	var cdw11 uint32
+	if enable {
+		cdw11 = 1
+	}
+
+	_, err := d.SetFeature(FEATURE_VOLATILE_WC, 0, cdw11, 0, save, nil)
+	return err
+}
+
+// NumberOfQueues (FID 07h). Unlike the on-the-wire encoding, the counts are not 0's based.
+type NumberOfQueues struct {
+	SubmissionQueues uint32
+	CompletionQueues uint32
+}
+
+func decodeNumberOfQueues(res uint32) NumberOfQueues {
+	return NumberOfQueues{
+		SubmissionQueues: uint32(getBitsValue(uint64(res), 0, 15)) + 1,
+		CompletionQueues: uint32(getBitsValue(uint64(res), 16, 31)) + 1,
+	}
+}
+
+func (d *NVMeDevice) GetNumberOfQueues(sel uint8) (NumberOfQueues, error) {
+	res, err := d.GetFeature(FEATURE_NUM_QUEUES, sel, 0, 0, nil)
+	if err != nil {
+		return NumberOfQueues{}, err
+	}
+
+	return decodeNumberOfQueues(res), nil
+}
+
+// SetNumberOfQueues requests q and returns the number of queues actually allocated.
+func (d *NVMeDevice) SetNumberOfQueues(q NumberOfQueues, save bool) (NumberOfQueues, error) {
+	if q.SubmissionQueues == 0 || q.CompletionQueues == 0 {
+		return NumberOfQueues{}, fmt.Errorf("number of queues must be at least 1")
+	}
+
+	cdw11 := (q.SubmissionQueues-1)&0xffff | ((q.CompletionQueues-1)&0xffff)<<16
+
+	res, err := d.SetFeature(FEATURE_NUM_QUEUES, 0, cdw11, 0, save, nil)
+	if err != nil {
+		return NumberOfQueues{}, err
+	}
+
+	return decodeNumberOfQueues(res), nil
+}
+
+// InterruptCoalescing (FID 08h)
+type InterruptCoalescing struct {
+	THR  uint8 // Aggregation Threshold, 0's based
+	TIME uint8 // Aggregation Time, in 100 µs units
+}
+
+func (d *NVMeDevice) GetInterruptCoalescing(sel uint8) (InterruptCoalescing, error) {
+	res, err := d.GetFeature(FEATURE_IRQ_COALESCE, sel, 0, 0, nil)
+	if err != nil {
+		return InterruptCoalescing{}, err
+	}
+
+	return InterruptCoalescing{
+		THR:  uint8(getBitsValue(uint64(res), 0, 7)),
+		TIME: uint8(getBitsValue(uint64(res), 8, 15)),
+	}, nil
+}
+
+func (d *NVMeDevice) SetInterruptCoalescing(c InterruptCoalescing, save bool) error {
+	cdw11 := uint32(c.THR) | uint32(c.TIME)<<8
+
+	_, err := d.SetFeature(FEATURE_IRQ_COALESCE, 0, cdw11, 0, save, nil)
+	return err
+}
+
+// AsyncEventConfig (FID 0Bh) selects which events generate an Asynchronous Event Notification.
+type AsyncEventConfig struct {
+	SmartCritWarnings   uint8 // Bit mask of SMART / Health critical warnings
+	NamespaceAttribute  bool
+	FirmwareActivation  bool
+	Telemetry           bool
+	ANAChange           bool
+	PredictableLatency  bool
+	LBAStatus           bool
+	EnduranceGroupEvent bool
+	NormalShutdown      bool
+	Discovery           bool
+}
+
+func (d *NVMeDevice) GetAsyncEventConfig(sel uint8) (AsyncEventConfig, error) {
+	res, err := d.GetFeature(FEATURE_ASYNC_EVENT, sel, 0, 0, nil)
+	if err != nil {
+		return AsyncEventConfig{}, err
+	}
+
+	v := uint64(res)
+
+	return AsyncEventConfig{
+		SmartCritWarnings:   uint8(getBitsValue(v, 0, 7)),
+		NamespaceAttribute:  getBitsValue(v, 8, 8) != 0,
+		FirmwareActivation:  getBitsValue(v, 9, 9) != 0,
+		Telemetry:           getBitsValue(v, 10, 10) != 0,
+		ANAChange:           getBitsValue(v, 11, 11) != 0,
+		PredictableLatency:  getBitsValue(v, 12, 12) != 0,
+		LBAStatus:           getBitsValue(v, 13, 13) != 0,
+		EnduranceGroupEvent: getBitsValue(v, 14, 14) != 0,
+		NormalShutdown:      getBitsValue(v, 15, 15) != 0,
+		Discovery:           getBitsValue(v, 31, 31) != 0,
+	}, nil
+}
+
+func (d *NVMeDevice) SetAsyncEventConfig(a AsyncEventConfig, save bool) error {
+	cdw11 := uint32(a.SmartCritWarnings)
+
+	for bit, set := range map[uint8]bool{
+		8:  a.NamespaceAttribute,
+		9:  a.FirmwareActivation,
+		10: a.Telemetry,
+		11: a.ANAChange,
+		12: a.PredictableLatency,
+		13: a.LBAStatus,
+		14: a.EnduranceGroupEvent,
+		15: a.NormalShutdown,
+		31: a.Discovery,
+	} {
+		if set {
+			cdw11 |= 1 << bit
+		}
+	}
+
+	_, err := d.SetFeature(FEATURE_ASYNC_EVENT, 0, cdw11, 0, save, nil)
+	return err
+}
+
+// Timestamp (FID 0Eh)
+type Timestamp struct {
+	Milliseconds uint64 // Milliseconds since midnight, 01-Jan-1970, UTC
+	Synch        bool   // The controller may have stopped counting, e.g. in a non-operational state
+	Origin       uint8  // 0: cleared by reset, 1: set by Set Features
+}
+
+func (d *NVMeDevice) GetTimestamp(sel uint8) (Timestamp, error) {
+	buf := make([]byte, 8)
+
+	if _, err := d.GetFeature(FEATURE_TIMESTAMP, sel, 0, 0, buf); err != nil {
+		return Timestamp{}, err
+	}
+
+	// The timestamp is a 48-bit field, followed by the attributes byte
+	return Timestamp{
+		Milliseconds: binary.LittleEndian.Uint64(buf) & (1<<48 - 1),
+		Synch:        getBitsValue(uint64(buf[6]), 0, 0) != 0,
+		Origin:       uint8(getBitsValue(uint64(buf[6]), 1, 3)),
+	}, nil
+}
+
+// SetTimestamp sets the controller's timestamp. Only the milliseconds are host-settable.
+func (d *NVMeDevice) SetTimestamp(milliseconds uint64) error {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, milliseconds&(1<<48-1))
+
+	_, err := d.SetFeature(FEATURE_TIMESTAMP, 0, 0, 0, false, buf)
+	return err
+}
+
+// HostBehavior (FID 16h)
+type HostBehavior struct {
+	ACRE   bool // Advanced Command Retry Enable
+	ETDAS  bool // Extended Telemetry Data Area 4 Supported
+	LBAFEE bool // LBA Format Extension Enable
+}
+
+func (d *NVMeDevice) GetHostBehavior(sel uint8) (HostBehavior, error) {
+	buf := make([]byte, 512)
+
+	if _, err := d.GetFeature(FEATURE_HOST_BEHAVIOR, sel, 0, 0, buf); err != nil {
+		return HostBehavior{}, err
+	}
+
+	return HostBehavior{
+		ACRE:   buf[0]&1 != 0,
+		ETDAS:  buf[1]&1 != 0,
+		LBAFEE: buf[2]&1 != 0,
+	}, nil
+}
+
+func (d *NVMeDevice) SetHostBehavior(h HostBehavior, save bool) error {
+	buf := make([]byte, 512)
+	for i, b := range []bool{h.ACRE, h.ETDAS, h.LBAFEE} {
+		if b {
+			buf[i] = 1
+		}
+	}
+
+	_, err := d.SetFeature(FEATURE_HOST_BEHAVIOR, 0, 0, 0, save, buf)
+	return err
+}
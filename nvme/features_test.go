@@ -0,0 +1,130 @@
+package nvme_test
+
+import (
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestFeatures(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	d := newSimDevice(t, cfg)
+
+	caps, err := d.GetFeatureCapabilities(nvme.FEATURE_TEMP_THRESHOLD, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.Saveable || !caps.Changeable || caps.NamespaceSpecific {
+		t.Errorf("temperature threshold capabilities %+v", caps)
+	}
+
+	th, err := d.GetTemperatureThreshold(nvme.FEATURE_SEL_CURRENT, 0, nvme.TEMP_THRESHOLD_OVER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.TMPTH != cfg.WarningTemp {
+		t.Errorf("temperature threshold %d, want %d", th.TMPTH, cfg.WarningTemp)
+	}
+
+	if err := d.SetTemperatureThreshold(nvme.TemperatureThreshold{TMPTH: 350}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		sel  uint8
+		want uint16
+	}{
+		{nvme.FEATURE_SEL_CURRENT, 350},
+		{nvme.FEATURE_SEL_DEFAULT, cfg.WarningTemp},
+		{nvme.FEATURE_SEL_SAVED, cfg.WarningTemp},
+	} {
+		th, err := d.GetTemperatureThreshold(c.sel, 0, nvme.TEMP_THRESHOLD_OVER)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if th.TMPTH != c.want {
+			t.Errorf("select %d: threshold %d, want %d", c.sel, th.TMPTH, c.want)
+		}
+	}
+
+	if err := d.SetVolatileWriteCache(false, true); err != nil {
+		t.Fatal(err)
+	}
+	if on, err := d.GetVolatileWriteCache(nvme.FEATURE_SEL_SAVED); err != nil || on {
+		t.Errorf("saved volatile write cache %v, err %v, want disabled", on, err)
+	}
+
+	// Timestamps transfer a data structure
+	if err := d.SetTimestamp(1700000000000); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := d.GetTimestamp(nvme.FEATURE_SEL_CURRENT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Milliseconds != 1700000000000 {
+		t.Errorf("timestamp %d, want 1700000000000", ts.Milliseconds)
+	}
+
+	if _, err := d.GetFeature(nvme.FEATURE_LBA_RANGE, nvme.FEATURE_SEL_CURRENT, 0, 0, nil); !nvme.IsInvalidField(err) {
+		t.Errorf("unsupported feature: got %v, want Invalid Field", err)
+	}
+}
+
+func TestFeatureEncoding(t *testing.T) {
+	tr := &recordingTransport{}
+	d := nvme.NewNVMeDeviceWithTransport("fake0", tr)
+
+	if err := d.SetArbitration(nvme.Arbitration{AB: 3, LPW: 1, MPW: 2, HPW: 4}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetPowerManagement(nvme.FEATURE_SEL_SAVED); err != nil {
+		t.Fatal(err)
+	}
+
+	// FID in bits 7:0, Save in bit 31 of Set Features; Select in bits 10:8 of Get Features
+	set, get := tr.admin[0], tr.admin[1]
+	if set.Opcode != nvme.NVME_ADMIN_SET_FEATURES || set.Cdw10 != uint32(nvme.FEATURE_ARBITRATION)|1<<31 || set.Cdw11 != 0x04020103 {
+		t.Errorf("set arbitration command %+v", set)
+	}
+	if get.Opcode != nvme.NVME_ADMIN_GET_FEATURES || get.Cdw10 != uint32(nvme.FEATURE_POWER_MGMT)|uint32(nvme.FEATURE_SEL_SAVED)<<8 {
+		t.Errorf("get power management command %+v", get)
+	}
+}
+
+func TestFeatureDecoding(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	if err := d.SetArbitration(nvme.Arbitration{AB: 7, LPW: 0x10, MPW: 0x20, HPW: 0x30}, false); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := d.GetArbitration(nvme.FEATURE_SEL_CURRENT); err != nil || a != (nvme.Arbitration{AB: 7, LPW: 0x10, MPW: 0x20, HPW: 0x30}) {
+		t.Errorf("arbitration %+v, err %v", a, err)
+	}
+
+	if err := d.SetPowerManagement(nvme.PowerManagement{PS: 2, WH: 1}, false); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := d.GetPowerManagement(nvme.FEATURE_SEL_CURRENT); err != nil || p != (nvme.PowerManagement{PS: 2, WH: 1}) {
+		t.Errorf("power management %+v, err %v", p, err)
+	}
+
+	// Queue counts are 0's based on the wire
+	q, err := d.GetNumberOfQueues(nvme.FEATURE_SEL_DEFAULT)
+	if err != nil || q != (nvme.NumberOfQueues{SubmissionQueues: 64, CompletionQueues: 64}) {
+		t.Errorf("default queues %+v, err %v", q, err)
+	}
+	q, err = d.SetNumberOfQueues(nvme.NumberOfQueues{SubmissionQueues: 8, CompletionQueues: 4}, false)
+	if err != nil || q != (nvme.NumberOfQueues{SubmissionQueues: 8, CompletionQueues: 4}) {
+		t.Errorf("allocated queues %+v, err %v", q, err)
+	}
+	if _, err := d.SetNumberOfQueues(nvme.NumberOfQueues{SubmissionQueues: 0, CompletionQueues: 1}, false); err == nil {
+		t.Error("zero submission queues accepted")
+	}
+
+	// Timestamp is changeable but not saveable
+	if _, err := d.SetFeature(nvme.FEATURE_TIMESTAMP, 0, 0, 0, true, make([]byte, 8)); !nvme.IsStatus(err, nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_FEATURE_NOT_SAVEABLE) {
+		t.Errorf("saving the timestamp: got %v, want Feature Identifier Not Saveable", err)
+	}
+}
@@ -0,0 +1,279 @@
+package nvme
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// Commit Action of Firmware Commit
+	FW_COMMIT_CA_REPLACE                 uint8 = 0x0 // Download to the slot, do not activate
+	FW_COMMIT_CA_REPLACE_ACTIVATE        uint8 = 0x1 // Download to the slot, activate at the next reset
+	FW_COMMIT_CA_ACTIVATE                uint8 = 0x2 // Activate the image in the slot at the next reset
+	FW_COMMIT_CA_ACTIVATE_IMMEDIATE      uint8 = 0x3 // Replace the slot image with a downloaded one, if any, and activate it without a reset
+	FW_COMMIT_CA_REPLACE_BOOT_PARTITION  uint8 = 0x6 // Download to the boot partition
+	FW_COMMIT_CA_ACTIVATE_BOOT_PARTITION uint8 = 0x7 // Mark the boot partition active
+
+	// Resets needed to complete a firmware activation
+	FW_RESET_NONE         uint8 = 0x0
+	FW_RESET_ANY          uint8 = 0x1 // Activation takes place at the next reset of any kind
+	FW_RESET_CONVENTIONAL uint8 = 0x2
+	FW_RESET_SUBSYSTEM    uint8 = 0x3
+	FW_RESET_CONTROLLER   uint8 = 0x4
+
+	// FW_DOWNLOAD_MAX_CHUNK caps the size of a single Firmware Image Download
+	FW_DOWNLOAD_MAX_CHUNK = 128 * 1024
+)
+
+var FwDownloadCdw10BitInfo = cdwBitInfo{
+	{
+		name: "NUMD", bitStart: 0,
+	},
+}
+
+type FwDownloadCdw10 struct {
+	NUMD uint32
+}
+
+var FwDownloadCdw11BitInfo = cdwBitInfo{
+	{
+		name: "OFST", bitStart: 0,
+	},
+}
+
+type FwDownloadCdw11 struct {
+	OFST uint32
+}
+
+var FwCommitCdw10BitInfo = cdwBitInfo{
+	{
+		name: "FS", bitStart: 0,
+	},
+	{
+		name: "CA", bitStart: 3,
+	},
+	{
+		name: "BPID", bitStart: 31,
+	},
+}
+
+type FwCommitCdw10 struct {
+	FS   uint32
+	CA   uint32
+	BPID uint32
+}
+
+// FirmwareCommit selects what Firmware Commit does with a downloaded image.
+type FirmwareCommit struct {
+	Slot            uint8 // 1 to 7, 0 lets the controller choose; ignored for boot partitions
+	Action          uint8 // FW_COMMIT_CA_*
+	BootPartitionID uint8 // 0 or 1
+}
+
+// FirmwareCommitResult tells whether a reset is needed before the committed image runs.
+type FirmwareCommitResult struct {
+	ResetRequired bool
+	ResetType     uint8 // FW_RESET_*
+}
+
+// firmwareChunkSize returns the largest download size that respects MDTS and is a multiple of the
+// firmware update granularity.
+func firmwareChunkSize(idCtrl *NvmeIdentController) (int, error) {
+	chunk := FW_DOWNLOAD_MAX_CHUNK
+
+	// MDTS is in units of the minimum memory page size, assumed to be 4 KiB
+	if idCtrl.Mdts != 0 && 4096<<idCtrl.Mdts < chunk {
+		chunk = 4096 << idCtrl.Mdts
+	}
+
+	// FWUG is in 4 KiB units; 0 means not reported and 0xff no restriction
+	if idCtrl.Fwug == 0 || idCtrl.Fwug == 0xff {
+		return chunk, nil
+	}
+
+	gran := int(idCtrl.Fwug) * 4096
+	if gran > chunk {
+		if idCtrl.Mdts != 0 && gran > 4096<<idCtrl.Mdts {
+			return 0, fmt.Errorf("firmware update granularity %d exceeds the maximum transfer size", gran)
+		}
+		return gran, nil
+	}
+
+	return chunk / gran * gran, nil
+}
+
+// DownloadFirmware transfers image to the controller in chunks that respect MDTS and FWUG. The
+// image must then be committed with CommitFirmware.
+func (d *NVMeDevice) DownloadFirmware(image []byte) error {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return err
+	}
+
+	return d.downloadFirmware(&idCtrl, image)
+}
+
+func (d *NVMeDevice) downloadFirmware(idCtrl *NvmeIdentController, image []byte) error {
+	if !idCtrl.HasOacs(OACS_FIRMWARE) {
+		return fmt.Errorf("%w: firmware download", ErrNotSupported)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_FIRMWARE_DOWNLOAD, "firmware download"); err != nil {
+		return err
+	}
+
+	if len(image) == 0 {
+		return fmt.Errorf("empty firmware image")
+	}
+
+	// Transfers are in dwords, so pad the image
+	if len(image)%4 != 0 {
+		image = append(image[:len(image):len(image)], make([]byte, 4-len(image)%4)...)
+	}
+
+	chunk, err := firmwareChunkSize(idCtrl)
+	if err != nil {
+		return err
+	}
+
+	for offset := 0; offset < len(image); offset += chunk {
+		end := min(offset+chunk, len(image))
+
+		cmd := Command{
+			Opcode: NVME_ADMIN_FIRMWARE_DOWNLOAD,
+			Data:   image[offset:end],
+			Cdw10:  buildCdw(FwDownloadCdw10BitInfo, FwDownloadCdw10{NUMD: uint32((end-offset)/4 - 1)}),
+			Cdw11:  buildCdw(FwDownloadCdw11BitInfo, FwDownloadCdw11{OFST: uint32(offset / 4)}),
+		}
+
+		if err := d.transport.SubmitAdmin(&cmd); err != nil {
+			return fmt.Errorf("firmware download at offset %d: %w", offset, err)
+		}
+	}
+
+	return nil
+}
+
+// validateFirmwareCommit checks c against the firmware update capabilities of the controller.
+func validateFirmwareCommit(idCtrl *NvmeIdentController, c *FirmwareCommit) error {
+	switch c.Action {
+	case FW_COMMIT_CA_REPLACE, FW_COMMIT_CA_REPLACE_ACTIVATE:
+		if c.Slot == 1 && idCtrl.FirmwareSlot1ReadOnly() {
+			return fmt.Errorf("firmware slot 1 is read only")
+		}
+	case FW_COMMIT_CA_ACTIVATE:
+	case FW_COMMIT_CA_ACTIVATE_IMMEDIATE:
+		if idCtrl.Frmw&FRMW_NO_RESET == 0 {
+			return fmt.Errorf("%w: firmware activation without reset", ErrNotSupported)
+		}
+	case FW_COMMIT_CA_REPLACE_BOOT_PARTITION, FW_COMMIT_CA_ACTIVATE_BOOT_PARTITION:
+		if c.BootPartitionID > 1 {
+			return fmt.Errorf("invalid boot partition ID %d", c.BootPartitionID)
+		}
+	default:
+		return fmt.Errorf("invalid commit action %d", c.Action)
+	}
+
+	if c.Slot > idCtrl.FirmwareSlots() {
+		return fmt.Errorf("firmware slot %d out of range (%d slots)", c.Slot, idCtrl.FirmwareSlots())
+	}
+
+	return nil
+}
+
+// CommitFirmware issues Firmware Commit. Statuses that report a successful commit whose
+// activation needs a reset are returned in the result rather than as an error.
+func (d *NVMeDevice) CommitFirmware(c FirmwareCommit) (FirmwareCommitResult, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return FirmwareCommitResult{}, err
+	}
+
+	return d.commitFirmware(&idCtrl, c)
+}
+
+func (d *NVMeDevice) commitFirmware(idCtrl *NvmeIdentController, c FirmwareCommit) (FirmwareCommitResult, error) {
+	if !idCtrl.HasOacs(OACS_FIRMWARE) {
+		return FirmwareCommitResult{}, fmt.Errorf("%w: firmware commit", ErrNotSupported)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_FIRMWARE_COMMIT, "firmware commit"); err != nil {
+		return FirmwareCommitResult{}, err
+	}
+
+	if err := validateFirmwareCommit(idCtrl, &c); err != nil {
+		return FirmwareCommitResult{}, err
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_FIRMWARE_COMMIT,
+		Cdw10: buildCdw(FwCommitCdw10BitInfo, FwCommitCdw10{
+			FS:   uint32(c.Slot),
+			CA:   uint32(c.Action),
+			BPID: uint32(c.BootPartitionID & 0x1),
+		}),
+	}
+
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
+		var se *StatusError
+		if !errors.As(err, &se) || se.SCT != NVME_SCT_CMD_SPECIFIC {
+			return FirmwareCommitResult{}, err
+		}
+
+		switch se.SC {
+		case NVME_SC_FW_NEEDS_CONV_RESET:
+			return FirmwareCommitResult{ResetRequired: true, ResetType: FW_RESET_CONVENTIONAL}, nil
+		case NVME_SC_FW_NEEDS_SUBSYS_RESET:
+			return FirmwareCommitResult{ResetRequired: true, ResetType: FW_RESET_SUBSYSTEM}, nil
+		case NVME_SC_FW_NEEDS_RESET:
+			return FirmwareCommitResult{ResetRequired: true, ResetType: FW_RESET_CONTROLLER}, nil
+		case NVME_SC_FW_NEEDS_MAX_TIME:
+			// Activating now would exceed MTFA, so the image runs after the next reset instead
+			return FirmwareCommitResult{ResetRequired: true, ResetType: FW_RESET_ANY}, nil
+		}
+
+		return FirmwareCommitResult{}, err
+	}
+
+	if c.Action == FW_COMMIT_CA_REPLACE_ACTIVATE || c.Action == FW_COMMIT_CA_ACTIVATE {
+		return FirmwareCommitResult{ResetRequired: true, ResetType: FW_RESET_ANY}, nil
+	}
+
+	// The new firmware may support different commands and log pages
+	if c.Action == FW_COMMIT_CA_ACTIVATE_IMMEDIATE {
+		d.resetSupportInfo()
+	}
+
+	return FirmwareCommitResult{}, nil
+}
+
+// UpdateFirmware downloads image and commits it as described by c, whose action must replace the
+// image in a slot or boot partition. FW_COMMIT_CA_ACTIVATE_IMMEDIATE replaces the slot image and
+// activates it without a reset.
+func (d *NVMeDevice) UpdateFirmware(image []byte, c FirmwareCommit) (FirmwareCommitResult, error) {
+	switch c.Action {
+	case FW_COMMIT_CA_REPLACE, FW_COMMIT_CA_REPLACE_ACTIVATE, FW_COMMIT_CA_ACTIVATE_IMMEDIATE,
+		FW_COMMIT_CA_REPLACE_BOOT_PARTITION:
+	default:
+		return FirmwareCommitResult{}, fmt.Errorf("commit action %d does not replace the downloaded image", c.Action)
+	}
+
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return FirmwareCommitResult{}, err
+	}
+
+	// Fail before a lengthy download if the commit cannot succeed
+	if err := validateFirmwareCommit(&idCtrl, &c); err != nil {
+		return FirmwareCommitResult{}, err
+	}
+	if c.Action == FW_COMMIT_CA_ACTIVATE_IMMEDIATE && c.Slot == 1 && idCtrl.FirmwareSlot1ReadOnly() {
+		return FirmwareCommitResult{}, fmt.Errorf("firmware slot 1 is read only")
+	}
+
+	if err := d.downloadFirmware(&idCtrl, image); err != nil {
+		return FirmwareCommitResult{}, err
+	}
+
+	return d.commitFirmware(&idCtrl, c)
+}
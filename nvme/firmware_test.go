@@ -0,0 +1,147 @@
+package nvme_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+// firmwareImage returns an image of size bytes whose revision is rev.
+func firmwareImage(rev string, size int) []byte {
+	image := pattern(size, 0x5a)
+	copy(image, rev+"\x00\x00\x00\x00\x00\x00\x00\x00")
+
+	return image
+}
+
+// firmwareSlots returns the active slot, the slot activated at the next reset and the revision of
+// each slot, indexed by slot number, from the Firmware Slot Information log.
+func firmwareSlots(t *testing.T, d *nvme.NVMeDevice) (active, next int, revs [8]string) {
+	t.Helper()
+
+	buf := make([]byte, 512)
+	if err := d.GetLogPageFwSlotInfo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for slot := 1; slot < len(revs); slot++ {
+		revs[slot] = string(bytes.TrimRight(buf[slot*8:slot*8+8], "\x00 "))
+	}
+
+	return int(buf[0] & 0x7), int(buf[0] >> 4 & 0x7), revs
+}
+
+func TestUpdateFirmware(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Mdts = 1 // Download in 8 KiB pieces
+	cfg.Slot1ReadOnly = true
+	c := nvmesim.New(cfg)
+	d := nvme.NewNVMeDeviceWithTransport("sim0", c)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	res, err := d.UpdateFirmware(firmwareImage("2.0", 30001), nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_REPLACE_ACTIVATE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.ResetRequired || res.ResetType != nvme.FW_RESET_ANY {
+		t.Errorf("result %+v, want a reset of any kind", res)
+	}
+
+	if active, next, revs := firmwareSlots(t, d); active != 1 || next != 2 || revs[2] != "2.0" {
+		t.Errorf("active slot %d, next slot %d, revisions %q, want 2.0 in slot 2 activated at the next reset", active, next, revs)
+	}
+
+	c.Reset()
+	if active, _, _ := firmwareSlots(t, d); active != 2 {
+		t.Errorf("active slot %d after reset, want 2", active)
+	}
+
+	if _, err := d.UpdateFirmware(firmwareImage("3.0", 4096), nvme.FirmwareCommit{Slot: 1}); err == nil {
+		t.Error("update of read-only slot 1 succeeded")
+	}
+	if _, err := d.UpdateFirmware(firmwareImage("3.0", 4096), nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_ACTIVATE}); err == nil {
+		t.Error("update with an action that does not replace the image succeeded")
+	}
+}
+
+func TestActivateFirmwareImmediately(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.FirmwareActivateNoReset = true
+	d, tr := newCountingDevice(t, cfg)
+
+	// Load the support information, so that only the update's own commands are counted
+	d.SupportsLogPage(nvme.LOGPAGE_FIRMWARE_SLOT_INFO)
+	tr.admin[nvme.NVME_ADMIN_IDENTIFY] = 0
+
+	res, err := d.UpdateFirmware(firmwareImage("2.1", 4096), nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_REPLACE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ResetRequired {
+		t.Errorf("result %+v, want no reset for a replace", res)
+	}
+	if n := tr.admin[nvme.NVME_ADMIN_IDENTIFY]; n != 1 {
+		t.Errorf("%d Identify commands, want 1", n)
+	}
+
+	// Activating the slot does not consume another download
+	res, err = d.CommitFirmware(nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ResetRequired {
+		t.Errorf("result %+v, want immediate activation", res)
+	}
+
+	if active, next, revs := firmwareSlots(t, d); active != 2 || next != 0 || revs[2] != "2.1" {
+		t.Errorf("active slot %d, next slot %d, revisions %q, want 2.1 running from slot 2", active, next, revs)
+	}
+
+	if _, err := d.CommitFirmware(nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_REPLACE}); !nvme.IsStatus(err, nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_INVALID_FW_IMAGE) {
+		t.Errorf("replace without a download: got %v, want Invalid Firmware Image", err)
+	}
+}
+
+func TestUpdateFirmwareActivateImmediately(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.FirmwareActivateNoReset = true
+	cfg.FirmwareSlots = 3
+	cfg.Slot1ReadOnly = true
+	d := newSimDevice(t, cfg)
+
+	// The downloaded image replaces the slot image before the slot is activated
+	res, err := d.UpdateFirmware(firmwareImage("3.0", 4096), nvme.FirmwareCommit{Slot: 3, Action: nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ResetRequired {
+		t.Errorf("result %+v, want immediate activation", res)
+	}
+
+	info, err := d.GetFirmwareSlots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ActiveSlot != 3 || info.NextSlot != 0 || info.Slots[2].Revision != "3.0" || info.ActiveRevision() != "3.0" {
+		t.Errorf("slot info %+v, want 3.0 running from slot 3", info)
+	}
+
+	if _, err := d.UpdateFirmware(firmwareImage("3.1", 4096), nvme.FirmwareCommit{Slot: 1, Action: nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE}); err == nil {
+		t.Error("update of read-only slot 1 succeeded")
+	}
+}
+
+func TestActivateFirmwareNeedsReset(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	_, err := d.CommitFirmware(nvme.FirmwareCommit{Slot: 1, Action: nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE})
+	if !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("immediate activation without FRMW support: got %v, want ErrNotSupported", err)
+	}
+}
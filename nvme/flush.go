@@ -0,0 +1,36 @@
+package nvme
+
+import (
+	"fmt"
+)
+
+// Flush commits data in the volatile write cache of namespace nsid, or of all namespaces if nsid
+// is NSID_ALL, to non-volatile media. Without a volatile write cache there is nothing to flush
+// and no command is sent.
+func (d *NVMeDevice) Flush(nsid uint32) error {
+	idCtrl, err := d.cachedIdentifyController()
+	if err != nil {
+		return err
+	}
+
+	if !idCtrl.VolatileWriteCachePresent() {
+		return nil
+	}
+
+	// VWC bits 2:1 of 0 mean broadcast support is not reported, so only a definite 10b is refused
+	if nsid == NSID_ALL && getBitsValue(uint64(idCtrl.Vwc), 1, 2) == 0x2 {
+		return fmt.Errorf("%w: flush of all namespaces", ErrNotSupported)
+	}
+
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_FLUSH,
+		Nsid:   nsid,
+	}
+
+	return d.transport.SubmitIO(&cmd)
+}
+
+// Flush commits data of the namespace in the volatile write cache to non-volatile media.
+func (n *NVMeNamespace) Flush() error {
+	return n.dev.Flush(n.Nsid)
+}
@@ -0,0 +1,243 @@
+package nvme
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// Secure Erase Settings of Format NVM
+	FORMAT_SES_NONE      uint8 = 0x0
+	FORMAT_SES_USER_DATA uint8 = 0x1
+	FORMAT_SES_CRYPTO    uint8 = 0x2
+
+	// Protection Information types of Format NVM
+	FORMAT_PI_NONE  uint8 = 0x0
+	FORMAT_PI_TYPE1 uint8 = 0x1
+	FORMAT_PI_TYPE2 uint8 = 0x2
+	FORMAT_PI_TYPE3 uint8 = 0x3
+
+	// FORMAT_DEFAULT_TIMEOUT_MS is used when FormatParams.TimeoutMs is 0. A format, and in
+	// particular a user data erase, can take far longer than the driver's default admin timeout.
+	FORMAT_DEFAULT_TIMEOUT_MS uint32 = 10 * 60 * 1000
+)
+
+var FormatNVMCdw10BitInfo = cdwBitInfo{
+	{
+		name: "LBAFL", bitStart: 0,
+	},
+	{
+		name: "MSET", bitStart: 4,
+	},
+	{
+		name: "PI", bitStart: 5,
+	},
+	{
+		name: "PIL", bitStart: 8,
+	},
+	{
+		name: "SES", bitStart: 9,
+	},
+	{
+		name: "LBAFU", bitStart: 12,
+	},
+}
+
+type FormatNVMCdw10 struct {
+	LBAFL uint32
+	MSET  uint32
+	PI    uint32
+	PIL   uint32
+	SES   uint32
+	LBAFU uint32
+}
+
+// FormatParams describes the format applied by Format.
+type FormatParams struct {
+	FormatIndex      uint8  // LBA format index
+	ExtendedMetadata bool   // Transfer metadata as part of an extended data LBA
+	PI               uint8  // Protection Information type, FORMAT_PI_*
+	PIFirst          bool   // Protection information is in the first bytes of the metadata
+	SES              uint8  // Secure Erase Settings, FORMAT_SES_*
+	TimeoutMs        uint32 // 0 for FORMAT_DEFAULT_TIMEOUT_MS
+}
+
+// validateFormat checks p against the controller's Format NVM Attributes and the LBA formats and
+// protection capabilities of the namespace.
+func (d *NVMeDevice) validateFormat(nsid uint32, p *FormatParams) error {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return err
+	}
+
+	if !idCtrl.HasOacs(OACS_FORMAT_NVM) {
+		return fmt.Errorf("%w: Format NVM", ErrNotSupported)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_FORMAT_NVM, "Format NVM"); err != nil {
+		return err
+	}
+
+	switch p.SES {
+	case FORMAT_SES_NONE, FORMAT_SES_USER_DATA:
+	case FORMAT_SES_CRYPTO:
+		if !idCtrl.HasFna(FNA_CRYPTO_ERASE) {
+			return fmt.Errorf("%w: cryptographic erase", ErrNotSupported)
+		}
+	default:
+		return fmt.Errorf("invalid secure erase setting %d", p.SES)
+	}
+
+	if nsid == NSID_ALL {
+		if idCtrl.HasFna(FNA_NO_BROADCAST_NS) {
+			return fmt.Errorf("%w: format of all namespaces", ErrNotSupported)
+		}
+	} else {
+		// These controllers would silently apply the operation to every namespace
+		if idCtrl.HasFna(FNA_FORMAT_ALL_NS) {
+			return fmt.Errorf("controller formats all namespaces at once, use NSID_ALL")
+		}
+		if p.SES != FORMAT_SES_NONE && idCtrl.HasFna(FNA_SEC_ERASE_ALL) {
+			return fmt.Errorf("controller erases all namespaces at once, use NSID_ALL")
+		}
+	}
+
+	ns, err := d.IdentifyNamespace(nsid)
+	if err != nil {
+		return err
+	}
+
+	if p.FormatIndex > ns.Nlbaf || int(p.FormatIndex) >= len(ns.Lbaf) {
+		return fmt.Errorf("LBA format %d out of range (%d formats)", p.FormatIndex, int(ns.Nlbaf)+1)
+	}
+
+	lbaf := ns.Lbaf[p.FormatIndex]
+	if lbaf.DataSize() == 0 {
+		return fmt.Errorf("LBA format %d is not supported", p.FormatIndex)
+	}
+
+	if p.PI == FORMAT_PI_NONE {
+		return nil
+	}
+
+	if p.PI > FORMAT_PI_TYPE3 {
+		return fmt.Errorf("invalid protection information type %d", p.PI)
+	}
+
+	if ns.Dpc&(1<<(p.PI-1)) == 0 {
+		return fmt.Errorf("%w: protection information type %d", ErrNotSupported, p.PI)
+	}
+
+	if (p.PIFirst && ns.Dpc&DPC_FIRST == 0) || (!p.PIFirst && ns.Dpc&DPC_LAST == 0) {
+		return fmt.Errorf("%w: requested protection information location", ErrNotSupported)
+	}
+
+	if lbaf.Ms < 8 {
+		return fmt.Errorf("LBA format %d has %d bytes of metadata, too few for protection information", p.FormatIndex, lbaf.Ms)
+	}
+
+	return nil
+}
+
+// Format issues Format NVM for namespace nsid, or for all namespaces if nsid is NSID_ALL. Since the
+// controller may format other namespaces as well (cf. FNA), existing NVMeNamespace handles of the
+// device refresh their Identify data and block size before their next command.
+func (d *NVMeDevice) Format(nsid uint32, p FormatParams) error {
+	if nsid == 0 {
+		return fmt.Errorf("invalid namespace ID %#x", nsid)
+	}
+
+	if err := d.validateFormat(nsid, &p); err != nil {
+		return err
+	}
+
+	var mset, pil uint32
+	if p.ExtendedMetadata {
+		mset = 1
+	}
+	if p.PIFirst {
+		pil = 1
+	}
+
+	timeout := p.TimeoutMs
+	if timeout == 0 {
+		timeout = FORMAT_DEFAULT_TIMEOUT_MS
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_FORMAT_NVM,
+		Nsid:   nsid,
+		Cdw10: buildCdw(FormatNVMCdw10BitInfo, FormatNVMCdw10{
+			LBAFL: uint32(p.FormatIndex & 0xf),
+			MSET:  mset,
+			PI:    uint32(p.PI),
+			PIL:   pil,
+			SES:   uint32(p.SES),
+			LBAFU: uint32(p.FormatIndex>>4) & 0x3,
+		}),
+		TimeoutMs: timeout,
+	}
+
+	// A failed format may have left the namespace in an unknown state, too
+	d.formatGen++
+
+	return d.transport.SubmitAdmin(&cmd)
+}
+
+// Format formats the namespace and refreshes its Identify data and block size.
+func (n *NVMeNamespace) Format(p FormatParams) error {
+	if err := n.dev.Format(n.Nsid, p); err != nil {
+		return err
+	}
+
+	return n.Refresh()
+}
+
+// FormatProgress returns the percentage of namespace nsid that remains to be formatted, 0 if no
+// format is in progress. It wraps ErrNotSupported if the namespace does not report its progress
+// in FPI.
+func (d *NVMeDevice) FormatProgress(nsid uint32) (uint8, error) {
+	ident, err := d.IdentifyNamespace(nsid)
+	if err != nil {
+		return 0, err
+	}
+
+	if ident.Fpi&FPI_SUPPORTED == 0 {
+		return 0, fmt.Errorf("%w: format progress indicator", ErrNotSupported)
+	}
+
+	return ident.Fpi & FPI_REMAINING_MASK, nil
+}
+
+// WaitFormat polls the Format Progress Indicator of namespace nsid every interval until no format
+// is in progress, calling progress (if not nil) with the remaining percentage after each poll.
+func (d *NVMeDevice) WaitFormat(ctx context.Context, nsid uint32, interval time.Duration, progress func(remaining uint8)) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid polling interval %v", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		remaining, err := d.FormatProgress(nsid)
+		if err != nil {
+			return err
+		}
+
+		if progress != nil {
+			progress(remaining)
+		}
+
+		if remaining == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
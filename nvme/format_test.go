@@ -0,0 +1,126 @@
+package nvme_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestFormat(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+	size := n.Ident.SizeBytes()
+
+	if err := n.Write(0, 1, pattern(512, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Format(nvme.FormatParams{FormatIndex: 1, SES: nvme.FORMAT_SES_USER_DATA}); err != nil {
+		t.Fatal(err)
+	}
+	if n.BlockSize != 4096 || n.Ident.SizeBytes() != size {
+		t.Errorf("block size %d, size %d bytes, want 4096 %d", n.BlockSize, n.Ident.SizeBytes(), size)
+	}
+
+	buf := make([]byte, 4096)
+	if err := n.Read(0, 1, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, make([]byte, 4096)) {
+		t.Error("data survived the format")
+	}
+
+	if err := n.Format(nvme.FormatParams{FormatIndex: 2}); err == nil {
+		t.Error("format to an LBA format that does not exist succeeded")
+	}
+	if err := n.Format(nvme.FormatParams{PI: nvme.FORMAT_PI_TYPE1}); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("protection information: got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestFormatAllRefreshesHandles(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Namespaces = append(cfg.Namespaces, nvmesim.NamespaceConfig{Nsid: 2, Size: 4096})
+	d := newSimDevice(t, cfg)
+
+	namespaces, err := d.Namespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Format(nvme.NSID_ALL, nvme.FormatParams{FormatIndex: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Existing handles pick up the new LBA format before their next command
+	for _, n := range namespaces {
+		data := pattern(4096, byte(n.Nsid))
+		if err := n.Write(0, 1, data); err != nil {
+			t.Fatalf("namespace %d: %v", n.Nsid, err)
+		}
+		if n.BlockSize != 4096 {
+			t.Errorf("namespace %d: block size %d, want 4096", n.Nsid, n.BlockSize)
+		}
+
+		buf := make([]byte, 4096)
+		if err := n.Read(0, 1, buf); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, data) {
+			t.Errorf("namespace %d: read data differs from written data", n.Nsid)
+		}
+	}
+
+	if n := namespaces[1]; n.Ident.Nsze != 512 {
+		t.Errorf("namespace 2: NSZE %d, want 512", n.Ident.Nsze)
+	}
+}
+
+func TestWaitFormat(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.FormatProgress = true
+	d := newSimDevice(t, cfg)
+
+	if remaining, err := d.FormatProgress(1); err != nil || remaining != 0 {
+		t.Fatalf("remaining %d%%, err %v before a format", remaining, err)
+	}
+
+	if err := d.Format(1, nvme.FormatParams{FormatIndex: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	var polls []uint8
+	if err := d.WaitFormat(context.Background(), 1, time.Millisecond, func(remaining uint8) {
+		polls = append(polls, remaining)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(polls, []uint8{75, 50, 25, 0}) {
+		t.Errorf("remaining %v, want 75 50 25 0", polls)
+	}
+
+	if err := d.Format(1, nvme.FormatParams{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := d.WaitFormat(ctx, 1, time.Millisecond, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled wait: got %v, want context.Canceled", err)
+	}
+}
+
+func TestWaitFormatNotSupported(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	if _, err := d.FormatProgress(1); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if err := d.WaitFormat(context.Background(), 1, time.Millisecond, nil); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+}
@@ -3,9 +3,6 @@ package nvme
 import (
 	"bytes"
 	"encoding/binary"
-	"unsafe"
-
-	"github.com/AaronFei/go-nvme/ioctl"
 )
 
 const (
@@ -33,54 +30,217 @@ const (
 	IDENTIFY_CNS_IOCS           uint8 = 0x1c
 )
 
+// NvmeIdentNamespace is the I/O Command Set Independent part of the Identify Namespace data
+// structure for the NVM Command Set (cf. NVM Command Set Specification 1.0c, figure 97).
 type NvmeIdentNamespace struct {
-	Nsze    uint64
-	Ncap    uint64
-	Nuse    uint64
-	Nsfeat  uint8
-	Nlbaf   uint8
-	Flbas   uint8
-	Mc      uint8
-	Dpc     uint8
-	Dps     uint8
-	Nmic    uint8
-	Rescap  uint8
-	Fpi     uint8
-	Rsvd33  uint8
-	Nawun   uint16
-	Nawupf  uint16
-	Nacwu   uint16
-	Nabsn   uint16
-	Nabo    uint16
-	Nabspf  uint16
-	Rsvd46  [2]byte
-	Nvmcap  [16]byte
-	Rsvd64  [40]byte
-	Nguid   [16]byte
-	EUI64   [8]byte
-	Lbaf    [16]lbaf
-	Rsvd192 [192]byte
-	Vs      [3712]byte
+	Nsze     uint64        // Namespace Size
+	Ncap     uint64        // Namespace Capacity
+	Nuse     uint64        // Namespace Utilization
+	Nsfeat   uint8         // Namespace Features
+	Nlbaf    uint8         // Number of LBA Formats (0's based)
+	Flbas    uint8         // Formatted LBA Size
+	Mc       uint8         // Metadata Capabilities
+	Dpc      uint8         // End-to-end Data Protection Capabilities
+	Dps      uint8         // End-to-end Data Protection Type Settings
+	Nmic     uint8         // Namespace Multi-path I/O and Namespace Sharing Capabilities
+	Rescap   uint8         // Reservation Capabilities
+	Fpi      uint8         // Format Progress Indicator
+	Dlfeat   uint8         // Deallocate Logical Block Features
+	Nawun    uint16        // Namespace Atomic Write Unit Normal
+	Nawupf   uint16        // Namespace Atomic Write Unit Power Fail
+	Nacwu    uint16        // Namespace Atomic Compare & Write Unit
+	Nabsn    uint16        // Namespace Atomic Boundary Size Normal
+	Nabo     uint16        // Namespace Atomic Boundary Offset
+	Nabspf   uint16        // Namespace Atomic Boundary Size Power Fail
+	Noiob    uint16        // Namespace Optimal I/O Boundary
+	Nvmcap   Uint128       // NVM Capacity
+	Npwg     uint16        // Namespace Preferred Write Granularity (0's based)
+	Npwa     uint16        // Namespace Preferred Write Alignment (0's based)
+	Npdg     uint16        // Namespace Preferred Deallocate Granularity (0's based)
+	Npda     uint16        // Namespace Preferred Deallocate Alignment (0's based)
+	Nows     uint16        // Namespace Optimal Write Size (0's based)
+	Mssrl    uint16        // Maximum Single Source Range Length
+	Mcl      uint32        // Maximum Copy Length
+	Msrc     uint8         // Maximum Source Range Count (0's based)
+	Rsvd81   uint8         // ...
+	Nulbaf   uint8         // Number of Unique Capability LBA Formats
+	Rsvd83   [9]byte       // ...
+	Anagrpid uint32        // ANA Group Identifier
+	Rsvd96   [3]byte       // ...
+	Nsattr   uint8         // Namespace Attributes
+	Nvmsetid uint16        // NVM Set Identifier
+	Endgid   uint16        // Endurance Group Identifier
+	Nguid    [16]byte      // Namespace Globally Unique Identifier
+	EUI64    [8]byte       // IEEE Extended Unique Identifier
+	Lbaf     [64]LbaFormat // LBA Format Support
+	Vs       [3712]byte    // Vendor Specific
 } // 4096 bytes
 
-type lbaf struct {
-	Ms    uint16
-	Lbads uint8
-	Rp    uint8
+// LbaFormat is an LBA Format Data Structure entry of Identify Namespace.
+type LbaFormat struct {
+	Ms    uint16 // Metadata Size
+	Lbads uint8  // LBA Data Size, as a power of two
+	Rp    uint8  // Relative Performance
+}
+
+// DataSize returns the LBA data size in bytes, or 0 if the format is not supported.
+func (f LbaFormat) DataSize() uint32 {
+	if f.Lbads < 9 {
+		return 0
+	}
+
+	return 1 << f.Lbads
+}
+
+const (
+	// Namespace Features
+	NSFEAT_THIN_PROVISIONING uint8 = 1 << 0
+	NSFEAT_ATOMIC            uint8 = 1 << 1
+	NSFEAT_DULBE             uint8 = 1 << 2
+	NSFEAT_UID_REUSE         uint8 = 1 << 3
+	NSFEAT_OPT_PERF          uint8 = 1 << 4
+
+	// Formatted LBA Size
+	FLBAS_EXTENDED_METADATA uint8 = 1 << 4
+
+	// End-to-end Data Protection Capabilities
+	DPC_TYPE1 uint8 = 1 << 0
+	DPC_TYPE2 uint8 = 1 << 1
+	DPC_TYPE3 uint8 = 1 << 2
+	DPC_FIRST uint8 = 1 << 3
+	DPC_LAST  uint8 = 1 << 4
+
+	// Namespace Multi-path I/O and Namespace Sharing Capabilities
+	NMIC_SHARED uint8 = 1 << 0
+
+	// Format Progress Indicator
+	FPI_SUPPORTED      uint8 = 1 << 7
+	FPI_REMAINING_MASK uint8 = 0x7f // Percentage of the namespace that remains to be formatted
+
+	// Reservation Capabilities
+	RESCAP_PTPL      uint8 = 1 << 0 // Persist Through Power Loss
+	RESCAP_WE        uint8 = 1 << 1 // Write Exclusive
+	RESCAP_EA        uint8 = 1 << 2 // Exclusive Access
+	RESCAP_WE_RO     uint8 = 1 << 3 // Write Exclusive - Registrants Only
+	RESCAP_EA_RO     uint8 = 1 << 4 // Exclusive Access - Registrants Only
+	RESCAP_WE_AR     uint8 = 1 << 5 // Write Exclusive - All Registrants
+	RESCAP_EA_AR     uint8 = 1 << 6 // Exclusive Access - All Registrants
+	RESCAP_IEKEY_1_3 uint8 = 1 << 7 // Ignore Existing Key behaves as defined in NVMe 1.3
+
+	// Deallocate Logical Block Features
+	DLFEAT_READ_MASK    uint8 = 0x7
+	DLFEAT_READ_ZEROES  uint8 = 0x1
+	DLFEAT_READ_ONES    uint8 = 0x2
+	DLFEAT_WRITE_ZEROES uint8 = 1 << 3
+	DLFEAT_GUARD_CRC    uint8 = 1 << 4
+
+	// Namespace Attributes
+	NSATTR_WRITE_PROTECTED uint8 = 1 << 0
+)
+
+func (ns *NvmeIdentNamespace) HasNsfeat(mask uint8) bool {
+	return ns.Nsfeat&mask == mask
+}
+
+func (ns *NvmeIdentNamespace) HasRescap(mask uint8) bool {
+	return ns.Rescap&mask == mask
+}
+
+// FormatIndex returns the index of the LBA format the namespace is formatted with. FLBAS bits 3:0
+// hold the low bits of the index, and bits 6:5 the upper bits when more than 16 formats exist.
+func (ns *NvmeIdentNamespace) FormatIndex() uint8 {
+	idx := uint8(getBitsValue(uint64(ns.Flbas), 0, 3))
+	if ns.Nlbaf >= 16 {
+		idx |= uint8(getBitsValue(uint64(ns.Flbas), 5, 6)) << 4
+	}
+
+	return idx
+}
+
+// CurrentLbaFormat returns the LBA format the namespace is formatted with.
+func (ns *NvmeIdentNamespace) CurrentLbaFormat() LbaFormat {
+	return ns.Lbaf[ns.FormatIndex()]
+}
+
+// BlockSize returns the logical block data size in bytes.
+func (ns *NvmeIdentNamespace) BlockSize() uint32 {
+	return ns.CurrentLbaFormat().DataSize()
+}
+
+// MetadataSize returns the number of metadata bytes per logical block.
+func (ns *NvmeIdentNamespace) MetadataSize() uint16 {
+	return ns.CurrentLbaFormat().Ms
+}
+
+// ExtendedMetadata reports whether metadata is transferred at the end of each logical block as
+// part of an extended data LBA, as opposed to in a separate contiguous buffer.
+func (ns *NvmeIdentNamespace) ExtendedMetadata() bool {
+	return ns.Flbas&FLBAS_EXTENDED_METADATA != 0
+}
+
+// SizeBytes, CapacityBytes and UsedBytes convert NSZE, NCAP and NUSE from logical blocks to bytes.
+func (ns *NvmeIdentNamespace) SizeBytes() uint64 {
+	return ns.Nsze * uint64(ns.BlockSize())
+}
+
+func (ns *NvmeIdentNamespace) CapacityBytes() uint64 {
+	return ns.Ncap * uint64(ns.BlockSize())
+}
+
+func (ns *NvmeIdentNamespace) UsedBytes() uint64 {
+	return ns.Nuse * uint64(ns.BlockSize())
+}
+
+// optPerf converts a 0's based optimal performance field to logical blocks, or 0 when the
+// namespace does not report the optimal performance fields.
+func (ns *NvmeIdentNamespace) optPerf(v uint16) uint32 {
+	if !ns.HasNsfeat(NSFEAT_OPT_PERF) {
+		return 0
+	}
+
+	return uint32(v) + 1
+}
+
+// PreferredWriteGranularity returns NPWG in logical blocks, or 0 if not reported.
+func (ns *NvmeIdentNamespace) PreferredWriteGranularity() uint32 {
+	return ns.optPerf(ns.Npwg)
+}
+
+// PreferredWriteAlignment returns NPWA in logical blocks, or 0 if not reported.
+func (ns *NvmeIdentNamespace) PreferredWriteAlignment() uint32 {
+	return ns.optPerf(ns.Npwa)
+}
+
+// PreferredDeallocateGranularity returns NPDG in logical blocks, or 0 if not reported.
+func (ns *NvmeIdentNamespace) PreferredDeallocateGranularity() uint32 {
+	return ns.optPerf(ns.Npdg)
+}
+
+// PreferredDeallocateAlignment returns NPDA in logical blocks, or 0 if not reported.
+func (ns *NvmeIdentNamespace) PreferredDeallocateAlignment() uint32 {
+	return ns.optPerf(ns.Npda)
+}
+
+// OptimalWriteSize returns NOWS in logical blocks, or 0 if not reported.
+func (ns *NvmeIdentNamespace) OptimalWriteSize() uint32 {
+	return ns.optPerf(ns.Nows)
+}
+
+func (ns *NvmeIdentNamespace) WriteProtected() bool {
+	return ns.Nsattr&NSATTR_WRITE_PROTECTED != 0
 }
 
 func (d *NVMeDevice) IdentifyRaw(cns uint8, nsid uint32, cdw10 uint32, cdw11 uint32, cdw14 uint32, buf []byte) error {
-	cmd := nvmePassthruCommand{
-		opcode:   NVME_ADMIN_IDENTIFY,
-		nsid:     nsid, // Namespace 0, since we are identifying the controller
-		addr:     uint64(uintptr(unsafe.Pointer(&(buf[0])))),
-		data_len: uint32(len(buf)),
-		cdw10:    cdw10, // Identify controller
-		cdw11:    cdw11,
-		cdw14:    cdw14,
+	cmd := Command{
+		Opcode: NVME_ADMIN_IDENTIFY,
+		Nsid:   nsid, // Namespace 0, since we are identifying the controller
+		Data:   buf,
+		Cdw10:  cdw10, // Identify controller
+		Cdw11:  cdw11,
+		Cdw14:  cdw14,
 	}
 
-	if err := ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_ADMIN_CMD, uintptr(unsafe.Pointer(&cmd))); err != nil {
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
 		return err
 	}
 
@@ -111,6 +271,25 @@ func (d *NVMeDevice) IdentifyController() (NvmeIdentController, error) {
 	return idCtrlr, nil
 }
 
+// cachedIdentifyController returns Identify Controller data for the checks made before I/O
+// commands, reading it only if there is none since the last Format NVM or resetSupportInfo.
+func (d *NVMeDevice) cachedIdentifyController() (*NvmeIdentController, error) {
+	if d.idCtrl != nil && d.idCtrlGen == d.formatGen {
+		return d.idCtrl, nil
+	}
+
+	gen := d.formatGen
+
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return nil, err
+	}
+
+	d.idCtrl, d.idCtrlGen = &idCtrl, gen
+
+	return d.idCtrl, nil
+}
+
 func (d *NVMeDevice) IdentifyNamespace(nsid uint32) (NvmeIdentNamespace, error) {
 	buf := make([]byte, 4096)
 
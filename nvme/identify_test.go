@@ -0,0 +1,179 @@
+package nvme_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestIdentifyControllerLayout(t *testing.T) {
+	if size := binary.Size(nvme.NvmeIdentController{}); size != 4096 {
+		t.Fatalf("Identify Controller data structure of %d bytes, want 4096", size)
+	}
+
+	// Place values at the byte offsets of NVM Express Base Specification 2.0c, figure 275
+	tr := &recordingTransport{
+		respond: func(cmd *nvme.Command) error {
+			b := cmd.Data
+			binary.LittleEndian.PutUint32(b[80:], 0x00010400) // VER 1.4.0
+			b[260] = 0x7<<1 | nvme.FRMW_SLOT1_RO              // FRMW
+			b[512] = 0x66                                     // SQES
+			b[513] = 0x44                                     // CQES
+			binary.LittleEndian.PutUint16(b[520:], nvme.ONCS_COPY)
+			b[525] = 0x7 // VWC present, flush all supported
+			binary.LittleEndian.PutUint16(b[534:], 0x5)
+			copy(b[768:], "nqn.2014-08.org.example:test")
+			return nil
+		},
+	}
+
+	idCtrl, err := nvme.NewNVMeDeviceWithTransport("fake0", tr).IdentifyController()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if major, minor, tertiary := idCtrl.Version(); major != 1 || minor != 4 || tertiary != 0 {
+		t.Errorf("version %d.%d.%d, want 1.4.0", major, minor, tertiary)
+	}
+	if idCtrl.FirmwareSlots() != 7 || !idCtrl.FirmwareSlot1ReadOnly() {
+		t.Errorf("firmware slots %d, slot 1 read-only %v", idCtrl.FirmwareSlots(), idCtrl.FirmwareSlot1ReadOnly())
+	}
+	if idCtrl.SqesMin() != 6 || idCtrl.SqesMax() != 6 || idCtrl.CqesMin() != 4 || idCtrl.CqesMax() != 4 {
+		t.Errorf("SQES %#x CQES %#x", idCtrl.Sqes, idCtrl.Cqes)
+	}
+	if !idCtrl.HasOncs(nvme.ONCS_COPY) || idCtrl.HasOncs(nvme.ONCS_DSM) {
+		t.Errorf("ONCS %#x", idCtrl.Oncs)
+	}
+	if !idCtrl.VolatileWriteCachePresent() || !idCtrl.FlushAllSupported() {
+		t.Errorf("VWC %#x", idCtrl.Vwc)
+	}
+	if !idCtrl.HasOcfs(0x4) || idCtrl.HasOcfs(0x2) {
+		t.Errorf("OCFS %#x", idCtrl.Ocfs)
+	}
+	if nqn := idCtrl.SubsystemNQN(); nqn != "nqn.2014-08.org.example:test" {
+		t.Errorf("subsystem NQN %q", nqn)
+	}
+}
+
+func TestIdentifyController(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.IEEE = [3]byte{0x38, 0x25, 0x00}
+	d := newSimDevice(t, cfg)
+
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idCtrl.VendorID != cfg.VendorID || idCtrl.Ssvid != cfg.SubsysVendorID {
+		t.Errorf("VID %#x SSVID %#x, want %#x %#x", idCtrl.VendorID, idCtrl.Ssvid, cfg.VendorID, cfg.SubsysVendorID)
+	}
+	if idCtrl.Mdts != cfg.Mdts || idCtrl.Elpe != cfg.ErrorLogEntries || idCtrl.Nn != 1 {
+		t.Errorf("MDTS %d ELPE %d NN %d, want %d %d 1", idCtrl.Mdts, idCtrl.Elpe, idCtrl.Nn, cfg.Mdts, cfg.ErrorLogEntries)
+	}
+	if idCtrl.Wctemp != cfg.WarningTemp || idCtrl.Cctemp != cfg.CriticalTemp {
+		t.Errorf("WCTEMP %d CCTEMP %d, want %d %d", idCtrl.Wctemp, idCtrl.Cctemp, cfg.WarningTemp, cfg.CriticalTemp)
+	}
+	if major, minor, _ := idCtrl.Version(); major != 2 || minor != 0 {
+		t.Errorf("version %d.%d, want 2.0", major, minor)
+	}
+	if idCtrl.Tnvmcap.Lo != 1<<21*512 || idCtrl.Tnvmcap.Hi != 0 {
+		t.Errorf("TNVMCAP %v, want %d", idCtrl.Tnvmcap, 1<<21*512)
+	}
+	if !strings.HasSuffix(idCtrl.SubsystemNQN(), cfg.SerialNumber) {
+		t.Errorf("subsystem NQN %q", idCtrl.SubsystemNQN())
+	}
+
+	if d.ModelInfo.SerialNumber != cfg.SerialNumber {
+		t.Errorf("serial number %q, want %q", d.ModelInfo.SerialNumber, cfg.SerialNumber)
+	}
+	if strings.TrimSpace(d.ModelInfo.ModelNumber) != cfg.ModelNumber {
+		t.Errorf("model number %q, want %q", d.ModelInfo.ModelNumber, cfg.ModelNumber)
+	}
+	if d.ModelInfo.OUI != 0x2538 {
+		t.Errorf("OUI %#x, want 0x2538", d.ModelInfo.OUI)
+	}
+}
+
+func TestIdentifyNamespaceLayout(t *testing.T) {
+	if size := binary.Size(nvme.NvmeIdentNamespace{}); size != 4096 {
+		t.Fatalf("Identify Namespace data structure of %d bytes, want 4096", size)
+	}
+
+	// 20 LBA formats, formatted with format 17 (FLBAS bits 6:5 hold the upper index bits), with
+	// the optimal performance fields at the offsets of NVM Command Set Specification 1.0c, figure 97
+	tr := &recordingTransport{
+		respond: func(cmd *nvme.Command) error {
+			b := cmd.Data
+			binary.LittleEndian.PutUint64(b[0:], 1000)
+			binary.LittleEndian.PutUint64(b[16:], 250)
+			b[24] = nvme.NSFEAT_OPT_PERF
+			b[25] = 19
+			b[26] = 1<<5 | nvme.FLBAS_EXTENDED_METADATA | 0x1
+			binary.LittleEndian.PutUint16(b[64:], 7)  // NPWG
+			binary.LittleEndian.PutUint16(b[72:], 31) // NOWS
+			lbaf := b[128+17*4:]
+			binary.LittleEndian.PutUint16(lbaf, 8)
+			lbaf[2] = 12
+			return nil
+		},
+	}
+
+	id, err := nvme.NewNVMeDeviceWithTransport("fake0", tr).IdentifyNamespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.admin[0].Nsid != 1 || tr.admin[0].Cdw10 != uint32(nvme.IDENTIFY_CNS_NSID) {
+		t.Errorf("identify command %+v", tr.admin[0])
+	}
+	if id.FormatIndex() != 17 || id.BlockSize() != 4096 || id.MetadataSize() != 8 || !id.ExtendedMetadata() {
+		t.Errorf("format %d, %d+%d bytes, extended %v", id.FormatIndex(), id.BlockSize(), id.MetadataSize(), id.ExtendedMetadata())
+	}
+	if id.SizeBytes() != 1000*4096 || id.UsedBytes() != 250*4096 {
+		t.Errorf("size %d used %d", id.SizeBytes(), id.UsedBytes())
+	}
+	if id.PreferredWriteGranularity() != 8 || id.OptimalWriteSize() != 32 {
+		t.Errorf("NPWG %d NOWS %d, want 8 32", id.PreferredWriteGranularity(), id.OptimalWriteSize())
+	}
+
+	// Without NSFEAT bit 4 the optimal performance fields are not reported
+	id.Nsfeat = 0
+	if id.PreferredWriteGranularity() != 0 {
+		t.Errorf("NPWG %d without OPTPERF", id.PreferredWriteGranularity())
+	}
+
+	// With 16 formats or fewer, FLBAS bits 6:5 are not part of the index
+	id.Nlbaf = 15
+	if id.FormatIndex() != 1 {
+		t.Errorf("format index %d, want 1", id.FormatIndex())
+	}
+	if (nvme.LbaFormat{Lbads: 0}).DataSize() != 0 {
+		t.Error("unsupported LBA format reports a data size")
+	}
+}
+
+func TestIdentifyNamespace(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Namespaces[0].FormatIndex = 1
+	d := newSimDevice(t, cfg)
+
+	id, err := d.IdentifyNamespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Nsze != cfg.Namespaces[0].Size || id.Ncap != id.Nsze || id.Nuse != 0 {
+		t.Errorf("NSZE %d NCAP %d NUSE %d, want %d %d 0", id.Nsze, id.Ncap, id.Nuse, cfg.Namespaces[0].Size, cfg.Namespaces[0].Size)
+	}
+	if id.Nlbaf != 1 || id.FormatIndex() != 1 || id.BlockSize() != 4096 || id.Lbaf[0].DataSize() != 512 {
+		t.Errorf("NLBAF %d, format %d, block size %d", id.Nlbaf, id.FormatIndex(), id.BlockSize())
+	}
+
+	if _, err := d.IdentifyNamespace(2); !nvme.IsInvalidNamespace(err) {
+		t.Errorf("missing namespace: got %v, want Invalid Namespace", err)
+	}
+}
@@ -1,10 +1,9 @@
 package nvme
 
 import (
+	"context"
 	"fmt"
-	"unsafe"
-
-	"github.com/AaronFei/go-nvme/ioctl"
+	"io"
 )
 
 const (
@@ -110,35 +109,166 @@ type LogPageCdw14 struct {
 }
 
 func (d *NVMeDevice) GetLogPageRaw(nsid, cdw10, cdw11, cdw12, cdw13, cdw14 uint32, buf []byte) error {
-	cmd := nvmePassthruCommand{
-		opcode:   NVME_ADMIN_GET_LOG_PAGE,
-		nsid:     nsid,
-		addr:     uint64(uintptr(unsafe.Pointer(&(buf)[0]))),
-		data_len: uint32(len(buf)),
-		cdw10:    cdw10,
-		cdw11:    cdw11,
-		cdw12:    cdw12,
-		cdw13:    cdw13,
-		cdw14:    cdw14,
+	cmd := Command{
+		Opcode: NVME_ADMIN_GET_LOG_PAGE,
+		Nsid:   nsid,
+		Data:   buf,
+		Cdw10:  cdw10,
+		Cdw11:  cdw11,
+		Cdw12:  cdw12,
+		Cdw13:  cdw13,
+		Cdw14:  cdw14,
+	}
+
+	return d.transport.SubmitAdmin(&cmd)
+}
+
+// LOG_PAGE_MAX_CHUNK caps the size of a single Get Log Page transfer made by GetLogPage.
+const LOG_PAGE_MAX_CHUNK = 256 * 1024
+
+// LogRequest describes a log page read by GetLogPage or StreamLogPage.
+type LogRequest struct {
+	Lid       uint8
+	Lsp       uint8  // Log Specific Parameter
+	Lsi       uint16 // Log Specific Identifier
+	Rae       bool   // Retain Asynchronous Event
+	UUIDIndex uint8
+	Csi       uint8 // Command Set Identifier
+	Nsid      uint32
+	Offset    uint64 // Byte offset into the log page, a multiple of 4
+	Length    int    // Number of bytes to read, a multiple of 4
+}
+
+// logPageChunkSize returns the largest Get Log Page transfer that respects MDTS, assuming a 4 KiB
+// minimum memory page size.
+func logPageChunkSize(idCtrl *NvmeIdentController) int {
+	chunk := LOG_PAGE_MAX_CHUNK
+	if idCtrl.Mdts != 0 && 4096<<idCtrl.Mdts < chunk {
+		chunk = 4096 << idCtrl.Mdts
+	}
+
+	return chunk
+}
+
+// GetLogPage reads req.Length bytes of a log page, split into transfers that respect MDTS.
+// Reading beyond the first transfer or at a non-zero offset needs the controller to support
+// extended Get Log Page data (LPA bit 2).
+func (d *NVMeDevice) GetLogPage(ctx context.Context, req LogRequest) ([]byte, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return nil, err
 	}
 
-	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_ADMIN_CMD, uintptr(unsafe.Pointer(&cmd)))
+	return d.getLogPage(ctx, &idCtrl, req)
 }
 
-func (d *NVMeDevice) readLogPage(logID uint8, buf []byte) error {
-	bufLen := len(buf)
+func (d *NVMeDevice) getLogPage(ctx context.Context, idCtrl *NvmeIdentController, req LogRequest) ([]byte, error) {
+	if err := checkLogRequest(idCtrl, &req); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, req.Length)
+	if err := d.readLogChunks(ctx, idCtrl, req, req.Offset+uint64(req.Length), buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
 
-	if (bufLen < 4) || (bufLen > 0x4000) || (bufLen%4 != 0) {
-		return fmt.Errorf("invalid buffer size")
+// StreamLogPage is GetLogPage writing the log page to w as it is read, so only one transfer is
+// held in memory at a time.
+func (d *NVMeDevice) StreamLogPage(ctx context.Context, req LogRequest, w io.Writer) error {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return err
 	}
 
-	cmd := nvmePassthruCommand{
-		opcode:   NVME_ADMIN_GET_LOG_PAGE,
-		nsid:     0xffffffff, // FIXME
-		addr:     uint64(uintptr(unsafe.Pointer(&(buf)[0]))),
-		data_len: uint32(bufLen),
-		cdw10:    uint32(logID) | (((uint32(bufLen) / 4) - 1) << 16),
+	return d.streamLogPage(ctx, &idCtrl, req, w)
+}
+
+func (d *NVMeDevice) streamLogPage(ctx context.Context, idCtrl *NvmeIdentController, req LogRequest, w io.Writer) error {
+	if err := checkLogRequest(idCtrl, &req); err != nil {
+		return err
+	}
+
+	end := req.Offset + uint64(req.Length)
+	buf := make([]byte, min(logPageChunkSize(idCtrl), req.Length))
+
+	for pos := 0; pos < req.Length; pos += len(buf) {
+		buf = buf[:min(cap(buf), req.Length-pos)]
+
+		r := req
+		r.Offset += uint64(pos)
+		if err := d.readLogChunks(ctx, idCtrl, r, end, buf); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkLogRequest(idCtrl *NvmeIdentController, req *LogRequest) error {
+	if req.Length < 4 || req.Length%4 != 0 || req.Offset%4 != 0 {
+		return fmt.Errorf("invalid log page length %d or offset %d", req.Length, req.Offset)
+	}
+
+	if (req.Offset != 0 || req.Length > logPageChunkSize(idCtrl)) && !idCtrl.HasLpa(LPA_EXTENDED_DATA) {
+		return fmt.Errorf("%w: extended log page data", ErrNotSupported)
+	}
+
+	return nil
+}
+
+// readLogChunks reads len(buf) bytes of the log page at req.Offset. end is the offset the whole
+// read stops at: unless req.Rae is set, only the transfer reaching it clears the asynchronous
+// event, so the log cannot change while it is being read.
+func (d *NVMeDevice) readLogChunks(ctx context.Context, idCtrl *NvmeIdentController, req LogRequest, end uint64, buf []byte) error {
+	chunk := logPageChunkSize(idCtrl)
+
+	for pos := 0; pos < len(buf); pos += chunk {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := min(chunk, len(buf)-pos)
+		numd := uint32(n/4 - 1)
+		lpo := req.Offset + uint64(pos)
+
+		var rae uint32
+		if req.Rae || lpo+uint64(n) < end {
+			rae = 1
+		}
+
+		cmd := Command{
+			Opcode: NVME_ADMIN_GET_LOG_PAGE,
+			Nsid:   req.Nsid,
+			Data:   buf[pos : pos+n],
+			Cdw10: buildCdw(LogPageCdw10BitInfo, LogPageCdw10{
+				LID:   uint32(req.Lid),
+				LSP:   uint32(req.Lsp & 0x7f),
+				RAE:   rae,
+				NUMDL: numd & 0xffff,
+			}),
+			Cdw11: buildCdw(LogPageCdw11BitInfo, LogPageCdw11{
+				NUMDU: numd >> 16,
+				LSID:  uint32(req.Lsi),
+			}),
+			Cdw12: buildCdw(LogPageCdw12BitInfo, LogPageCdw12{LPOL: uint32(lpo)}),
+			Cdw13: buildCdw(LogPageCdw13BitInfo, LogPageCdw13{LPOU: uint32(lpo >> 32)}),
+			Cdw14: buildCdw(LogPageCdw14BitInfo, LogPageCdw14{
+				UUID: uint32(req.UUIDIndex & 0x7f),
+				CSI:  uint32(req.Csi),
+			}),
+		}
+
+		if err := d.transport.SubmitAdmin(&cmd); err != nil {
+			return fmt.Errorf("log page %#x at offset %d: %w", req.Lid, lpo, err)
+		}
 	}
 
-	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_ADMIN_CMD, uintptr(unsafe.Pointer(&cmd)))
+	return nil
 }
@@ -0,0 +1,257 @@
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Log page descriptor of the Supported Log Pages log
+	SUPPORTED_LOG_LSUPP uint32 = 1 << 0 // Log page supported
+	SUPPORTED_LOG_IOS   uint32 = 1 << 1 // Index offset supported
+
+	// Commands Supported and Effects data structure
+	CMD_EFFECTS_CSUPP uint32 = 1 << 0  // Command supported
+	CMD_EFFECTS_LBCC  uint32 = 1 << 1  // Logical block content change
+	CMD_EFFECTS_NCC   uint32 = 1 << 2  // Namespace capability change
+	CMD_EFFECTS_NIC   uint32 = 1 << 3  // Namespace inventory change
+	CMD_EFFECTS_CCC   uint32 = 1 << 4  // Controller capability change
+	CMD_EFFECTS_UUID  uint32 = 1 << 19 // UUID selection supported
+
+	// Command Submission and Execution, bits 18:16
+	CMD_EFFECTS_CSE_NONE uint8 = 0x0
+	CMD_EFFECTS_CSE_NS   uint8 = 0x1 // Serialized with other commands to the same namespace
+	CMD_EFFECTS_CSE_ALL  uint8 = 0x2 // Serialized with all other commands
+)
+
+// LogPageSupportedLogs is the Supported Log Pages log page, one descriptor per log page ID.
+type LogPageSupportedLogs struct {
+	Lids [256]uint32
+} // 1024 bytes
+
+func (l *LogPageSupportedLogs) Supported(lid uint8) bool {
+	return l.Lids[lid]&SUPPORTED_LOG_LSUPP != 0
+}
+
+// IndexOffsetSupported reports whether log page lid may be read with an index offset (OT=1).
+func (l *LogPageSupportedLogs) IndexOffsetSupported(lid uint8) bool {
+	return l.Lids[lid]&SUPPORTED_LOG_IOS != 0
+}
+
+// CommandEffects describes the support and effects of one command.
+type CommandEffects uint32
+
+func (e CommandEffects) Has(mask uint32) bool {
+	return uint32(e)&mask == mask
+}
+
+func (e CommandEffects) Supported() bool {
+	return e.Has(CMD_EFFECTS_CSUPP)
+}
+
+// ChangesLBAContent reports whether the command may change logical block content.
+func (e CommandEffects) ChangesLBAContent() bool {
+	return e.Has(CMD_EFFECTS_LBCC)
+}
+
+// ChangesNamespaces reports whether the command may change namespace capabilities or the
+// namespace inventory, requiring the namespaces to be re-identified.
+func (e CommandEffects) ChangesNamespaces() bool {
+	return uint32(e)&(CMD_EFFECTS_NCC|CMD_EFFECTS_NIC) != 0
+}
+
+// ChangesController reports whether the command may change controller capabilities.
+func (e CommandEffects) ChangesController() bool {
+	return e.Has(CMD_EFFECTS_CCC)
+}
+
+// Serialization returns the submission and execution restriction, CMD_EFFECTS_CSE_*.
+func (e CommandEffects) Serialization() uint8 {
+	return uint8(getBitsValue(uint64(e), 16, 18))
+}
+
+// LogPageCmdEffects is the Commands Supported and Effects log page, indexed by opcode.
+type LogPageCmdEffects struct {
+	Admin    [256]CommandEffects
+	Rsvd1024 [1024]byte
+	IO       [256]CommandEffects
+	Rsvd3072 [1024]byte
+} // 4096 bytes
+
+// GetSupportedLogPages reads the Supported Log Pages log page.
+func (d *NVMeDevice) GetSupportedLogPages() (LogPageSupportedLogs, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return LogPageSupportedLogs{}, err
+	}
+
+	return d.getSupportedLogPages(&idCtrl)
+}
+
+func (d *NVMeDevice) getSupportedLogPages(idCtrl *NvmeIdentController) (LogPageSupportedLogs, error) {
+	if !idCtrl.HasLpa(LPA_SUPPORTED_LOGS) {
+		return LogPageSupportedLogs{}, fmt.Errorf("%w: supported log pages log", ErrNotSupported)
+	}
+
+	buf := make([]byte, 1024)
+
+	cdw10 := buildCdw(LogPageCdw10BitInfo, LogPageCdw10{
+		LID:   uint32(LOGPAGE_SUPPORTED_LOG_PAGES),
+		NUMDL: ((uint32(len(buf)) / 4) - 1),
+	})
+
+	if err := d.GetLogPageRaw(0, cdw10, 0, 0, 0, 0, buf); err != nil {
+		return LogPageSupportedLogs{}, err
+	}
+
+	var l LogPageSupportedLogs
+	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &l)
+
+	return l, nil
+}
+
+// GetCommandEffects reads the Commands Supported and Effects log page of the NVM Command Set.
+func (d *NVMeDevice) GetCommandEffects() (LogPageCmdEffects, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return LogPageCmdEffects{}, err
+	}
+
+	return d.getCommandEffects(&idCtrl)
+}
+
+func (d *NVMeDevice) getCommandEffects(idCtrl *NvmeIdentController) (LogPageCmdEffects, error) {
+	if !idCtrl.HasLpa(LPA_CMD_EFFECTS) {
+		return LogPageCmdEffects{}, fmt.Errorf("%w: commands supported and effects log", ErrNotSupported)
+	}
+
+	buf := make([]byte, 4096)
+
+	cdw10 := buildCdw(LogPageCdw10BitInfo, LogPageCdw10{
+		LID:   uint32(LOGPAGE_CMD_SUPPORTED_EFFECTS),
+		NUMDL: ((uint32(len(buf)) / 4) - 1),
+	})
+
+	if err := d.GetLogPageRaw(0, cdw10, 0, 0, 0, 0, buf); err != nil {
+		return LogPageCmdEffects{}, err
+	}
+
+	var l LogPageCmdEffects
+	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &l)
+
+	return l, nil
+}
+
+// loadSupportInfo reads the Supported Log Pages and Commands Supported and Effects logs once per
+// device. A log the controller does not provide is left nil until resetSupportInfo. If a read
+// fails otherwise, e.g. while a sanitize operation restricts log page access, the log is read
+// again by the next check; until then the checks let the command be attempted.
+func (d *NVMeDevice) loadSupportInfo() {
+	if d.supportLoaded {
+		return
+	}
+
+	idCtrl, err := d.cachedIdentifyController()
+	if err != nil {
+		return
+	}
+
+	loaded := true
+
+	if d.supportedLogs == nil {
+		l, err := d.getSupportedLogPages(idCtrl)
+		if err == nil {
+			d.supportedLogs = &l
+		} else if !errors.Is(err, ErrNotSupported) {
+			loaded = false
+		}
+	}
+
+	if d.cmdEffects == nil {
+		e, err := d.getCommandEffects(idCtrl)
+		if err == nil {
+			d.cmdEffects = &e
+		} else if !errors.Is(err, ErrNotSupported) {
+			loaded = false
+		}
+	}
+
+	d.supportLoaded = loaded
+}
+
+// resetSupportInfo discards the cached support information and Identify Controller data, e.g.
+// after new firmware was activated.
+func (d *NVMeDevice) resetSupportInfo() {
+	d.idCtrl = nil
+	d.supportLoaded = false
+	d.supportedLogs = nil
+	d.cmdEffects = nil
+}
+
+// SupportsLogPage reports whether the controller supports log page lid. known is false if the
+// controller does not provide the Supported Log Pages log, in which case supported is meaningless.
+func (d *NVMeDevice) SupportsLogPage(lid uint8) (supported, known bool) {
+	d.loadSupportInfo()
+
+	if d.supportedLogs == nil {
+		return false, false
+	}
+
+	return d.supportedLogs.Supported(lid), true
+}
+
+// AdminCommandEffects returns the support and effects of the admin command opcode. known is false
+// if the controller does not provide the Commands Supported and Effects log.
+func (d *NVMeDevice) AdminCommandEffects(opcode uint8) (effects CommandEffects, known bool) {
+	d.loadSupportInfo()
+
+	if d.cmdEffects == nil {
+		return 0, false
+	}
+
+	return d.cmdEffects.Admin[opcode], true
+}
+
+// IOCommandEffects returns the support and effects of the I/O command opcode. known is false if
+// the controller does not provide the Commands Supported and Effects log.
+func (d *NVMeDevice) IOCommandEffects(opcode uint8) (effects CommandEffects, known bool) {
+	d.loadSupportInfo()
+
+	if d.cmdEffects == nil {
+		return 0, false
+	}
+
+	return d.cmdEffects.IO[opcode], true
+}
+
+// checkLogPage returns an error wrapping ErrNotSupported if the controller reports log page lid as
+// unsupported. Without the Supported Log Pages log the read is attempted.
+func (d *NVMeDevice) checkLogPage(lid uint8, what string) error {
+	if supported, known := d.SupportsLogPage(lid); known && !supported {
+		return fmt.Errorf("%w: %s", ErrNotSupported, what)
+	}
+
+	return nil
+}
+
+// checkAdminCommand returns an error wrapping ErrNotSupported if the controller reports the admin
+// command opcode as unsupported.
+func (d *NVMeDevice) checkAdminCommand(opcode uint8, what string) error {
+	if e, known := d.AdminCommandEffects(opcode); known && !e.Supported() {
+		return fmt.Errorf("%w: %s", ErrNotSupported, what)
+	}
+
+	return nil
+}
+
+// checkIOCommand returns an error wrapping ErrNotSupported if the controller reports the I/O
+// command opcode as unsupported.
+func (d *NVMeDevice) checkIOCommand(opcode uint8, what string) error {
+	if e, known := d.IOCommandEffects(opcode); known && !e.Supported() {
+		return fmt.Errorf("%w: %s", ErrNotSupported, what)
+	}
+
+	return nil
+}
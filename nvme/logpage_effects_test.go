@@ -0,0 +1,140 @@
+package nvme_test
+
+import (
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestSupportInfo(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	l, err := d.GetSupportedLogPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.Supported(nvme.LOGPAGE_SMART_HEALTH_INFO) || l.Supported(nvme.LOGPAGE_TELEMETRY_HOST) {
+		t.Error("supported log pages do not match the configuration")
+	}
+
+	if supported, known := d.SupportsLogPage(nvme.LOGPAGE_DEVICE_SELF_TEST); !supported || !known {
+		t.Errorf("self-test log: supported %v known %v", supported, known)
+	}
+	if supported, known := d.SupportsLogPage(nvme.LOGPAGE_SANITIZE_STATUS); supported || !known {
+		t.Errorf("sanitize status log: supported %v known %v", supported, known)
+	}
+
+	e, known := d.AdminCommandEffects(nvme.NVME_ADMIN_FORMAT_NVM)
+	if !known || !e.Supported() || !e.ChangesLBAContent() || !e.ChangesNamespaces() || e.Serialization() != nvme.CMD_EFFECTS_CSE_ALL {
+		t.Errorf("Format NVM effects %#x, known %v", uint32(e), known)
+	}
+	if e, known := d.IOCommandEffects(nvme.NVME_NVM_CMD_COPY); !known || e.Supported() {
+		t.Errorf("Copy effects %#x, known %v, want unsupported", uint32(e), known)
+	}
+
+	if _, err := d.GetSanitizeStatus(); err == nil {
+		t.Error("unsupported sanitize status log was read")
+	}
+}
+
+// logFailTransport is a simulated controller that fails reads of the Supported Log Pages and
+// Commands Supported and Effects logs while fail is set, and does not report them in LPA if
+// noLogs is set.
+type logFailTransport struct {
+	*countingTransport
+	logs   map[uint8]int
+	fail   bool
+	noLogs bool
+}
+
+func newLogFailDevice(t *testing.T) (*nvme.NVMeDevice, *logFailTransport) {
+	t.Helper()
+
+	tr := &logFailTransport{
+		countingTransport: &countingTransport{Controller: nvmesim.New(nvmesim.DefaultConfig()), admin: make(map[uint8]int)},
+		logs:              make(map[uint8]int),
+	}
+	d := nvme.NewNVMeDeviceWithTransport("sim0", tr)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	return d, tr
+}
+
+func (t *logFailTransport) SubmitAdmin(cmd *nvme.Command) error {
+	if cmd.Opcode == nvme.NVME_ADMIN_GET_LOG_PAGE {
+		lid := uint8(cmd.Cdw10)
+		t.logs[lid]++
+		if t.fail && (lid == nvme.LOGPAGE_SUPPORTED_LOG_PAGES || lid == nvme.LOGPAGE_CMD_SUPPORTED_EFFECTS) {
+			return &nvme.StatusError{SCT: nvme.NVME_SCT_GENERIC, SC: nvme.NVME_SC_SANITIZE_IN_PROGRESS}
+		}
+	}
+
+	if err := t.countingTransport.SubmitAdmin(cmd); err != nil {
+		return err
+	}
+
+	// LPA is byte 261 of the Identify Controller data structure
+	if t.noLogs && cmd.Opcode == nvme.NVME_ADMIN_IDENTIFY && uint8(cmd.Cdw10) == nvme.IDENTIFY_CNS_CTRL {
+		cmd.Data[261] &^= nvme.LPA_SUPPORTED_LOGS | nvme.LPA_CMD_EFFECTS
+	}
+
+	return nil
+}
+
+func TestSupportInfoFailureRetried(t *testing.T) {
+	d, tr := newLogFailDevice(t)
+	tr.fail = true
+
+	for i := 0; i < 3; i++ {
+		// Without support information the log page is read anyway
+		if _, err := d.GetSelfTestLog(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, known := d.SupportsLogPage(nvme.LOGPAGE_DEVICE_SELF_TEST); known {
+		t.Error("support reported as known")
+	}
+	if n := tr.logs[nvme.LOGPAGE_SUPPORTED_LOG_PAGES]; n != 4 {
+		t.Errorf("Supported Log Pages read %d times, want once per check", n)
+	}
+
+	// Once the logs can be read they are cached
+	tr.fail = false
+	for i := 0; i < 3; i++ {
+		if supported, known := d.SupportsLogPage(nvme.LOGPAGE_DEVICE_SELF_TEST); !supported || !known {
+			t.Fatalf("self-test log: supported %v known %v", supported, known)
+		}
+	}
+	if n := tr.logs[nvme.LOGPAGE_SUPPORTED_LOG_PAGES]; n != 5 {
+		t.Errorf("Supported Log Pages read %d times, want 5", n)
+	}
+	if n := tr.logs[nvme.LOGPAGE_CMD_SUPPORTED_EFFECTS]; n != 5 {
+		t.Errorf("Commands Supported and Effects read %d times, want 5", n)
+	}
+}
+
+func TestSupportInfoNotSupportedCached(t *testing.T) {
+	d, tr := newLogFailDevice(t)
+	tr.noLogs = true
+
+	for i := 0; i < 3; i++ {
+		if _, known := d.SupportsLogPage(nvme.LOGPAGE_DEVICE_SELF_TEST); known {
+			t.Fatal("support reported as known")
+		}
+		if _, known := d.AdminCommandEffects(nvme.NVME_ADMIN_FORMAT_NVM); known {
+			t.Fatal("effects reported as known")
+		}
+	}
+
+	if n := tr.admin[nvme.NVME_ADMIN_IDENTIFY]; n != 1 {
+		t.Errorf("%d Identify commands, want 1", n)
+	}
+	if len(tr.logs) != 0 {
+		t.Errorf("log pages read: %v", tr.logs)
+	}
+}
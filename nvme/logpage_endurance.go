@@ -0,0 +1,57 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// LogPageEnduranceGroup is the Endurance Group Information log page (LID 09h).
+type LogPageEnduranceGroup struct {
+	CritWarning       CriticalWarning // Spare, reliability and read-only bits only
+	Egfeat            uint8           // Endurance Group Features
+	Rsvd2             uint8
+	AvailSpare        uint8 // Percent
+	AvailSpareThresh  uint8 // Percent
+	PercentUsed       uint8 // Percent of the rated endurance, may exceed 100
+	DomainID          uint16
+	Rsvd8             [24]byte
+	EnduranceEstimate Uint128 // In units of 1,000,000,000 bytes
+	DataUnitsRead     Uint128 // In units of SMART_DATA_UNIT bytes
+	DataUnitsWritten  Uint128
+	MediaUnitsWritten Uint128 // In units of 1,000,000,000 bytes
+	HostReads         Uint128
+	HostWrites        Uint128
+	MediaErrors       Uint128
+	NumErrLogEntries  Uint128
+	TotalEgCap        Uint128 // Total Endurance Group Capacity, in bytes
+	UnallocEgCap      Uint128 // Unallocated Endurance Group Capacity, in bytes
+	Rsvd192           [320]byte
+} // 512 bytes
+
+// GetEnduranceGroupLog reads the Endurance Group Information log of endurance group egid.
+func (d *NVMeDevice) GetEnduranceGroupLog(ctx context.Context, egid uint16) (LogPageEnduranceGroup, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return LogPageEnduranceGroup{}, err
+	}
+
+	if !idCtrl.HasCtratt(CTRATT_ENDURANCE_GROUPS) {
+		return LogPageEnduranceGroup{}, fmt.Errorf("%w: endurance groups", ErrNotSupported)
+	}
+
+	if err := d.checkLogPage(LOGPAGE_ENDURANCE_GROUP_INFO, "endurance group information log"); err != nil {
+		return LogPageEnduranceGroup{}, err
+	}
+
+	buf, err := d.GetLogPage(ctx, LogRequest{Lid: LOGPAGE_ENDURANCE_GROUP_INFO, Lsi: egid, Length: 512})
+	if err != nil {
+		return LogPageEnduranceGroup{}, err
+	}
+
+	var l LogPageEnduranceGroup
+	binary.Read(bytes.NewBuffer(buf), NativeEndian, &l)
+
+	return l, nil
+}
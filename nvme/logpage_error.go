@@ -0,0 +1,91 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ErrorLogEntry is one entry of the Error Information log page.
+type ErrorLogEntry struct {
+	ErrorCount  uint64 // Unique, incrementing identifier of the error, 0 for an empty entry
+	Sqid        uint16 // Submission Queue ID, 0 for the admin queue
+	Cid         uint16 // Command ID
+	StatusField uint16 // Status Field of the completion, including the phase tag in bit 0
+	ParamErrLoc uint16 // Parameter Error Location, 0xffff if not applicable
+	Lba         uint64 // First LBA that experienced the error
+	Nsid        uint32
+	Vsia        uint8 // Log page ID of vendor specific information, 0 if none
+	Trtype      uint8 // Transport Type
+	Rsvd30      [2]byte
+	Csi         uint64 // Command Specific Information
+	Ttsi        uint16 // Transport Type Specific Information
+	Rsvd42      [22]byte
+} // 64 bytes
+
+// Status decodes the status of the failed command.
+func (e *ErrorLogEntry) Status() *StatusError {
+	return NewStatusError(e.StatusField>>1, 0)
+}
+
+// ParamErrorLocation returns the byte and bit within the command that caused the error. ok is
+// false if the controller did not report a location.
+func (e *ErrorLogEntry) ParamErrorLocation() (byteOffset uint8, bit uint8, ok bool) {
+	if e.ParamErrLoc == 0xffff {
+		return 0, 0, false
+	}
+
+	return uint8(getBitsValue(uint64(e.ParamErrLoc), 0, 7)), uint8(getBitsValue(uint64(e.ParamErrLoc), 8, 10)), true
+}
+
+// GetErrorLog reads the Error Information log and returns its non-empty entries, which the
+// controller reports newest first. Up to ELPE+1 entries are read, or as many as fit in a single
+// transfer if the controller does not support extended log page data.
+func (d *NVMeDevice) GetErrorLog() ([]ErrorLogEntry, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return nil, err
+	}
+
+	n := int(idCtrl.Elpe) + 1
+	if !idCtrl.HasLpa(LPA_EXTENDED_DATA) {
+		n = min(n, logPageChunkSize(&idCtrl)/64)
+	}
+
+	buf, err := d.getLogPage(context.Background(), &idCtrl, LogRequest{Lid: LOGPAGE_ERROR_INFO, Length: 64 * n})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]ErrorLogEntry, n)
+	if err := binary.Read(bytes.NewReader(buf), NativeEndian, entries); err != nil {
+		return nil, err
+	}
+
+	used := entries[:0]
+	for _, e := range entries {
+		if e.ErrorCount != 0 {
+			used = append(used, e)
+		}
+	}
+
+	return used, nil
+}
+
+func (d *NVMeDevice) PrintErrorLog(w io.Writer) error {
+	entries, err := d.GetErrorLog()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nError information log follows (%d entries):\n", len(entries))
+
+	for _, e := range entries {
+		fmt.Fprintf(w, "Error count %d: SQID %d, CID %#x, NSID %#x, LBA %d: %s\n",
+			e.ErrorCount, e.Sqid, e.Cid, e.Nsid, e.Lba, e.Status())
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package nvme_test
+
+import (
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestGetErrorLog(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	entries, err := d.GetErrorLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("%d entries in a fresh error log", len(entries))
+	}
+
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 512)
+	if err := n.Read(n.Ident.Nsze, 1, buf); !nvme.IsLbaOutOfRange(err) {
+		t.Fatalf("read beyond the namespace: got %v, want LBA Out of Range", err)
+	}
+	if _, err := d.GetFeature(0xfe, nvme.FEATURE_SEL_CURRENT, 0, 0, nil); !nvme.IsInvalidField(err) {
+		t.Fatalf("get unsupported feature: got %v, want Invalid Field", err)
+	}
+
+	entries, err = d.GetErrorLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+
+	// Newest first
+	admin, io := entries[0], entries[1]
+	if admin.ErrorCount != 2 || admin.Sqid != 0 || !nvme.IsInvalidField(admin.Status()) {
+		t.Errorf("admin entry: count %d SQID %d status %v", admin.ErrorCount, admin.Sqid, admin.Status())
+	}
+	if io.ErrorCount != 1 || io.Sqid == 0 || io.Nsid != 1 || io.Lba != n.Ident.Nsze || !nvme.IsLbaOutOfRange(io.Status()) {
+		t.Errorf("I/O entry: count %d SQID %d NSID %d LBA %d status %v", io.ErrorCount, io.Sqid, io.Nsid, io.Lba, io.Status())
+	}
+}
+
+func TestGetErrorLogChunked(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.ErrorLogEntries = 255 // 16 KiB log
+	cfg.Mdts = 1              // 8 KiB transfers
+	d, tr := newCountingDevice(t, cfg)
+
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 512)
+	for i := 0; i < 200; i++ {
+		if err := n.Read(n.Ident.Nsze+uint64(i), 1, buf); !nvme.IsLbaOutOfRange(err) {
+			t.Fatalf("got %v, want LBA Out of Range", err)
+		}
+	}
+
+	tr.admin[nvme.NVME_ADMIN_GET_LOG_PAGE] = 0
+
+	entries, err := d.GetErrorLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.admin[nvme.NVME_ADMIN_GET_LOG_PAGE] != 2 {
+		t.Errorf("%d Get Log Page commands, want 2", tr.admin[nvme.NVME_ADMIN_GET_LOG_PAGE])
+	}
+	if len(entries) != 200 {
+		t.Fatalf("%d entries, want 200", len(entries))
+	}
+
+	for i, e := range entries {
+		if e.ErrorCount != uint64(200-i) || e.Lba != n.Ident.Nsze+uint64(199-i) {
+			t.Fatalf("entry %d: count %d LBA %d", i, e.ErrorCount, e.Lba)
+		}
+		if _, _, ok := e.ParamErrorLocation(); ok {
+			t.Fatalf("entry %d reports a parameter error location", i)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"strings"
 )
 
 type LogPageFwSlotInfo struct {
@@ -26,29 +28,94 @@ func (d *NVMeDevice) GetLogPageFwSlotInfo(buf []byte) error {
 	return nil
 }
 
-func (d *NVMeDevice) PrintFwSlotInfo() error {
-	buf := make([]byte, 512)
+// FirmwareSlot describes the image held by one firmware slot.
+type FirmwareSlot struct {
+	Slot     uint8
+	Revision string
+	Empty    bool // No image is present in the slot
+}
+
+// FirmwareSlotInfo is the decoded Firmware Slot Information log together with the firmware update
+// capabilities from Identify Controller.
+type FirmwareSlotInfo struct {
+	ActiveSlot    uint8 // Slot of the running firmware
+	NextSlot      uint8 // Slot activated at the next reset, 0 if not set
+	NumSlots      uint8
+	Slot1ReadOnly bool
+	Slots         []FirmwareSlot // NumSlots entries, starting with slot 1
+}
+
+// ActiveRevision returns the revision of the running firmware.
+func (f *FirmwareSlotInfo) ActiveRevision() string {
+	if f.ActiveSlot == 0 || int(f.ActiveSlot) > len(f.Slots) {
+		return ""
+	}
+
+	return f.Slots[f.ActiveSlot-1].Revision
+}
+
+// GetFirmwareSlots reads the Firmware Slot Information log.
+func (d *NVMeDevice) GetFirmwareSlots() (FirmwareSlotInfo, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return FirmwareSlotInfo{}, err
+	}
 
-	// Read SMART log
+	buf := make([]byte, 512)
 	if err := d.GetLogPageFwSlotInfo(buf); err != nil {
-		return err
+		return FirmwareSlotInfo{}, err
 	}
 
 	var sl LogPageFwSlotInfo
-
 	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &sl)
 
-	fmt.Printf("\nActive Firmware Slot follows:\n")
+	info := FirmwareSlotInfo{
+		ActiveSlot:    uint8(getBitsValue(uint64(sl.AFI), 0, 2)),
+		NextSlot:      uint8(getBitsValue(uint64(sl.AFI), 4, 6)),
+		NumSlots:      idCtrl.FirmwareSlots(),
+		Slot1ReadOnly: idCtrl.FirmwareSlot1ReadOnly(),
+	}
 
-	slotNum := (sl.AFI & 0x7)
-	if slotNum == 0 {
-		fmt.Println("Active Firmware Info: Invalid")
-	} else {
-		fmt.Printf("Active Firmware Info: %#02b\n", sl.AFI)
-		fmt.Printf("Firmware current Revision: %s\n", string(sl.FWRevision[slotNum-1][:]))
-		for i := 0; i < 7; i++ {
-			fmt.Printf("Firmware Revision for slot %d: %s\n", i+1, string(sl.FWRevision[i][:]))
+	for i := 0; i < int(info.NumSlots) && i < len(sl.FWRevision); i++ {
+		rev := strings.TrimRight(string(sl.FWRevision[i][:]), " \x00")
+		info.Slots = append(info.Slots, FirmwareSlot{
+			Slot:     uint8(i + 1),
+			Revision: rev,
+			Empty:    rev == "",
+		})
+	}
+
+	return info, nil
+}
+
+func (d *NVMeDevice) PrintFwSlotInfo(w io.Writer) error {
+	info, err := d.GetFirmwareSlots()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nFirmware slot info follows:\n")
+
+	if info.ActiveSlot == 0 {
+		fmt.Fprintln(w, "Active firmware slot: Invalid")
+		return nil
+	}
+
+	fmt.Fprintf(w, "Active firmware slot: %d\n", info.ActiveSlot)
+	if info.NextSlot != 0 {
+		fmt.Fprintf(w, "Next reset firmware slot: %d\n", info.NextSlot)
+	}
+	fmt.Fprintf(w, "Firmware current revision: %s\n", info.ActiveRevision())
+
+	for _, slot := range info.Slots {
+		rev := slot.Revision
+		if slot.Empty {
+			rev = "<empty>"
+		}
+		if slot.Slot == 1 && info.Slot1ReadOnly {
+			rev += " (read only)"
 		}
+		fmt.Fprintf(w, "Firmware revision for slot %d: %s\n", slot.Slot, rev)
 	}
 
 	return nil
@@ -0,0 +1,60 @@
+package nvme_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestGetFirmwareSlots(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.FirmwareSlots = 3
+	cfg.Slot1ReadOnly = true
+	d := newSimDevice(t, cfg)
+
+	info, err := d.GetFirmwareSlots()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.ActiveSlot != 1 || info.NextSlot != 0 || info.NumSlots != 3 || !info.Slot1ReadOnly {
+		t.Errorf("active %d next %d slots %d slot 1 read-only %v, want 1 0 3 true",
+			info.ActiveSlot, info.NextSlot, info.NumSlots, info.Slot1ReadOnly)
+	}
+	if info.ActiveRevision() != cfg.FirmwareRevision {
+		t.Errorf("active revision %q, want %q", info.ActiveRevision(), cfg.FirmwareRevision)
+	}
+	if len(info.Slots) != 3 || info.Slots[0].Empty || !info.Slots[1].Empty || !info.Slots[2].Empty {
+		t.Errorf("slots %+v, want only slot 1 populated", info.Slots)
+	}
+}
+
+func TestPrintFwSlotInfo(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Slot1ReadOnly = true
+	d := newSimDevice(t, cfg)
+
+	if _, err := d.UpdateFirmware(firmwareImage("1.1", 4096), nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_REPLACE_ACTIVATE}); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := d.PrintFwSlotInfo(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"Active firmware slot: 1\n",
+		"Next reset firmware slot: 2\n",
+		"Firmware current revision: 1.0\n",
+		"Firmware revision for slot 1: 1.0 (read only)\n",
+		"Firmware revision for slot 2: 1.1\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// Management Action, LSP bits 1:0 of the Persistent Event log
+	PEL_ACTION_READ      uint8 = 0x0
+	PEL_ACTION_ESTABLISH uint8 = 0x1 // Establish a reporting context and read the log
+	PEL_ACTION_RELEASE   uint8 = 0x2 // Release the reporting context
+
+	// Persistent Event Types
+	PEL_EVENT_SMART_SNAPSHOT      uint8 = 0x01
+	PEL_EVENT_FW_COMMIT           uint8 = 0x02
+	PEL_EVENT_TIMESTAMP_CHANGE    uint8 = 0x03
+	PEL_EVENT_POWER_ON_RESET      uint8 = 0x04
+	PEL_EVENT_HW_ERROR            uint8 = 0x05
+	PEL_EVENT_CHANGE_NS           uint8 = 0x06
+	PEL_EVENT_FORMAT_START        uint8 = 0x07
+	PEL_EVENT_FORMAT_COMPLETION   uint8 = 0x08
+	PEL_EVENT_SANITIZE_START      uint8 = 0x09
+	PEL_EVENT_SANITIZE_COMPLETION uint8 = 0x0a
+	PEL_EVENT_SET_FEATURE         uint8 = 0x0b
+	PEL_EVENT_TELEMETRY_CREATED   uint8 = 0x0c
+	PEL_EVENT_THERMAL_EXCURSION   uint8 = 0x0d
+	PEL_EVENT_VENDOR              uint8 = 0xde
+	PEL_EVENT_TCG                 uint8 = 0xdf
+
+	// PEL_HEADER_SIZE is the size of the log header; events follow it
+	PEL_HEADER_SIZE = 512
+)
+
+// PersistentEventLogHeader is the header of the Persistent Event log page.
+type PersistentEventLogHeader struct {
+	Lid          uint8
+	Rsvd1        [3]byte
+	Tnev         uint32 // Total Number of Events
+	Tll          uint64 // Total Log Length in bytes, including the header
+	Lrev         uint8  // Log Revision
+	Rsvd17       uint8
+	Lhl          uint16 // Log Header Length
+	Timestamp    uint64
+	Poh          Uint128 // Power on hours
+	PowerCycles  uint64
+	VendorID     uint16
+	Ssvid        uint16
+	SerialNumber [20]byte
+	ModelNumber  [40]byte
+	Subnqn       [256]byte
+	Gennum       uint16 // Generation Number
+	Rci          uint32 // Reporting Context Information
+	Rsvd378      [102]byte
+	Seb          [32]byte // Supported Events Bitmap
+} // 512 bytes
+
+// SupportsEvent reports whether the controller may log events of type t.
+func (h *PersistentEventLogHeader) SupportsEvent(t uint8) bool {
+	return h.Seb[t/8]&(1<<(t%8)) != 0
+}
+
+// PersistentEvent is one event of the Persistent Event log. Event holds the decoded event data for
+// the event types defined by the specification, e.g. *PelFirmwareCommitEvent, and is nil for
+// vendor specific and unknown types.
+type PersistentEvent struct {
+	Type         uint8
+	TypeRevision uint8
+	Ehai         uint8  // Event Header Additional Info
+	ControllerID uint16 // Controller that logged the event
+	Timestamp    uint64 // Event Timestamp, in the format of the Timestamp feature
+	PortID       uint16
+	VendorInfo   []byte
+	Data         []byte
+	Event        any
+}
+
+// TimestampMs returns the milliseconds part of the event timestamp.
+func (e *PersistentEvent) TimestampMs() uint64 {
+	return getBitsValue(e.Timestamp, 0, 47)
+}
+
+// PelSmartSnapshotEvent holds the SMART / Health Information log at the time of the event.
+type PelSmartSnapshotEvent struct {
+	SMARTHealth
+}
+
+type PelFirmwareCommitEvent struct {
+	OldFirmware  [8]byte
+	NewFirmware  [8]byte
+	CommitAction uint8
+	Slot         uint8
+	Sct          uint8 // Status of the Firmware Commit command
+	Sc           uint8
+	VendorResult uint16
+}
+
+type PelTimestampChangeEvent struct {
+	PreviousTimestamp uint64
+	MlSecsSinceReset  uint64 // Milliseconds Since Reset, the time since the last controller reset
+}
+
+type PelPowerOnResetDescriptor struct {
+	ControllerID     uint16
+	FwActivation     uint8 // Bit 0 is set if firmware was activated by the reset
+	OpInProgress     uint8 // Operation in progress at the time of the reset
+	Rsvd4            [12]byte
+	CtrlPowerCycles  uint32
+	PowerOnMlSecs    uint64
+	ControllerTimeMs uint64
+} // 36 bytes
+
+type PelPowerOnResetEvent struct {
+	Firmware    [8]byte
+	Descriptors []PelPowerOnResetDescriptor
+}
+
+type PelHardwareErrorEvent struct {
+	Code           uint16 // NVM Subsystem Hardware Error Event Code
+	AdditionalInfo []byte
+}
+
+type PelChangeNamespaceEvent struct {
+	NsMgmtCdw10 uint32
+	Rsvd4       [4]byte
+	Nsze        uint64
+	Rsvd16      [8]byte
+	Ncap        uint64
+	Flbas       uint8
+	Dps         uint8
+	Nmic        uint8
+	Rsvd35      uint8
+	Anagrpid    uint32
+	Nvmsetid    uint16
+	Rsvd42      uint16
+	Nsid        uint32
+}
+
+type PelFormatStartEvent struct {
+	Nsid        uint32
+	Fna         uint8
+	Rsvd5       [3]byte
+	FormatCdw10 uint32
+}
+
+type PelFormatCompletionEvent struct {
+	Nsid          uint32
+	SmallestFpi   uint8 // Smallest Format Progress Indicator
+	FormatStatus  uint8 // Bit 0 is set if the format failed
+	CompletionInf uint16
+	StatusField   uint32
+}
+
+type PelSanitizeStartEvent struct {
+	Sanicap       uint32
+	SanitizeCdw10 uint32
+	SanitizeCdw11 uint32
+}
+
+type PelSanitizeCompletionEvent struct {
+	Sprog          uint16
+	Sstat          uint16
+	CompletionInfo uint16
+	Rsvd6          [2]byte
+}
+
+type PelSetFeatureEvent struct {
+	Layout       uint32   // Dword count in bits 2:0, memory buffer count in bits 31:16
+	Cdws         []uint32 // Logged command dwords, starting with CDW10
+	MemoryBuffer []byte
+}
+
+// Fid returns the feature identifier from the logged CDW10.
+func (e *PelSetFeatureEvent) Fid() uint8 {
+	if len(e.Cdws) == 0 {
+		return 0
+	}
+
+	return uint8(e.Cdws[0])
+}
+
+type PelTelemetryCreatedEvent struct {
+	Header TelemetryHeader
+}
+
+type PelThermalExcursionEvent struct {
+	OverTemp  uint8 // Kelvin over the threshold
+	Threshold uint8 // Threshold that was exceeded, in Kelvin
+}
+
+// decodeFixed reads the fixed size event structure v from data.
+func decodeFixed(data []byte, v any) (any, error) {
+	if len(data) < binary.Size(v) {
+		return nil, fmt.Errorf("event data too short: %d bytes, need %d", len(data), binary.Size(v))
+	}
+
+	binary.Read(bytes.NewBuffer(data), NativeEndian, v)
+
+	return v, nil
+}
+
+func decodePersistentEvent(t uint8, data []byte) (any, error) {
+	switch t {
+	case PEL_EVENT_SMART_SNAPSHOT:
+		if len(data) < 512 {
+			return nil, fmt.Errorf("event data too short: %d bytes, need 512", len(data))
+		}
+		return &PelSmartSnapshotEvent{decodeSMART(data)}, nil
+	case PEL_EVENT_FW_COMMIT:
+		return decodeFixed(data, &PelFirmwareCommitEvent{})
+	case PEL_EVENT_TIMESTAMP_CHANGE:
+		return decodeFixed(data, &PelTimestampChangeEvent{})
+	case PEL_EVENT_POWER_ON_RESET:
+		if len(data) < 8 {
+			return nil, fmt.Errorf("event data too short: %d bytes", len(data))
+		}
+		e := &PelPowerOnResetEvent{}
+		copy(e.Firmware[:], data)
+		e.Descriptors = make([]PelPowerOnResetDescriptor, (len(data)-8)/36)
+		binary.Read(bytes.NewBuffer(data[8:]), NativeEndian, e.Descriptors)
+		return e, nil
+	case PEL_EVENT_HW_ERROR:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("event data too short: %d bytes", len(data))
+		}
+		return &PelHardwareErrorEvent{Code: NativeEndian.Uint16(data), AdditionalInfo: data[4:]}, nil
+	case PEL_EVENT_CHANGE_NS:
+		return decodeFixed(data, &PelChangeNamespaceEvent{})
+	case PEL_EVENT_FORMAT_START:
+		return decodeFixed(data, &PelFormatStartEvent{})
+	case PEL_EVENT_FORMAT_COMPLETION:
+		return decodeFixed(data, &PelFormatCompletionEvent{})
+	case PEL_EVENT_SANITIZE_START:
+		return decodeFixed(data, &PelSanitizeStartEvent{})
+	case PEL_EVENT_SANITIZE_COMPLETION:
+		return decodeFixed(data, &PelSanitizeCompletionEvent{})
+	case PEL_EVENT_SET_FEATURE:
+		if len(data) < 4 {
+			return nil, fmt.Errorf("event data too short: %d bytes", len(data))
+		}
+		e := &PelSetFeatureEvent{Layout: NativeEndian.Uint32(data)}
+		ndw := int(getBitsValue(uint64(e.Layout), 0, 2))
+		mbc := int(getBitsValue(uint64(e.Layout), 16, 31))
+		if len(data) < 4+4*ndw+mbc {
+			return nil, fmt.Errorf("set feature event data too short: %d bytes", len(data))
+		}
+		for i := 0; i < ndw; i++ {
+			e.Cdws = append(e.Cdws, NativeEndian.Uint32(data[4+4*i:]))
+		}
+		e.MemoryBuffer = data[4+4*ndw : 4+4*ndw+mbc]
+		return e, nil
+	case PEL_EVENT_TELEMETRY_CREATED:
+		return decodeFixed(data, &PelTelemetryCreatedEvent{})
+	case PEL_EVENT_THERMAL_EXCURSION:
+		return decodeFixed(data, &PelThermalExcursionEvent{})
+	}
+
+	return nil, nil
+}
+
+// DecodePersistentEventLog decodes a complete Persistent Event log page.
+func DecodePersistentEventLog(buf []byte) (PersistentEventLogHeader, []PersistentEvent, error) {
+	var hdr PersistentEventLogHeader
+
+	if len(buf) < PEL_HEADER_SIZE {
+		return hdr, nil, fmt.Errorf("persistent event log too short: %d bytes", len(buf))
+	}
+	binary.Read(bytes.NewBuffer(buf[:PEL_HEADER_SIZE]), NativeEndian, &hdr)
+
+	var events []PersistentEvent
+
+	offset := PEL_HEADER_SIZE
+	for i := 0; i < int(hdr.Tnev); i++ {
+		// Event header: type, revision, header length, additional info, controller ID,
+		// timestamp, port ID, reserved, vendor specific info length and event length
+		if offset+24 > len(buf) {
+			return hdr, events, fmt.Errorf("event %d at offset %d: truncated header", i, offset)
+		}
+		b := buf[offset:]
+
+		ehl := int(b[2]) + 3
+		vsil := int(NativeEndian.Uint16(b[20:]))
+		el := int(NativeEndian.Uint16(b[22:]))
+
+		if ehl < 24 || vsil > el || offset+ehl+el > len(buf) {
+			return hdr, events, fmt.Errorf("event %d at offset %d: invalid length", i, offset)
+		}
+
+		e := PersistentEvent{
+			Type:         b[0],
+			TypeRevision: b[1],
+			Ehai:         b[3],
+			ControllerID: NativeEndian.Uint16(b[4:]),
+			Timestamp:    NativeEndian.Uint64(b[6:]),
+			PortID:       NativeEndian.Uint16(b[14:]),
+			VendorInfo:   b[ehl : ehl+vsil],
+			Data:         b[ehl+vsil : ehl+el],
+		}
+
+		event, err := decodePersistentEvent(e.Type, e.Data)
+		if err != nil {
+			return hdr, events, fmt.Errorf("event %d (type %#x): %w", i, e.Type, err)
+		}
+		e.Event = event
+
+		events = append(events, e)
+		offset += ehl + el
+	}
+
+	return hdr, events, nil
+}
+
+// ReadPersistentEventLog establishes a reporting context, reads the whole Persistent Event log
+// with offset reads and releases the context again.
+func (d *NVMeDevice) ReadPersistentEventLog(ctx context.Context) ([]byte, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return nil, err
+	}
+
+	if !idCtrl.HasLpa(LPA_PERSISTENT_EVT) {
+		return nil, fmt.Errorf("%w: persistent event log", ErrNotSupported)
+	}
+
+	if err := d.checkLogPage(LOGPAGE_PERSISTENT_EVENT_LOG, "persistent event log"); err != nil {
+		return nil, err
+	}
+
+	establish := LogRequest{Lid: LOGPAGE_PERSISTENT_EVENT_LOG, Lsp: PEL_ACTION_ESTABLISH, Length: PEL_HEADER_SIZE}
+	release := LogRequest{Lid: LOGPAGE_PERSISTENT_EVENT_LOG, Lsp: PEL_ACTION_RELEASE, Length: PEL_HEADER_SIZE}
+
+	hdrBuf, err := d.GetLogPage(ctx, establish)
+	if IsStatus(err, NVME_SCT_GENERIC, NVME_SC_CMD_SEQ_ERROR) {
+		// A reporting context left behind by an interrupted reader
+		if _, err := d.GetLogPage(ctx, release); err != nil {
+			return nil, err
+		}
+		hdrBuf, err = d.GetLogPage(ctx, establish)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer d.GetLogPage(context.Background(), release)
+
+	tll := NativeEndian.Uint64(hdrBuf[8:])
+	if tll <= PEL_HEADER_SIZE {
+		return hdrBuf, nil
+	}
+
+	// Transfers are in dwords
+	length := (tll + 3) &^ 3
+	if length > 1<<32 {
+		return nil, fmt.Errorf("persistent event log length %d too large", tll)
+	}
+
+	rest, err := d.GetLogPage(ctx, LogRequest{
+		Lid:    LOGPAGE_PERSISTENT_EVENT_LOG,
+		Lsp:    PEL_ACTION_READ,
+		Offset: PEL_HEADER_SIZE,
+		Length: int(length) - PEL_HEADER_SIZE,
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf := append(hdrBuf, rest...)
+
+	return buf[:tll], nil
+}
+
+// GetPersistentEventLog reads and decodes the Persistent Event log.
+func (d *NVMeDevice) GetPersistentEventLog(ctx context.Context) (PersistentEventLogHeader, []PersistentEvent, error) {
+	buf, err := d.ReadPersistentEventLog(ctx)
+	if err != nil {
+		return PersistentEventLogHeader{}, nil, err
+	}
+
+	return DecodePersistentEventLog(buf)
+}
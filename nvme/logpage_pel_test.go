@@ -0,0 +1,99 @@
+package nvme_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestGetPersistentEventLog(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.PersistentEvents = true
+	cfg.SMART.PowerOnHours = 42
+	d := newSimDevice(t, cfg)
+	ctx := context.Background()
+
+	if err := d.SetTimestamp(1700000000000); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Format(1, nvme.FormatParams{FormatIndex: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.UpdateFirmware(firmwareImage("2.0", 4096), nvme.FirmwareCommit{Slot: 2, Action: nvme.FW_COMMIT_CA_REPLACE_ACTIVATE}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reporting context left behind by another reader is released and established again
+	if _, err := d.GetLogPage(ctx, nvme.LogRequest{Lid: nvme.LOGPAGE_PERSISTENT_EVENT_LOG, Lsp: nvme.PEL_ACTION_ESTABLISH, Length: nvme.PEL_HEADER_SIZE}); err != nil {
+		t.Fatal(err)
+	}
+
+	hdr, events, err := d.GetPersistentEventLog(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hdr.Tnev != 3 || len(events) != 3 {
+		t.Fatalf("%d events, %d decoded, want 3", hdr.Tnev, len(events))
+	}
+	if v, _ := hdr.Poh.Uint64(); v != 42 || hdr.VendorID != cfg.VendorID {
+		t.Errorf("power on hours %v vendor %#x", hdr.Poh, hdr.VendorID)
+	}
+	if !hdr.SupportsEvent(nvme.PEL_EVENT_FW_COMMIT) || hdr.SupportsEvent(nvme.PEL_EVENT_THERMAL_EXCURSION) {
+		t.Error("supported events bitmap does not match the simulator")
+	}
+
+	for i, want := range []uint8{nvme.PEL_EVENT_FORMAT_START, nvme.PEL_EVENT_FORMAT_COMPLETION, nvme.PEL_EVENT_FW_COMMIT} {
+		if events[i].Type != want {
+			t.Errorf("event %d: type %#x, want %#x", i, events[i].Type, want)
+		}
+		if events[i].TimestampMs() < 1700000000000 {
+			t.Errorf("event %d: timestamp %d", i, events[i].TimestampMs())
+		}
+	}
+
+	if e, ok := events[0].Event.(*nvme.PelFormatStartEvent); !ok || e.Nsid != 1 {
+		t.Errorf("format start event %+v", events[0].Event)
+	}
+	if e, ok := events[2].Event.(*nvme.PelFirmwareCommitEvent); !ok || e.Slot != 2 || string(e.NewFirmware[:3]) != "2.0" {
+		t.Errorf("firmware commit event %+v", events[2].Event)
+	}
+
+	// The context was released, so the log can be read again
+	if _, _, err := d.GetPersistentEventLog(ctx); err != nil {
+		t.Errorf("second read: %v", err)
+	}
+}
+
+func TestDecodePersistentEventLogTruncated(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.PersistentEvents = true
+	d := newSimDevice(t, cfg)
+
+	if err := d.Format(1, nvme.FormatParams{}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := d.ReadPersistentEventLog(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := nvme.DecodePersistentEventLog(buf[:len(buf)-4]); err == nil {
+		t.Error("truncated log decoded")
+	}
+	if _, _, err := nvme.DecodePersistentEventLog(buf[:100]); err == nil {
+		t.Error("truncated header decoded")
+	}
+}
+
+func TestPersistentEventLogNotSupported(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	if _, _, err := d.GetPersistentEventLog(context.Background()); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+}
@@ -5,80 +5,246 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"math/big"
+	"strings"
 )
 
+const (
+	// Critical Warning bits of the SMART / Health Information log
+	CRIT_WARN_SPARE           uint8 = 1 << 0 // Available spare below threshold
+	CRIT_WARN_TEMPERATURE     uint8 = 1 << 1 // Temperature above an over or below an under threshold
+	CRIT_WARN_RELIABILITY     uint8 = 1 << 2 // Reliability degraded by media or internal errors
+	CRIT_WARN_READ_ONLY       uint8 = 1 << 3 // Media placed in read-only mode
+	CRIT_WARN_VOLATILE_BACKUP uint8 = 1 << 4 // Volatile memory backup device failed
+	CRIT_WARN_PMR_READ_ONLY   uint8 = 1 << 5 // Persistent Memory Region read-only or unreliable
+
+	// SMART_DATA_UNIT is the size of the data units counted by the SMART / Health Information log
+	SMART_DATA_UNIT = 512 * 1000
+)
+
+var critWarnNames = []struct {
+	mask uint8
+	name string
+}{
+	{CRIT_WARN_SPARE, "spare"},
+	{CRIT_WARN_TEMPERATURE, "temperature"},
+	{CRIT_WARN_RELIABILITY, "reliability"},
+	{CRIT_WARN_READ_ONLY, "read-only"},
+	{CRIT_WARN_VOLATILE_BACKUP, "volatile backup"},
+	{CRIT_WARN_PMR_READ_ONLY, "PMR read-only"},
+}
+
+// CriticalWarning is a set of CRIT_WARN_* bits. The Endurance Group Critical Warning Summary
+// shares the spare, reliability and read-only bits.
+type CriticalWarning uint8
+
+// Has reports whether all bits of mask are set.
+func (w CriticalWarning) Has(mask uint8) bool {
+	return uint8(w)&mask == mask
+}
+
+func (w CriticalWarning) String() string {
+	var names []string
+	for _, n := range critWarnNames {
+		if w.Has(n.mask) {
+			names = append(names, n.name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "none"
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Temperature is a temperature in Kelvin, as reported by the controller. Zero means the sensor
+// is not implemented.
+type Temperature uint16
+
+func (t Temperature) Kelvin() uint16 {
+	return uint16(t)
+}
+
+func (t Temperature) Celsius() int {
+	return int(t) - 273
+}
+
 type nvmeSMARTLog struct {
-	CritWarning      uint8
-	Temperature      [2]uint8
-	AvailSpare       uint8
-	SpareThresh      uint8
-	PercentUsed      uint8
-	Rsvd6            [26]byte
-	DataUnitsRead    [16]byte
-	DataUnitsWritten [16]byte
-	HostReads        [16]byte
-	HostWrites       [16]byte
-	CtrlBusyTime     [16]byte
-	PowerCycles      [16]byte
-	PowerOnHours     [16]byte
-	UnsafeShutdowns  [16]byte
-	MediaErrors      [16]byte
-	NumErrLogEntries [16]byte
-	WarningTempTime  uint32
-	CritCompTime     uint32
-	TempSensor       [8]uint16
-	Rsvd216          [296]byte
+	CritWarning          uint8
+	Temperature          [2]uint8
+	AvailSpare           uint8
+	SpareThresh          uint8
+	PercentUsed          uint8
+	EnduranceGrpCritWarn uint8
+	Rsvd7                [25]byte
+	DataUnitsRead        Uint128
+	DataUnitsWritten     Uint128
+	HostReads            Uint128
+	HostWrites           Uint128
+	CtrlBusyTime         Uint128
+	PowerCycles          Uint128
+	PowerOnHours         Uint128
+	UnsafeShutdowns      Uint128
+	MediaErrors          Uint128
+	NumErrLogEntries     Uint128
+	WarningTempTime      uint32
+	CritCompTime         uint32
+	TempSensor           [8]uint16
+	ThmTemp1TransCount   uint32
+	ThmTemp2TransCount   uint32
+	ThmTemp1TotalTime    uint32
+	ThmTemp2TotalTime    uint32
+	Rsvd232              [280]byte
 } // 512 bytes
 
+// SMARTHealth is the decoded SMART / Health Information log.
+type SMARTHealth struct {
+	CriticalWarning      CriticalWarning
+	EnduranceGrpCritWarn CriticalWarning // Endurance Group Critical Warning Summary
+	Temperature          Temperature     // Composite temperature
+	AvailSpare           uint8           // Percent
+	SpareThresh          uint8           // Percent
+	PercentUsed          uint8           // Percent of the rated endurance, may exceed 100
+	DataUnitsRead        Uint128         // In units of SMART_DATA_UNIT bytes, rounded up
+	DataUnitsWritten     Uint128
+	BytesRead            Uint128 // DataUnitsRead in bytes
+	BytesWritten         Uint128
+	HostReads            Uint128 // Read commands completed
+	HostWrites           Uint128
+	CtrlBusyTime         Uint128 // Minutes
+	PowerCycles          Uint128
+	PowerOnHours         Uint128
+	UnsafeShutdowns      Uint128
+	MediaErrors          Uint128
+	NumErrLogEntries     Uint128
+	WarningTempTime      uint32 // Minutes above the warning composite temperature threshold
+	CritCompTime         uint32 // Minutes above the critical composite temperature threshold
+	TempSensor           [8]Temperature
+	ThmTemp1TransCount   uint32 // Transitions into thermal management temperature 1
+	ThmTemp2TransCount   uint32
+	ThmTemp1TotalTime    uint32 // Seconds spent in thermal management temperature 1
+	ThmTemp2TotalTime    uint32
+}
+
+func decodeSMART(buf []byte) SMARTHealth {
+	var sl nvmeSMARTLog
+	binary.Read(bytes.NewBuffer(buf), NativeEndian, &sl)
+
+	h := SMARTHealth{
+		CriticalWarning:      CriticalWarning(sl.CritWarning),
+		EnduranceGrpCritWarn: CriticalWarning(sl.EnduranceGrpCritWarn),
+		Temperature:          Temperature(uint16(sl.Temperature[0]) | uint16(sl.Temperature[1])<<8),
+		AvailSpare:           sl.AvailSpare,
+		SpareThresh:          sl.SpareThresh,
+		PercentUsed:          sl.PercentUsed,
+		DataUnitsRead:        sl.DataUnitsRead,
+		DataUnitsWritten:     sl.DataUnitsWritten,
+		HostReads:            sl.HostReads,
+		HostWrites:           sl.HostWrites,
+		CtrlBusyTime:         sl.CtrlBusyTime,
+		PowerCycles:          sl.PowerCycles,
+		PowerOnHours:         sl.PowerOnHours,
+		UnsafeShutdowns:      sl.UnsafeShutdowns,
+		MediaErrors:          sl.MediaErrors,
+		NumErrLogEntries:     sl.NumErrLogEntries,
+		WarningTempTime:      sl.WarningTempTime,
+		CritCompTime:         sl.CritCompTime,
+		ThmTemp1TransCount:   sl.ThmTemp1TransCount,
+		ThmTemp2TransCount:   sl.ThmTemp2TransCount,
+		ThmTemp1TotalTime:    sl.ThmTemp1TotalTime,
+		ThmTemp2TotalTime:    sl.ThmTemp2TotalTime,
+	}
+
+	h.BytesRead = h.DataUnitsRead.Bytes(SMART_DATA_UNIT)
+	h.BytesWritten = h.DataUnitsWritten.Bytes(SMART_DATA_UNIT)
+
+	for i, t := range sl.TempSensor {
+		h.TempSensor[i] = Temperature(t)
+	}
+
+	return h
+}
+
 func (d *NVMeDevice) GetLogPageSmart(buf []byte) error {
+	return d.getLogPageSmart(NSID_ALL, buf)
+}
+
+func (d *NVMeDevice) getLogPageSmart(nsid uint32, buf []byte) error {
 	cdw10 := buildCdw(LogPageCdw10BitInfo, LogPageCdw10{
 		LID:   uint32(LOGPAGE_SMART_HEALTH_INFO),
 		NUMDL: ((uint32(len(buf)) / 4) - 1),
 	})
 
-	if err := d.GetLogPageRaw(0xffffffff, cdw10, 0, 0, 0, 0, buf); err != nil {
+	if err := d.GetLogPageRaw(nsid, cdw10, 0, 0, 0, 0, buf); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (d *NVMeDevice) PrintSMART(w io.Writer) error {
-	buf := make([]byte, 512)
+// GetSMART reads the SMART / Health Information log. An nsid of 0 or NSID_ALL returns the
+// controller-wide log; any other nsid requires per-namespace SMART support (LPA bit 0).
+func (d *NVMeDevice) GetSMART(nsid uint32) (SMARTHealth, error) {
+	if nsid == 0 {
+		nsid = NSID_ALL
+	}
 
-	// Read SMART log
-	if err := d.GetLogPageSmart(buf); err != nil {
-		return err
+	if nsid != NSID_ALL {
+		idCtrl, err := d.IdentifyController()
+		if err != nil {
+			return SMARTHealth{}, err
+		}
+
+		if !idCtrl.HasLpa(LPA_SMART_PER_NS) {
+			return SMARTHealth{}, fmt.Errorf("%w: per-namespace SMART / health log", ErrNotSupported)
+		}
 	}
 
-	var sl nvmeSMARTLog
+	buf := make([]byte, 512)
+	if err := d.getLogPageSmart(nsid, buf); err != nil {
+		return SMARTHealth{}, err
+	}
 
-	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &sl)
+	return decodeSMART(buf), nil
+}
 
-	unitsRead := le128ToBigInt(sl.DataUnitsRead)
-	unitsWritten := le128ToBigInt(sl.DataUnitsWritten)
-	unit := big.NewInt(512 * 1000)
+func (d *NVMeDevice) PrintSMART(w io.Writer) error {
+	h, err := d.GetSMART(NSID_ALL)
+	if err != nil {
+		return err
+	}
 
 	fmt.Fprintln(w, "\nSMART data follows:")
-	fmt.Fprintf(w, "Critical warning: %#02x\n", sl.CritWarning)
-	fmt.Fprintf(w, "Temperature: %d° Celsius\n",
-		(uint16(sl.Temperature[0])|uint16(sl.Temperature[1])<<8)-273) // Kelvin to degrees Celsius
-	fmt.Fprintf(w, "Avail. spare: %d%%\n", sl.AvailSpare)
-	fmt.Fprintf(w, "Avail. spare threshold: %d%%\n", sl.SpareThresh)
-	fmt.Fprintf(w, "Percentage used: %d%%\n", sl.PercentUsed)
-	fmt.Fprintf(w, "Data units read: %d [%s]\n",
-		unitsRead, formatBigBytes(new(big.Int).Mul(unitsRead, unit)))
-	fmt.Fprintf(w, "Data units written: %d [%s]\n",
-		unitsWritten, formatBigBytes(new(big.Int).Mul(unitsWritten, unit)))
-	fmt.Fprintf(w, "Host read commands: %d\n", le128ToBigInt(sl.HostReads))
-	fmt.Fprintf(w, "Host write commands: %d\n", le128ToBigInt(sl.HostWrites))
-	fmt.Fprintf(w, "Controller busy time: %d\n", le128ToBigInt(sl.CtrlBusyTime))
-	fmt.Fprintf(w, "Power cycles: %d\n", le128ToBigInt(sl.PowerCycles))
-	fmt.Fprintf(w, "Power on hours: %d\n", le128ToBigInt(sl.PowerOnHours))
-	fmt.Fprintf(w, "Unsafe shutdowns: %d\n", le128ToBigInt(sl.UnsafeShutdowns))
-	fmt.Fprintf(w, "Media & data integrity errors: %d\n", le128ToBigInt(sl.MediaErrors))
-	fmt.Fprintf(w, "Error information log entries: %d\n", le128ToBigInt(sl.NumErrLogEntries))
+	fmt.Fprintf(w, "Critical warning: %#02x [%s]\n", uint8(h.CriticalWarning), h.CriticalWarning)
+	fmt.Fprintf(w, "Endurance group critical warning: %#02x [%s]\n",
+		uint8(h.EnduranceGrpCritWarn), h.EnduranceGrpCritWarn)
+	fmt.Fprintf(w, "Temperature: %d° Celsius\n", h.Temperature.Celsius())
+	fmt.Fprintf(w, "Avail. spare: %d%%\n", h.AvailSpare)
+	fmt.Fprintf(w, "Avail. spare threshold: %d%%\n", h.SpareThresh)
+	fmt.Fprintf(w, "Percentage used: %d%%\n", h.PercentUsed)
+	fmt.Fprintf(w, "Data units read: %d [%s]\n", h.DataUnitsRead, h.BytesRead.FormatBytes())
+	fmt.Fprintf(w, "Data units written: %d [%s]\n", h.DataUnitsWritten, h.BytesWritten.FormatBytes())
+	fmt.Fprintf(w, "Host read commands: %d\n", h.HostReads)
+	fmt.Fprintf(w, "Host write commands: %d\n", h.HostWrites)
+	fmt.Fprintf(w, "Controller busy time: %d\n", h.CtrlBusyTime)
+	fmt.Fprintf(w, "Power cycles: %d\n", h.PowerCycles)
+	fmt.Fprintf(w, "Power on hours: %d\n", h.PowerOnHours)
+	fmt.Fprintf(w, "Unsafe shutdowns: %d\n", h.UnsafeShutdowns)
+	fmt.Fprintf(w, "Media & data integrity errors: %d\n", h.MediaErrors)
+	fmt.Fprintf(w, "Error information log entries: %d\n", h.NumErrLogEntries)
+	fmt.Fprintf(w, "Warning temperature time: %d minutes\n", h.WarningTempTime)
+	fmt.Fprintf(w, "Critical composite temperature time: %d minutes\n", h.CritCompTime)
+
+	for i, t := range h.TempSensor {
+		if t != 0 {
+			fmt.Fprintf(w, "Temperature sensor %d: %d° Celsius\n", i+1, t.Celsius())
+		}
+	}
+
+	fmt.Fprintf(w, "Thermal management T1 transitions: %d\n", h.ThmTemp1TransCount)
+	fmt.Fprintf(w, "Thermal management T2 transitions: %d\n", h.ThmTemp2TransCount)
+	fmt.Fprintf(w, "Thermal management T1 total time: %d seconds\n", h.ThmTemp1TotalTime)
+	fmt.Fprintf(w, "Thermal management T2 total time: %d seconds\n", h.ThmTemp2TotalTime)
 
 	return nil
 }
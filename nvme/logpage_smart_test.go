@@ -0,0 +1,108 @@
+package nvme_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestGetSMART(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.SMART.PowerOnHours = 1234
+	cfg.SMART.PowerCycles = 56
+	cfg.SMART.PercentUsed = 7
+	cfg.SMART.TempSensor[0] = 310
+	d := newSimDevice(t, cfg)
+
+	h, err := d.GetSMART(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Temperature.Kelvin() != 308 || h.Temperature.Celsius() != 35 {
+		t.Errorf("temperature %d K %d C, want 308 35", h.Temperature.Kelvin(), h.Temperature.Celsius())
+	}
+	if h.AvailSpare != 100 || h.SpareThresh != 10 || h.PercentUsed != 7 {
+		t.Errorf("spare %d%% threshold %d%% used %d%%, want 100 10 7", h.AvailSpare, h.SpareThresh, h.PercentUsed)
+	}
+	if v, ok := h.PowerOnHours.Uint64(); !ok || v != 1234 {
+		t.Errorf("power on hours %v, want 1234", h.PowerOnHours)
+	}
+	if v, ok := h.PowerCycles.Uint64(); !ok || v != 56 {
+		t.Errorf("power cycles %v, want 56", h.PowerCycles)
+	}
+	if h.TempSensor[0].Kelvin() != 310 || h.TempSensor[1] != 0 {
+		t.Errorf("temperature sensors %v, want 310 and unimplemented", h.TempSensor[:2])
+	}
+	if h.CriticalWarning != 0 {
+		t.Errorf("critical warning %s, want none", h.CriticalWarning)
+	}
+}
+
+func TestGetSMARTWarningsAndCounters(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.SMART.CritWarning = nvme.CRIT_WARN_SPARE | nvme.CRIT_WARN_READ_ONLY
+	cfg.SMART.EnduranceGrpCritWarn = nvme.CRIT_WARN_RELIABILITY
+	cfg.SMART.DataUnitsRead = 999
+	cfg.SMART.ThmTempTransCount = [2]uint32{3, 4}
+	cfg.SMART.ThmTempTotalTime = [2]uint32{30, 40}
+	n := newSimNamespace(t, cfg)
+
+	// 1000 512-byte units written, within the transfer size limit, advance the counter by one
+	// data unit
+	for lba := uint64(0); lba < 1000; lba += 200 {
+		if err := n.Write(lba, 200, make([]byte, 200*512)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := n.Device().GetSMART(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !h.CriticalWarning.Has(nvme.CRIT_WARN_SPARE|nvme.CRIT_WARN_READ_ONLY) || h.CriticalWarning.Has(nvme.CRIT_WARN_TEMPERATURE) {
+		t.Errorf("critical warning %#x", uint8(h.CriticalWarning))
+	}
+	if s := h.CriticalWarning.String(); s != "spare, read-only" {
+		t.Errorf("critical warning %q", s)
+	}
+	if s := h.EnduranceGrpCritWarn.String(); s != "reliability" {
+		t.Errorf("endurance group critical warning %q", s)
+	}
+
+	if v, _ := h.DataUnitsWritten.Uint64(); v != 1 {
+		t.Errorf("data units written %v, want 1", h.DataUnitsWritten)
+	}
+	if v, _ := h.BytesWritten.Uint64(); v != nvme.SMART_DATA_UNIT {
+		t.Errorf("bytes written %v, want %d", h.BytesWritten, nvme.SMART_DATA_UNIT)
+	}
+	if v, _ := h.BytesRead.Uint64(); v != 999*nvme.SMART_DATA_UNIT {
+		t.Errorf("bytes read %v, want %d", h.BytesRead, 999*nvme.SMART_DATA_UNIT)
+	}
+	if v, _ := h.HostWrites.Uint64(); v != 5 {
+		t.Errorf("host writes %v, want 5", h.HostWrites)
+	}
+
+	if h.ThmTemp1TransCount != 3 || h.ThmTemp2TransCount != 4 || h.ThmTemp1TotalTime != 30 || h.ThmTemp2TotalTime != 40 {
+		t.Errorf("thermal management %d %d %d %d", h.ThmTemp1TransCount, h.ThmTemp2TransCount,
+			h.ThmTemp1TotalTime, h.ThmTemp2TotalTime)
+	}
+
+	var out bytes.Buffer
+	if err := n.Device().PrintSMART(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"Critical warning: 0x09 [spare, read-only]",
+		"Data units written: 1 [512 KB]",
+		"Thermal management T2 transitions: 4",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("PrintSMART output missing %q:\n%s", line, out.String())
+		}
+	}
+}
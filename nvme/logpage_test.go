@@ -0,0 +1,85 @@
+package nvme_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+// raeTransport is a simulated controller that records the Retain Asynchronous Event bit of each
+// Get Log Page command.
+type raeTransport struct {
+	*nvmesim.Controller
+	rae []bool
+}
+
+func (t *raeTransport) SubmitAdmin(cmd *nvme.Command) error {
+	if cmd.Opcode == nvme.NVME_ADMIN_GET_LOG_PAGE {
+		t.rae = append(t.rae, cmd.Cdw10&(1<<15) != 0)
+	}
+
+	return t.Controller.SubmitAdmin(cmd)
+}
+
+func TestGetLogPage(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Telemetry = true
+	cfg.Mdts = 1 // 8 KiB transfers
+	tr := &raeTransport{Controller: nvmesim.New(cfg)}
+	d := nvme.NewNVMeDeviceWithTransport("sim0", tr)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	size := 53 * nvme.TELEMETRY_BLOCK_SIZE
+
+	full, err := d.GetLogPage(ctx, nvme.LogRequest{Lid: nvme.LOGPAGE_TELEMETRY_CTRL, Length: size})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.rae) != 4 || !tr.rae[0] || !tr.rae[2] || tr.rae[3] {
+		t.Errorf("RAE bits %v, want only the last transfer to clear the event", tr.rae)
+	}
+	for i := nvme.TELEMETRY_BLOCK_SIZE; i < size; i++ {
+		if full[i] != uint8(i/nvme.TELEMETRY_BLOCK_SIZE) {
+			t.Fatalf("wrong data at offset %d", i)
+		}
+	}
+
+	part, err := d.GetLogPage(ctx, nvme.LogRequest{Lid: nvme.LOGPAGE_TELEMETRY_CTRL, Offset: 8188, Length: 8200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(part, full[8188:8188+8200]) {
+		t.Error("read at an offset differs from the full log")
+	}
+
+	var b bytes.Buffer
+	if err := d.StreamLogPage(ctx, nvme.LogRequest{Lid: nvme.LOGPAGE_TELEMETRY_CTRL, Offset: 512, Length: size - 512}, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), full[512:]) {
+		t.Error("streamed log differs from the full log")
+	}
+
+	for _, req := range []nvme.LogRequest{
+		{Lid: nvme.LOGPAGE_SMART_HEALTH_INFO, Length: 0},
+		{Lid: nvme.LOGPAGE_SMART_HEALTH_INFO, Length: 510},
+		{Lid: nvme.LOGPAGE_SMART_HEALTH_INFO, Offset: 2, Length: 4},
+	} {
+		if _, err := d.GetLogPage(ctx, req); err == nil {
+			t.Errorf("invalid request %+v accepted", req)
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := d.GetLogPage(canceled, nvme.LogRequest{Lid: nvme.LOGPAGE_TELEMETRY_CTRL, Length: size}); err != context.Canceled {
+		t.Errorf("canceled read: got %v", err)
+	}
+}
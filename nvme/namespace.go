@@ -0,0 +1,196 @@
+package nvme
+
+import (
+	"fmt"
+)
+
+const (
+	// NSID_ALL is the broadcast value of the namespace identifier
+	NSID_ALL uint32 = 0xffffffff
+)
+
+// NVMeNamespace is a handle to one namespace of an NVMeDevice. I/O submitted through it is
+// addressed to its NSID, regardless of which device node the controller was opened through.
+type NVMeNamespace struct {
+	Nsid      uint32
+	Ident     NvmeIdentNamespace
+	BlockSize uint32 // Logical block data size in bytes
+	dev       *NVMeDevice
+	formatGen uint64 // Device format generation of Ident
+}
+
+// ListNamespaces returns the NSIDs of all active namespaces in increasing order.
+func (d *NVMeDevice) ListNamespaces() ([]uint32, error) {
+	return d.listNamespaces(IDENTIFY_CNS_ACTIVE_NS_LIST)
+}
+
+// ListAllocatedNamespaces returns the NSIDs of all allocated namespaces, attached or not, in
+// increasing order. The controller must support Namespace Management.
+func (d *NVMeDevice) ListAllocatedNamespaces() ([]uint32, error) {
+	return d.listNamespaces(IDENTIFY_CNS_ALLOC_NS_LIST)
+}
+
+// listNamespaces pages through a namespace list. Each Identify returns up to 1024 NSIDs greater
+// than the NSID of the command, so the last NSID of a full page starts the next one.
+func (d *NVMeDevice) listNamespaces(cns uint8) ([]uint32, error) {
+	var nsids []uint32
+
+	buf := make([]byte, 4096)
+	start := uint32(0)
+
+	for {
+		if err := d.IdentifyRaw(cns, start, uint32(cns), 0, 0, buf); err != nil {
+			return nil, err
+		}
+
+		n := 0
+		for ; n < len(buf)/4; n++ {
+			nsid := NativeEndian.Uint32(buf[n*4:])
+			if nsid == 0 {
+				break
+			}
+			nsids = append(nsids, nsid)
+		}
+
+		if n < len(buf)/4 || nsids[len(nsids)-1] >= NSID_ALL-1 {
+			return nsids, nil
+		}
+
+		start = nsids[len(nsids)-1]
+	}
+}
+
+// Namespace returns a handle to the active namespace nsid.
+func (d *NVMeDevice) Namespace(nsid uint32) (*NVMeNamespace, error) {
+	if nsid == 0 || nsid == NSID_ALL {
+		return nil, fmt.Errorf("invalid namespace ID %#x", nsid)
+	}
+
+	n := &NVMeNamespace{Nsid: nsid, dev: d}
+
+	if err := n.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// Namespaces returns handles to all active namespaces.
+func (d *NVMeDevice) Namespaces() ([]*NVMeNamespace, error) {
+	nsids, err := d.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make([]*NVMeNamespace, 0, len(nsids))
+	for _, nsid := range nsids {
+		n, err := d.Namespace(nsid)
+		if err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, n)
+	}
+
+	return namespaces, nil
+}
+
+// Device returns the device the namespace belongs to.
+func (n *NVMeNamespace) Device() *NVMeDevice {
+	return n.dev
+}
+
+// Refresh re-reads the Identify Namespace data and the block size derived from it.
+func (n *NVMeNamespace) Refresh() error {
+	gen := n.dev.formatGen
+
+	ident, err := n.dev.IdentifyNamespace(n.Nsid)
+	if err != nil {
+		return err
+	}
+
+	// Inactive namespaces return a zero filled data structure
+	if ident.Nsze == 0 {
+		return fmt.Errorf("namespace %d is not active", n.Nsid)
+	}
+
+	n.Ident = ident
+	n.BlockSize = ident.BlockSize()
+	n.formatGen = gen
+
+	return nil
+}
+
+// sync refreshes the handle if the device issued a Format NVM since it was last refreshed, which
+// may have changed the LBA format and size of the namespace.
+func (n *NVMeNamespace) sync() error {
+	if n.formatGen == n.dev.formatGen {
+		return nil
+	}
+
+	return n.Refresh()
+}
+
+// transferSize returns the number of bytes transferred for length logical blocks, including
+// metadata if it is interleaved with the data.
+func (n *NVMeNamespace) transferSize(length uint16) int {
+	bs := int(n.BlockSize)
+	if n.Ident.ExtendedMetadata() {
+		bs += int(n.Ident.MetadataSize())
+	}
+
+	return int(length) * bs
+}
+
+func (n *NVMeNamespace) checkBuffer(length uint16, buf []byte) error {
+	if length == 0 {
+		return fmt.Errorf("invalid transfer length 0")
+	}
+
+	if size := n.transferSize(length); len(buf) < size {
+		return fmt.Errorf("buffer too small: %d bytes, need %d", len(buf), size)
+	}
+
+	return nil
+}
+
+// Read reads length logical blocks starting at lba.
+func (n *NVMeNamespace) Read(lba uint64, length uint16, buf []byte) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if err := n.checkBuffer(length, buf); err != nil {
+		return err
+	}
+
+	return n.dev.read(n.Nsid, lba, length, buf[:n.transferSize(length)])
+}
+
+// Write writes length logical blocks starting at lba.
+func (n *NVMeNamespace) Write(lba uint64, length uint16, buf []byte) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if err := n.checkBuffer(length, buf); err != nil {
+		return err
+	}
+
+	return n.dev.write(n.Nsid, lba, length, buf[:n.transferSize(length)])
+}
+
+// checkOptionalCommand returns an error wrapping ErrNotSupported unless the controller reports
+// the optional NVM command in ONCS and does not list it as unsupported in its Commands Supported
+// and Effects log.
+func (d *NVMeDevice) checkOptionalCommand(oncs uint16, opcode uint8, what string) error {
+	idCtrl, err := d.cachedIdentifyController()
+	if err != nil {
+		return err
+	}
+
+	if !idCtrl.HasOncs(oncs) {
+		return fmt.Errorf("%w: %s", ErrNotSupported, what)
+	}
+
+	return d.checkIOCommand(opcode, what)
+}
@@ -0,0 +1,112 @@
+package nvme_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestNamespaces(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Namespaces = append(cfg.Namespaces, nvmesim.NamespaceConfig{Nsid: 2, Size: 1024, LbaFormats: cfg.Namespaces[0].LbaFormats, FormatIndex: 1})
+	d := newSimDevice(t, cfg)
+
+	nsids, err := d.ListNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(nsids, []uint32{1, 2}) {
+		t.Errorf("active namespaces %v, want [1 2]", nsids)
+	}
+
+	namespaces, err := d.Namespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(namespaces) != 2 || namespaces[0].BlockSize != 512 || namespaces[1].BlockSize != 4096 {
+		t.Fatalf("namespaces %+v", namespaces)
+	}
+	if namespaces[1].Ident.Nsze != 1024 || namespaces[1].Device() != d {
+		t.Errorf("namespace 2: NSZE %d", namespaces[1].Ident.Nsze)
+	}
+
+	for _, nsid := range []uint32{0, nvme.NSID_ALL} {
+		if _, err := d.Namespace(nsid); err == nil {
+			t.Errorf("handle to NSID %#x", nsid)
+		}
+	}
+	if _, err := d.Namespace(3); !nvme.IsInvalidNamespace(err) {
+		t.Errorf("missing namespace: got %v, want Invalid Namespace", err)
+	}
+}
+
+func TestListNamespacesPaging(t *testing.T) {
+	// Report 1500 namespaces, 1024 per page, starting after the NSID of the command
+	tr := &recordingTransport{
+		respond: func(cmd *nvme.Command) error {
+			clear(cmd.Data)
+			for i := 0; i < 1024; i++ {
+				nsid := cmd.Nsid + uint32(i) + 1
+				if nsid > 1500 {
+					break
+				}
+				binary.NativeEndian.PutUint32(cmd.Data[i*4:], nsid)
+			}
+			return nil
+		},
+	}
+
+	nsids, err := nvme.NewNVMeDeviceWithTransport("fake0", tr).ListNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nsids) != 1500 || nsids[1499] != 1500 {
+		t.Errorf("%d namespaces, want 1500", len(nsids))
+	}
+	if len(tr.admin) != 2 || tr.admin[1].Nsid != 1024 {
+		t.Errorf("%d Identify commands, want 2 with the second starting at 1024", len(tr.admin))
+	}
+}
+
+func TestNamespaceReadWrite(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Namespaces = append(cfg.Namespaces, nvmesim.NamespaceConfig{Nsid: 2, Size: 1024, LbaFormats: cfg.Namespaces[0].LbaFormats, FormatIndex: 1})
+	d := newSimDevice(t, cfg)
+
+	namespaces, err := d.Namespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same LBA in each namespace holds its own data
+	for _, n := range namespaces {
+		if err := n.Write(100, 8, pattern(8*int(n.BlockSize), byte(n.Nsid))); err != nil {
+			t.Fatalf("namespace %d: %v", n.Nsid, err)
+		}
+	}
+	for _, n := range namespaces {
+		buf := make([]byte, 8*n.BlockSize)
+		if err := n.Read(100, 8, buf); err != nil {
+			t.Fatalf("namespace %d: %v", n.Nsid, err)
+		}
+		if !bytes.Equal(buf, pattern(len(buf), byte(n.Nsid))) {
+			t.Errorf("namespace %d: read data differs from written data", n.Nsid)
+		}
+	}
+
+	n := namespaces[1]
+	if err := n.Write(0, 2, make([]byte, n.BlockSize)); err == nil {
+		t.Error("write with a short buffer succeeded")
+	}
+	if err := n.Read(0, 0, nil); err == nil {
+		t.Error("read of 0 blocks succeeded")
+	}
+	if err := n.Read(n.Ident.Nsze-1, 2, make([]byte, 2*n.BlockSize)); !nvme.IsLbaOutOfRange(err) {
+		t.Errorf("read past the end: got %v, want LBA Out of Range", err)
+	}
+}
@@ -0,0 +1,345 @@
+package nvme
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// Select field of Namespace Management
+	NS_MGMT_SEL_CREATE uint8 = 0x0
+	NS_MGMT_SEL_DELETE uint8 = 0x1
+
+	// Select field of Namespace Attachment
+	NS_ATTACH_SEL_ATTACH uint8 = 0x0
+	NS_ATTACH_SEL_DETACH uint8 = 0x1
+)
+
+var NsManagementCdw10BitInfo = cdwBitInfo{
+	{
+		name: "SEL", bitStart: 0,
+	},
+}
+
+type NsManagementCdw10 struct {
+	SEL uint32
+}
+
+var NsManagementCdw11BitInfo = cdwBitInfo{
+	{
+		name: "CSI", bitStart: 24,
+	},
+}
+
+type NsManagementCdw11 struct {
+	CSI uint32
+}
+
+// NamespaceCreateParams describes a namespace to be created with CreateNamespace.
+type NamespaceCreateParams struct {
+	Size             uint64 // NSZE, in logical blocks of the selected LBA format
+	Capacity         uint64 // NCAP, in logical blocks; 0 makes it equal to Size
+	FormatIndex      uint8  // LBA format index, cf. the common namespace capabilities
+	ExtendedMetadata bool   // Transfer metadata as part of an extended data LBA
+	Dps              uint8  // End-to-end Data Protection Type Settings
+	Shared           bool   // May be attached to more than one controller
+	AnaGroupID       uint32 // 0 lets the controller choose
+	NvmSetID         uint16
+	EnduranceGroupID uint16
+	CSI              uint8 // Command Set Identifier, 0 for the NVM Command Set
+}
+
+// NamespaceGranularity is one descriptor of the Namespace Granularity List, in bytes. A zero value
+// means the granularity is not reported.
+type NamespaceGranularity struct {
+	SizeGranularity     uint64
+	CapacityGranularity uint64
+}
+
+// IdentifyAllocatedNamespace returns the Identify Namespace data of an allocated namespace, which
+// need not be attached to this controller.
+func (d *NVMeDevice) IdentifyAllocatedNamespace(nsid uint32) (NvmeIdentNamespace, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.IdentifyRaw(IDENTIFY_CNS_ALLOC_NS, nsid, uint32(IDENTIFY_CNS_ALLOC_NS), 0, 0, buf); err != nil {
+		return NvmeIdentNamespace{}, err
+	}
+
+	var ns NvmeIdentNamespace
+	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &ns)
+
+	return ns, nil
+}
+
+// ListControllers returns the identifiers of all controllers in the NVM subsystem.
+func (d *NVMeDevice) ListControllers() ([]uint16, error) {
+	return d.listControllers(IDENTIFY_CNS_CTRL_LIST, 0)
+}
+
+// ListAttachedControllers returns the identifiers of the controllers attached to namespace nsid.
+func (d *NVMeDevice) ListAttachedControllers(nsid uint32) ([]uint16, error) {
+	return d.listControllers(IDENTIFY_CNS_CTRL_LIST_NS, nsid)
+}
+
+// listControllers pages through a controller list. Each Identify returns up to 2047 controller
+// identifiers greater than or equal to the CNTID in CDW10.
+func (d *NVMeDevice) listControllers(cns uint8, nsid uint32) ([]uint16, error) {
+	var ids []uint16
+
+	buf := make([]byte, 4096)
+	start := uint32(0)
+
+	for {
+		if err := d.IdentifyRaw(cns, nsid, uint32(cns)|start<<16, 0, 0, buf); err != nil {
+			return nil, err
+		}
+
+		n := int(NativeEndian.Uint16(buf))
+		for i := 0; i < n && i < 2047; i++ {
+			ids = append(ids, NativeEndian.Uint16(buf[2+2*i:]))
+		}
+
+		if n < 2047 || ids[len(ids)-1] == 0xffff {
+			return ids, nil
+		}
+
+		start = uint32(ids[len(ids)-1]) + 1
+	}
+}
+
+// GetNamespaceGranularity returns the Namespace Granularity List. It holds either a single
+// descriptor that applies to every LBA format, or one descriptor per LBA format.
+func (d *NVMeDevice) GetNamespaceGranularity() ([]NamespaceGranularity, error) {
+	buf := make([]byte, 4096)
+
+	if err := d.IdentifyRaw(IDENTIFY_CNS_NS_GRAN_LIST, 0, uint32(IDENTIFY_CNS_NS_GRAN_LIST), 0, 0, buf); err != nil {
+		return nil, err
+	}
+
+	numdesc := int(buf[4]) + 1
+	if numdesc > 16 {
+		numdesc = 16
+	}
+
+	gran := make([]NamespaceGranularity, numdesc)
+	for i := range gran {
+		gran[i].SizeGranularity = NativeEndian.Uint64(buf[32+16*i:])
+		gran[i].CapacityGranularity = NativeEndian.Uint64(buf[40+16*i:])
+	}
+
+	return gran, nil
+}
+
+// validateNamespaceCreate checks p against the controller's unallocated capacity, the common
+// namespace capabilities and, if reported, the namespace granularity.
+func (d *NVMeDevice) validateNamespaceCreate(idCtrl *NvmeIdentController, p *NamespaceCreateParams) error {
+	if p.Size == 0 || p.Capacity > p.Size {
+		return fmt.Errorf("invalid namespace size %d / capacity %d", p.Size, p.Capacity)
+	}
+
+	if p.AnaGroupID != 0 && !idCtrl.HasAnacap(ANACAP_GRPID_NS_MGMT) {
+		return fmt.Errorf("%w: ANA group ID in namespace management", ErrNotSupported)
+	}
+
+	common, err := d.IdentifyNamespace(NSID_ALL)
+	if err != nil {
+		return err
+	}
+
+	if p.FormatIndex > common.Nlbaf || int(p.FormatIndex) >= len(common.Lbaf) {
+		return fmt.Errorf("LBA format %d out of range (%d formats)", p.FormatIndex, int(common.Nlbaf)+1)
+	}
+
+	bs := uint64(common.Lbaf[p.FormatIndex].DataSize())
+	if bs == 0 {
+		return fmt.Errorf("LBA format %d is not supported", p.FormatIndex)
+	}
+
+	sizeBytes := p.Size * bs
+	if sizeBytes/bs != p.Size {
+		return fmt.Errorf("namespace size overflows")
+	}
+
+	capBytes := p.Capacity * bs
+	if capBytes/bs != p.Capacity {
+		return fmt.Errorf("namespace capacity overflows")
+	}
+
+	if !idCtrl.Tnvmcap.IsZero() && idCtrl.Unvmcap.Cmp(Uint128From64(capBytes)) < 0 {
+		return fmt.Errorf("namespace capacity %d bytes exceeds unallocated capacity %d bytes", capBytes, idCtrl.Unvmcap)
+	}
+
+	if !idCtrl.HasCtratt(CTRATT_NS_GRANULARITY) {
+		return nil
+	}
+
+	gran, err := d.GetNamespaceGranularity()
+	if err != nil {
+		return err
+	}
+
+	g := gran[0]
+	if len(gran) > 1 {
+		if int(p.FormatIndex) >= len(gran) {
+			return nil
+		}
+		g = gran[p.FormatIndex]
+	}
+
+	if g.SizeGranularity != 0 && sizeBytes%g.SizeGranularity != 0 {
+		return fmt.Errorf("namespace size %d bytes is not a multiple of the granularity %d", sizeBytes, g.SizeGranularity)
+	}
+
+	if g.CapacityGranularity != 0 && capBytes%g.CapacityGranularity != 0 {
+		return fmt.Errorf("namespace capacity %d bytes is not a multiple of the granularity %d", capBytes, g.CapacityGranularity)
+	}
+
+	return nil
+}
+
+// rescanNamespaces asks the host to rescan the namespaces after a successful namespace management
+// or attachment command. A failure is reported wrapping ErrRescanFailed, so that callers can tell
+// it apart from a failure of the command itself.
+func (d *NVMeDevice) rescanNamespaces() error {
+	if err := d.Rescan(); err != nil {
+		return fmt.Errorf("%w: %w", ErrRescanFailed, err)
+	}
+
+	return nil
+}
+
+// CreateNamespace creates a namespace and returns its NSID. The namespace is not attached to any
+// controller; use AttachNamespace to make it usable. If only the rescan that follows fails, the
+// NSID is returned together with an error wrapping ErrRescanFailed.
+func (d *NVMeDevice) CreateNamespace(p NamespaceCreateParams) (uint32, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return 0, err
+	}
+
+	if !idCtrl.HasOacs(OACS_NS_MGMT) {
+		return 0, fmt.Errorf("%w: namespace management", ErrNotSupported)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_NS_MANAGEMENT, "namespace management"); err != nil {
+		return 0, err
+	}
+
+	if p.Capacity == 0 {
+		p.Capacity = p.Size
+	}
+
+	if err := d.validateNamespaceCreate(&idCtrl, &p); err != nil {
+		return 0, err
+	}
+
+	ns := NvmeIdentNamespace{
+		Nsze:     p.Size,
+		Ncap:     p.Capacity,
+		Flbas:    p.FormatIndex&0xf | (p.FormatIndex>>4)<<5,
+		Dps:      p.Dps,
+		Anagrpid: p.AnaGroupID,
+		Nvmsetid: p.NvmSetID,
+		Endgid:   p.EnduranceGroupID,
+	}
+
+	if p.ExtendedMetadata {
+		ns.Flbas |= FLBAS_EXTENDED_METADATA
+	}
+
+	if p.Shared {
+		ns.Nmic = NMIC_SHARED
+	}
+
+	var data bytes.Buffer
+	binary.Write(&data, NativeEndian, &ns)
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_NS_MANAGEMENT,
+		Data:   data.Bytes(),
+		Cdw10:  buildCdw(NsManagementCdw10BitInfo, NsManagementCdw10{SEL: uint32(NS_MGMT_SEL_CREATE)}),
+		Cdw11:  buildCdw(NsManagementCdw11BitInfo, NsManagementCdw11{CSI: uint32(p.CSI)}),
+	}
+
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
+		return 0, err
+	}
+
+	// The NSID of the created namespace is returned in completion dword 0
+	return cmd.Result, d.rescanNamespaces()
+}
+
+// DeleteNamespace deletes namespace nsid, or all namespaces if nsid is NSID_ALL. As with
+// CreateNamespace, an error wrapping ErrRescanFailed means that the namespace was deleted.
+func (d *NVMeDevice) DeleteNamespace(nsid uint32) error {
+	if err := d.checkAdminCommand(NVME_ADMIN_NS_MANAGEMENT, "namespace management"); err != nil {
+		return err
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_NS_MANAGEMENT,
+		Nsid:   nsid,
+		Cdw10:  buildCdw(NsManagementCdw10BitInfo, NsManagementCdw10{SEL: uint32(NS_MGMT_SEL_DELETE)}),
+	}
+
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
+		return err
+	}
+
+	return d.rescanNamespaces()
+}
+
+// AttachNamespace attaches namespace nsid to the controllers in ctrls. An empty list attaches it
+// to the controller the device was opened through. As with CreateNamespace, an error wrapping
+// ErrRescanFailed means that the command itself succeeded.
+func (d *NVMeDevice) AttachNamespace(nsid uint32, ctrls []uint16) error {
+	return d.nsAttachment(NS_ATTACH_SEL_ATTACH, nsid, ctrls)
+}
+
+// DetachNamespace detaches namespace nsid from the controllers in ctrls. An empty list detaches it
+// from the controller the device was opened through.
+func (d *NVMeDevice) DetachNamespace(nsid uint32, ctrls []uint16) error {
+	return d.nsAttachment(NS_ATTACH_SEL_DETACH, nsid, ctrls)
+}
+
+func (d *NVMeDevice) nsAttachment(sel uint8, nsid uint32, ctrls []uint16) error {
+	if nsid == 0 || nsid == NSID_ALL {
+		return fmt.Errorf("invalid namespace ID %#x", nsid)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_NS_ATTACHMENT, "namespace attachment"); err != nil {
+		return err
+	}
+
+	if len(ctrls) == 0 {
+		idCtrl, err := d.IdentifyController()
+		if err != nil {
+			return err
+		}
+		ctrls = []uint16{idCtrl.Cntlid}
+	}
+
+	if len(ctrls) > 2047 {
+		return fmt.Errorf("controller list too long: %d entries", len(ctrls))
+	}
+
+	buf := make([]byte, 4096)
+	NativeEndian.PutUint16(buf, uint16(len(ctrls)))
+	for i, id := range ctrls {
+		NativeEndian.PutUint16(buf[2+2*i:], id)
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_NS_ATTACHMENT,
+		Nsid:   nsid,
+		Data:   buf,
+		Cdw10:  uint32(sel),
+	}
+
+	if err := d.transport.SubmitAdmin(&cmd); err != nil {
+		return err
+	}
+
+	return d.rescanNamespaces()
+}
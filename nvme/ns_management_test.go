@@ -0,0 +1,154 @@
+package nvme_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+// rescanTransport is a simulated controller whose namespace rescans return err.
+type rescanTransport struct {
+	*nvmesim.Controller
+	rescans int
+	err     error
+}
+
+func (t *rescanTransport) Rescan() error {
+	t.rescans++
+	return t.err
+}
+
+func nsManagementConfig() nvmesim.Config {
+	cfg := nvmesim.DefaultConfig()
+	cfg.NsManagement = true
+	cfg.MaxNamespaces = 4
+	cfg.Capacity = 1 << 32
+
+	return cfg
+}
+
+func TestNamespaceManagement(t *testing.T) {
+	tr := &rescanTransport{Controller: nvmesim.New(nsManagementConfig())}
+	d := nvme.NewNVMeDeviceWithTransport("sim0", tr)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	nsid, err := d.CreateNamespace(nvme.NamespaceCreateParams{Size: 1 << 16, FormatIndex: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nsid != 2 {
+		t.Errorf("created NSID %d, want 2", nsid)
+	}
+
+	if ids, err := d.ListAllocatedNamespaces(); err != nil || !slices.Equal(ids, []uint32{1, 2}) {
+		t.Errorf("allocated namespaces %v, err %v, want [1 2]", ids, err)
+	}
+	if ids, err := d.ListNamespaces(); err != nil || !slices.Equal(ids, []uint32{1}) {
+		t.Errorf("active namespaces %v, err %v, want [1]", ids, err)
+	}
+
+	// Allocated but inactive namespaces identify as zeroes and have no handle
+	if id, err := d.IdentifyNamespace(nsid); err != nil || id.Nsze != 0 {
+		t.Errorf("inactive namespace: NSZE %d, err %v", id.Nsze, err)
+	}
+	if _, err := d.Namespace(nsid); err == nil {
+		t.Error("handle to an inactive namespace")
+	}
+	if _, err := d.IdentifyNamespace(5); !nvme.IsInvalidNamespace(err) {
+		t.Errorf("NSID above NN: got %v, want Invalid Namespace", err)
+	}
+
+	if err := d.AttachNamespace(nsid, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ctrls, err := d.ListAttachedControllers(nsid); err != nil || len(ctrls) != 1 {
+		t.Errorf("attached controllers %v, err %v, want one", ctrls, err)
+	}
+
+	n, err := d.Namespace(nsid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Ident.Nsze != 1<<16 || n.BlockSize != 4096 {
+		t.Errorf("NSZE %d block size %d, want %d 4096", n.Ident.Nsze, n.BlockSize, 1<<16)
+	}
+
+	if err := d.AttachNamespace(nsid, nil); !nvme.IsStatus(err, nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_NS_ALREADY_ATTACHED) {
+		t.Errorf("second attach: got %v, want Namespace Already Attached", err)
+	}
+
+	if err := d.DetachNamespace(nsid, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteNamespace(nsid); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := d.ListAllocatedNamespaces(); err != nil || !slices.Equal(ids, []uint32{1}) {
+		t.Errorf("allocated namespaces %v, err %v, want [1]", ids, err)
+	}
+
+	if tr.rescans != 4 {
+		t.Errorf("%d rescans, want one per successful command", tr.rescans)
+	}
+}
+
+func TestNamespaceManagementRescanFailure(t *testing.T) {
+	cause := errors.New("rescan requires the controller device node")
+	tr := &rescanTransport{Controller: nvmesim.New(nsManagementConfig()), err: cause}
+	d := nvme.NewNVMeDeviceWithTransport("sim0", tr)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// The namespace is created and its NSID returned even though the rescan fails
+	nsid, err := d.CreateNamespace(nvme.NamespaceCreateParams{Size: 1 << 16})
+	if !errors.Is(err, nvme.ErrRescanFailed) || !errors.Is(err, cause) {
+		t.Fatalf("got %v, want a rescan failure", err)
+	}
+	if nsid != 2 {
+		t.Fatalf("created NSID %d, want 2", nsid)
+	}
+
+	if err := d.AttachNamespace(nsid, nil); !errors.Is(err, nvme.ErrRescanFailed) {
+		t.Errorf("attach: got %v, want a rescan failure", err)
+	}
+	if _, err := d.Namespace(nsid); err != nil {
+		t.Errorf("attached namespace: %v", err)
+	}
+
+	// Failed commands are not followed by a rescan
+	tr.rescans = 0
+	if err := d.DeleteNamespace(3); !nvme.IsInvalidNamespace(err) {
+		t.Errorf("delete of a missing namespace: got %v, want Invalid Namespace", err)
+	}
+	if tr.rescans != 0 {
+		t.Errorf("%d rescans after a failed command", tr.rescans)
+	}
+}
+
+func TestCreateNamespaceInvalid(t *testing.T) {
+	d, tr := newCountingDevice(t, nsManagementConfig())
+
+	for _, p := range []nvme.NamespaceCreateParams{
+		{},
+		{Size: 8, Capacity: 16},
+		{Size: 8, FormatIndex: 2},
+		{Size: 1 << 32, FormatIndex: 1}, // Exceeds the unallocated capacity
+		{Size: 1 << 62, Capacity: 8, FormatIndex: 1}, // Size in bytes overflows
+	} {
+		if _, err := d.CreateNamespace(p); err == nil {
+			t.Errorf("%+v accepted", p)
+		}
+	}
+
+	if c := tr.admin[nvme.NVME_ADMIN_NS_MANAGEMENT]; c != 0 {
+		t.Errorf("%d Namespace Management commands sent", c)
+	}
+}
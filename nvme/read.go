@@ -1,19 +1,20 @@
 package nvme
 
-import (
-	"unsafe"
-
-	"github.com/AaronFei/go-nvme/ioctl"
-)
-
+// Read reads length logical blocks starting at lba from the namespace implied by the device node.
 func (d *NVMeDevice) Read(lba uint64, length uint16, buf []byte) error {
+	return d.read(0, lba, length, buf)
+}
+
+func (d *NVMeDevice) read(nsid uint32, lba uint64, length uint16, buf []byte) error {
 
-	cmd := nvmeUserIo{
-		opcode:  NVME_NVM_CMD_READ,
-		slba:    lba,
-		addr:    uint64(uintptr(unsafe.Pointer(&(buf)[0]))),
-		nblocks: length - 1,
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_READ,
+		Nsid:   nsid,
+		Data:   buf,
+		Cdw10:  uint32(lba),
+		Cdw11:  uint32(lba >> 32),
+		Cdw12:  uint32(length - 1),
 	}
 
-	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_SUBMIT_IO, uintptr(unsafe.Pointer(&cmd)))
+	return d.transport.SubmitIO(&cmd)
 }
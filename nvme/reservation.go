@@ -0,0 +1,430 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// Reservation Types. RESV_TYPE_NONE is reported when no reservation is held.
+	RESV_TYPE_NONE                 uint8 = 0x0
+	RESV_TYPE_WRITE_EXCLUSIVE      uint8 = 0x1
+	RESV_TYPE_EXCLUSIVE_ACCESS     uint8 = 0x2
+	RESV_TYPE_WRITE_EXCLUSIVE_RO   uint8 = 0x3 // Registrants Only
+	RESV_TYPE_EXCLUSIVE_ACCESS_RO  uint8 = 0x4
+	RESV_TYPE_WRITE_EXCLUSIVE_ALL  uint8 = 0x5 // All Registrants
+	RESV_TYPE_EXCLUSIVE_ACCESS_ALL uint8 = 0x6
+
+	// Reservation Register Actions
+	RESV_REGISTER            uint8 = 0x0
+	RESV_REGISTER_UNREGISTER uint8 = 0x1
+	RESV_REGISTER_REPLACE    uint8 = 0x2
+
+	// Change Persist Through Power Loss State of Reservation Register
+	RESV_PTPL_NO_CHANGE uint8 = 0x0
+	RESV_PTPL_CLEAR     uint8 = 0x2
+	RESV_PTPL_SET       uint8 = 0x3
+
+	// Reservation Acquire Actions
+	RESV_ACQUIRE                   uint8 = 0x0
+	RESV_ACQUIRE_PREEMPT           uint8 = 0x1
+	RESV_ACQUIRE_PREEMPT_AND_ABORT uint8 = 0x2
+
+	// Reservation Release Actions
+	RESV_RELEASE       uint8 = 0x0
+	RESV_RELEASE_CLEAR uint8 = 0x1
+
+	// Reservation Notification Log Page Types
+	RESV_NOTIFY_EMPTY                  uint8 = 0x0
+	RESV_NOTIFY_REGISTRATION_PREEMPTED uint8 = 0x1
+	RESV_NOTIFY_RESERVATION_RELEASED   uint8 = 0x2
+	RESV_NOTIFY_RESERVATION_PREEMPTED  uint8 = 0x3
+)
+
+const (
+	reservationHeaderSize             = 24
+	reservationExtendedHeaderSize     = 64
+	reservationRegistrantSize         = 24
+	reservationExtendedRegistrantSize = 64
+
+	// Registrants read by the first Reservation Report; more need a second one
+	reservationRegistrantReadAhead = 32
+)
+
+var ReservationRegisterCdw10BitInfo = cdwBitInfo{
+	{
+		name: "RREGA", bitStart: 0,
+	},
+	{
+		name: "IEKEY", bitStart: 3,
+	},
+	{
+		name: "CPTPL", bitStart: 30,
+	},
+}
+
+type ReservationRegisterCdw10 struct {
+	RREGA uint32
+	IEKEY uint32
+	CPTPL uint32
+}
+
+// ReservationAcquireCdw10BitInfo also describes CDW10 of Reservation Release, whose RRELA field
+// takes the place of RACQA.
+var ReservationAcquireCdw10BitInfo = cdwBitInfo{
+	{
+		name: "RACQA", bitStart: 0,
+	},
+	{
+		name: "IEKEY", bitStart: 3,
+	},
+	{
+		name: "RTYPE", bitStart: 8,
+	},
+}
+
+type ReservationAcquireCdw10 struct {
+	RACQA uint32
+	IEKEY uint32
+	RTYPE uint32
+}
+
+// ReservationRegisterParams describes a Reservation Register command.
+type ReservationRegisterParams struct {
+	Action            uint8  // RESV_REGISTER*
+	CurrentKey        uint64 // Ignored when registering, or if IgnoreExistingKey is set
+	NewKey            uint64 // Key to register or replace the current key with
+	IgnoreExistingKey bool
+	PTPL              uint8 // RESV_PTPL_*
+}
+
+// ReservationAcquireParams describes a Reservation Acquire command.
+type ReservationAcquireParams struct {
+	Action            uint8 // RESV_ACQUIRE*
+	Type              uint8 // RESV_TYPE_*
+	CurrentKey        uint64
+	PreemptKey        uint64 // Registration key to preempt, for the preempt actions
+	IgnoreExistingKey bool
+}
+
+// ReservationReleaseParams describes a Reservation Release command.
+type ReservationReleaseParams struct {
+	Action            uint8 // RESV_RELEASE*
+	Type              uint8 // Type of the reservation held, RESV_TYPE_*
+	CurrentKey        uint64
+	IgnoreExistingKey bool
+}
+
+// Registrant is a controller registered with a namespace.
+type Registrant struct {
+	Cntlid           uint16 // 0xffff if the controller's ID is not reported
+	HoldsReservation bool
+	HostID           []byte // 8 bytes, or 16 for an extended report
+	Key              uint64
+}
+
+// ReservationStatus is a decoded Reservation Report.
+type ReservationStatus struct {
+	Generation  uint32 // Incremented by every successful Register, Preempt and Clear
+	Type        uint8  // RESV_TYPE_*, RESV_TYPE_NONE if no reservation is held
+	PTPL        bool   // Persist Through Power Loss state
+	Registrants []Registrant
+}
+
+// Holder returns the registrant that holds the reservation, if any. For all registrants
+// reservation types every registrant holds it, and the first one is returned.
+func (s *ReservationStatus) Holder() (Registrant, bool) {
+	for _, r := range s.Registrants {
+		if r.HoldsReservation {
+			return r, true
+		}
+	}
+
+	return Registrant{}, false
+}
+
+// ReservationNotification is an entry of the Reservation Notification log page.
+type ReservationNotification struct {
+	Lpc    uint64 // Log Page Count, 0 if the log is empty
+	Rnlpt  uint8  // Reservation Notification Log Page Type, RESV_NOTIFY_*
+	Nalp   uint8  // Number of Available Log Pages after this one
+	Rsvd10 [2]byte
+	Nsid   uint32
+	Rsvd16 [48]byte
+} // 64 bytes
+
+// checkReservations verifies that the controller supports the reservation commands and that the
+// namespace reports reservation capabilities.
+func (n *NVMeNamespace) checkReservations(opcode uint8, what string) error {
+	if err := n.dev.checkOptionalCommand(ONCS_RESERVATIONS, opcode, what); err != nil {
+		return err
+	}
+
+	if n.Ident.Rescap&^RESCAP_IEKEY_1_3 == 0 {
+		return fmt.Errorf("%w: reservations on namespace %d", ErrNotSupported, n.Nsid)
+	}
+
+	return nil
+}
+
+// checkReservationType verifies that the namespace supports reservation type t.
+func (n *NVMeNamespace) checkReservationType(t uint8) error {
+	if t == RESV_TYPE_NONE || t > RESV_TYPE_EXCLUSIVE_ACCESS_ALL {
+		return fmt.Errorf("invalid reservation type %d", t)
+	}
+
+	if !n.Ident.HasRescap(1 << t) {
+		return fmt.Errorf("%w: reservation type %d", ErrNotSupported, t)
+	}
+
+	return nil
+}
+
+func (n *NVMeNamespace) submitReservation(opcode uint8, cdw10 uint32, data []byte) error {
+	cmd := Command{
+		Opcode: opcode,
+		Nsid:   n.Nsid,
+		Data:   data,
+		Cdw10:  cdw10,
+	}
+
+	return n.dev.transport.SubmitIO(&cmd)
+}
+
+// ReservationRegister registers, unregisters or replaces the reservation key of the host.
+func (n *NVMeNamespace) ReservationRegister(p ReservationRegisterParams) error {
+	if p.Action > RESV_REGISTER_REPLACE {
+		return fmt.Errorf("invalid reservation register action %d", p.Action)
+	}
+
+	if p.PTPL != RESV_PTPL_NO_CHANGE && p.PTPL != RESV_PTPL_CLEAR && p.PTPL != RESV_PTPL_SET {
+		return fmt.Errorf("invalid persist through power loss setting %d", p.PTPL)
+	}
+
+	if err := n.checkReservations(NVME_NVM_CMD_RESERVATION_REGISTER, "Reservation Register"); err != nil {
+		return err
+	}
+
+	if p.PTPL != RESV_PTPL_NO_CHANGE && !n.Ident.HasRescap(RESCAP_PTPL) {
+		return fmt.Errorf("%w: persist through power loss", ErrNotSupported)
+	}
+
+	var iekey uint32
+	if p.IgnoreExistingKey {
+		iekey = 1
+	}
+
+	data := make([]byte, 16)
+	NativeEndian.PutUint64(data[0:], p.CurrentKey)
+	NativeEndian.PutUint64(data[8:], p.NewKey)
+
+	cdw10 := buildCdw(ReservationRegisterCdw10BitInfo, ReservationRegisterCdw10{
+		RREGA: uint32(p.Action),
+		IEKEY: iekey,
+		CPTPL: uint32(p.PTPL),
+	})
+
+	return n.submitReservation(NVME_NVM_CMD_RESERVATION_REGISTER, cdw10, data)
+}
+
+// ReservationAcquire acquires a reservation, or preempts the reservation or registration of
+// PreemptKey. Preempt and abort additionally aborts commands of the preempted hosts.
+func (n *NVMeNamespace) ReservationAcquire(p ReservationAcquireParams) error {
+	if p.Action > RESV_ACQUIRE_PREEMPT_AND_ABORT {
+		return fmt.Errorf("invalid reservation acquire action %d", p.Action)
+	}
+
+	if err := n.checkReservations(NVME_NVM_CMD_RESERVATION_ACQUIRE, "Reservation Acquire"); err != nil {
+		return err
+	}
+
+	if err := n.checkReservationType(p.Type); err != nil {
+		return err
+	}
+
+	var iekey uint32
+	if p.IgnoreExistingKey {
+		iekey = 1
+	}
+
+	data := make([]byte, 16)
+	NativeEndian.PutUint64(data[0:], p.CurrentKey)
+	NativeEndian.PutUint64(data[8:], p.PreemptKey)
+
+	cdw10 := buildCdw(ReservationAcquireCdw10BitInfo, ReservationAcquireCdw10{
+		RACQA: uint32(p.Action),
+		IEKEY: iekey,
+		RTYPE: uint32(p.Type),
+	})
+
+	return n.submitReservation(NVME_NVM_CMD_RESERVATION_ACQUIRE, cdw10, data)
+}
+
+// ReservationRelease releases the reservation held by the host, or clears the reservation and
+// all registrations of the namespace.
+func (n *NVMeNamespace) ReservationRelease(p ReservationReleaseParams) error {
+	if p.Action > RESV_RELEASE_CLEAR {
+		return fmt.Errorf("invalid reservation release action %d", p.Action)
+	}
+
+	if err := n.checkReservations(NVME_NVM_CMD_RESERVATION_RELEASE, "Reservation Release"); err != nil {
+		return err
+	}
+
+	if p.Action == RESV_RELEASE {
+		if err := n.checkReservationType(p.Type); err != nil {
+			return err
+		}
+	}
+
+	var iekey uint32
+	if p.IgnoreExistingKey {
+		iekey = 1
+	}
+
+	data := make([]byte, 8)
+	NativeEndian.PutUint64(data, p.CurrentKey)
+
+	cdw10 := buildCdw(ReservationAcquireCdw10BitInfo, ReservationAcquireCdw10{
+		RACQA: uint32(p.Action),
+		IEKEY: iekey,
+		RTYPE: uint32(p.Type),
+	})
+
+	return n.submitReservation(NVME_NVM_CMD_RESERVATION_RELEASE, cdw10, data)
+}
+
+func (n *NVMeNamespace) reservationReport(extended bool, size int) ([]byte, error) {
+	var eds uint32
+	if extended {
+		eds = 1
+	}
+
+	buf := make([]byte, size)
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_RESERVATION_REPORT,
+		Nsid:   n.Nsid,
+		Data:   buf,
+		Cdw10:  uint32(size/4 - 1),
+		Cdw11:  eds,
+	}
+
+	if err := n.dev.transport.SubmitIO(&cmd); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// ReservationReport returns the reservation status of the namespace. An extended report returns
+// 128-bit host identifiers, which requires controller support (CTRATT bit 0).
+func (n *NVMeNamespace) ReservationReport(extended bool) (ReservationStatus, error) {
+	if err := n.checkReservations(NVME_NVM_CMD_RESERVATION_REPORT, "Reservation Report"); err != nil {
+		return ReservationStatus{}, err
+	}
+
+	hdrSize, entrySize := reservationHeaderSize, reservationRegistrantSize
+	if extended {
+		idCtrl, err := n.dev.cachedIdentifyController()
+		if err != nil {
+			return ReservationStatus{}, err
+		}
+
+		if !idCtrl.HasCtratt(CTRATT_HOST_ID_128) {
+			return ReservationStatus{}, fmt.Errorf("%w: extended host identifiers", ErrNotSupported)
+		}
+
+		hdrSize, entrySize = reservationExtendedHeaderSize, reservationExtendedRegistrantSize
+	}
+
+	// Read room for a typical number of registrants, and read again if there are more
+	buf, err := n.reservationReport(extended, hdrSize+reservationRegistrantReadAhead*entrySize)
+	if err != nil {
+		return ReservationStatus{}, err
+	}
+
+	regctl := int(NativeEndian.Uint16(buf[5:]))
+	if regctl > reservationRegistrantReadAhead {
+		if buf, err = n.reservationReport(extended, hdrSize+regctl*entrySize); err != nil {
+			return ReservationStatus{}, err
+		}
+	}
+
+	s := ReservationStatus{
+		Generation:  NativeEndian.Uint32(buf[0:]),
+		Type:        buf[4],
+		PTPL:        buf[9]&0x1 != 0,
+		Registrants: make([]Registrant, 0, regctl),
+	}
+
+	for i := 0; i < regctl && hdrSize+(i+1)*entrySize <= len(buf); i++ {
+		e := buf[hdrSize+i*entrySize:]
+		r := Registrant{
+			Cntlid:           NativeEndian.Uint16(e[0:]),
+			HoldsReservation: e[2]&0x1 != 0,
+		}
+
+		if extended {
+			r.Key = NativeEndian.Uint64(e[8:])
+			r.HostID = append([]byte(nil), e[16:32]...)
+		} else {
+			r.HostID = append([]byte(nil), e[8:16]...)
+			r.Key = NativeEndian.Uint64(e[16:])
+		}
+
+		s.Registrants = append(s.Registrants, r)
+	}
+
+	return s, nil
+}
+
+// GetReservationNotification reads the oldest entry of the Reservation Notification log page,
+// which removes it from the log. Nalp of the returned entry tells how many more are available.
+func (d *NVMeDevice) GetReservationNotification(ctx context.Context) (ReservationNotification, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return ReservationNotification{}, err
+	}
+
+	if !idCtrl.HasOncs(ONCS_RESERVATIONS) {
+		return ReservationNotification{}, fmt.Errorf("%w: reservations", ErrNotSupported)
+	}
+
+	if err := d.checkLogPage(LOGPAGE_RESERVATION_NOTIFICATION, "reservation notification log"); err != nil {
+		return ReservationNotification{}, err
+	}
+
+	buf, err := d.GetLogPage(ctx, LogRequest{Lid: LOGPAGE_RESERVATION_NOTIFICATION, Length: 64})
+	if err != nil {
+		return ReservationNotification{}, err
+	}
+
+	var rn ReservationNotification
+	binary.Read(bytes.NewBuffer(buf), NativeEndian, &rn)
+
+	return rn, nil
+}
+
+// GetReservationNotifications drains the Reservation Notification log page and returns its
+// entries, oldest first.
+func (d *NVMeDevice) GetReservationNotifications(ctx context.Context) ([]ReservationNotification, error) {
+	var entries []ReservationNotification
+
+	for {
+		rn, err := d.GetReservationNotification(ctx)
+		if err != nil {
+			return entries, err
+		}
+
+		if rn.Rnlpt == RESV_NOTIFY_EMPTY {
+			return entries, nil
+		}
+
+		entries = append(entries, rn)
+		if rn.Nalp == 0 {
+			return entries, nil
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package nvme_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func isReservationConflict(err error) bool {
+	return nvme.IsStatus(err, nvme.NVME_SCT_GENERIC, nvme.NVME_SC_RESERVATION_CONFLICT)
+}
+
+func TestReservations(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Reservations = true
+	n := newSimNamespace(t, cfg)
+
+	if err := n.ReservationRegister(nvme.ReservationRegisterParams{NewKey: 0xabc, PTPL: nvme.RESV_PTPL_SET}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ReservationAcquire(nvme.ReservationAcquireParams{Type: nvme.RESV_TYPE_WRITE_EXCLUSIVE, CurrentKey: 0xabc}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := n.ReservationReport(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Type != nvme.RESV_TYPE_WRITE_EXCLUSIVE || !s.PTPL || s.Generation != 1 || len(s.Registrants) != 1 {
+		t.Fatalf("report %+v", s)
+	}
+	if h, ok := s.Holder(); !ok || h.Key != 0xabc || len(h.HostID) != 8 {
+		t.Errorf("holder %+v, %v", h, ok)
+	}
+
+	ext, err := n.ReservationReport(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ext.Registrants) != 1 || ext.Registrants[0].Key != 0xabc || len(ext.Registrants[0].HostID) != 16 {
+		t.Errorf("extended report %+v", ext)
+	}
+
+	// Wrong keys and a different type conflict with the reservation held
+	if err := n.ReservationAcquire(nvme.ReservationAcquireParams{Type: nvme.RESV_TYPE_WRITE_EXCLUSIVE, CurrentKey: 0xdef}); !isReservationConflict(err) {
+		t.Errorf("acquire with a wrong key: got %v, want Reservation Conflict", err)
+	}
+	if err := n.ReservationAcquire(nvme.ReservationAcquireParams{Type: nvme.RESV_TYPE_EXCLUSIVE_ACCESS, CurrentKey: 0xabc}); !isReservationConflict(err) {
+		t.Errorf("acquire of another type: got %v, want Reservation Conflict", err)
+	}
+	if err := n.ReservationRegister(nvme.ReservationRegisterParams{Action: nvme.RESV_REGISTER_UNREGISTER, CurrentKey: 0xdef}); !isReservationConflict(err) {
+		t.Errorf("unregister with a wrong key: got %v, want Reservation Conflict", err)
+	}
+
+	if err := n.ReservationRegister(nvme.ReservationRegisterParams{Action: nvme.RESV_REGISTER_REPLACE, CurrentKey: 0xabc, NewKey: 0x123}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.ReservationRelease(nvme.ReservationReleaseParams{Type: nvme.RESV_TYPE_WRITE_EXCLUSIVE, CurrentKey: 0x123}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = n.ReservationReport(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Holder(); ok || s.Type != nvme.RESV_TYPE_NONE || s.Generation != 2 {
+		t.Errorf("report after release %+v", s)
+	}
+
+	if err := n.ReservationRelease(nvme.ReservationReleaseParams{Action: nvme.RESV_RELEASE_CLEAR, CurrentKey: 0x123}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = n.ReservationReport(false); err != nil || len(s.Registrants) != 0 {
+		t.Errorf("report after clear %+v, %v", s, err)
+	}
+
+	// A single host never receives notifications
+	rn, err := n.Device().GetReservationNotifications(context.Background())
+	if err != nil || len(rn) != 0 {
+		t.Errorf("notifications %+v, %v", rn, err)
+	}
+}
+
+func TestReservationsInvalid(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Reservations = true
+	n := newSimNamespace(t, cfg)
+
+	if err := n.ReservationRegister(nvme.ReservationRegisterParams{Action: 3}); err == nil {
+		t.Error("invalid register action accepted")
+	}
+	if err := n.ReservationRegister(nvme.ReservationRegisterParams{PTPL: 1}); err == nil {
+		t.Error("invalid PTPL setting accepted")
+	}
+	if err := n.ReservationAcquire(nvme.ReservationAcquireParams{Type: nvme.RESV_TYPE_NONE}); err == nil {
+		t.Error("reservation type none accepted")
+	}
+	if err := n.ReservationAcquire(nvme.ReservationAcquireParams{Type: nvme.RESV_TYPE_WRITE_EXCLUSIVE}); !isReservationConflict(err) {
+		t.Errorf("acquire without registering: got %v, want Reservation Conflict", err)
+	}
+
+	n = newSimNamespace(t, nvmesim.DefaultConfig())
+	if _, err := n.ReservationReport(false); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("no reservation support: got %v, want ErrNotSupported", err)
+	}
+	if _, err := n.Device().GetReservationNotification(context.Background()); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("no reservation support: got %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,266 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Sanitize Action
+	SANITIZE_ACTION_EXIT_FAILURE uint8 = 0x1
+	SANITIZE_ACTION_BLOCK_ERASE  uint8 = 0x2
+	SANITIZE_ACTION_OVERWRITE    uint8 = 0x3
+	SANITIZE_ACTION_CRYPTO_ERASE uint8 = 0x4
+
+	// Sanitize Operation Status, SSTAT bits 2:0 of the Sanitize Status log
+	SANITIZE_STATE_NEVER                uint8 = 0x0
+	SANITIZE_STATE_COMPLETED            uint8 = 0x1
+	SANITIZE_STATE_IN_PROGRESS          uint8 = 0x2
+	SANITIZE_STATE_FAILED               uint8 = 0x3
+	SANITIZE_STATE_COMPLETED_NO_DEALLOC uint8 = 0x4
+)
+
+// ErrSanitizeFailed is returned, wrapped, by WaitSanitize when the sanitize operation failed. The
+// controller then only accepts a sanitize with SANITIZE_ACTION_EXIT_FAILURE or a new sanitize.
+var ErrSanitizeFailed = errors.New("sanitize operation failed")
+
+var SanitizeCdw10BitInfo = cdwBitInfo{
+	{
+		name: "SANACT", bitStart: 0,
+	},
+	{
+		name: "AUSE", bitStart: 3,
+	},
+	{
+		name: "OWPASS", bitStart: 4,
+	},
+	{
+		name: "OIPBP", bitStart: 8,
+	},
+	{
+		name: "NDAS", bitStart: 9,
+	},
+}
+
+type SanitizeCdw10 struct {
+	SANACT uint32
+	AUSE   uint32
+	OWPASS uint32
+	OIPBP  uint32
+	NDAS   uint32
+}
+
+// SanitizeParams describes the sanitize operation started by Sanitize.
+type SanitizeParams struct {
+	Action                uint8  // SANITIZE_ACTION_*
+	AllowUnrestrictedExit bool   // A failed operation may be exited without restarting it
+	OverwritePasses       uint8  // 1 to 16, 0 for 16
+	OverwriteInvert       bool   // Invert the pattern between passes
+	OverwritePattern      uint32 // Data pattern written by the overwrite action
+	NoDeallocate          bool   // Do not deallocate the media after sanitizing
+}
+
+// LogPageSanitizeStatus is the Sanitize Status log page.
+type LogPageSanitizeStatus struct {
+	Sprog  uint16 // Sanitize Progress, fraction of 65536
+	Sstat  uint16 // Sanitize Status
+	Scdw10 uint32 // CDW10 of the most recent Sanitize command
+	Eto    uint32 // Estimated time for overwrite, in seconds
+	Etbe   uint32 // Estimated time for block erase
+	Etce   uint32 // Estimated time for crypto erase
+	Etond  uint32 // Estimated time for overwrite with No-Deallocate
+	Etbend uint32 // Estimated time for block erase with No-Deallocate
+	Etcend uint32 // Estimated time for crypto erase with No-Deallocate
+	Rsvd32 [480]byte
+} // 512 bytes
+
+// Progress returns the fraction of the sanitize operation completed, from 0 to 1. It is 1 unless
+// an operation is in progress.
+func (s *LogPageSanitizeStatus) Progress() float64 {
+	if s.State() != SANITIZE_STATE_IN_PROGRESS {
+		return 1
+	}
+
+	return float64(s.Sprog) / 65536
+}
+
+// State returns the status of the most recent sanitize operation, SANITIZE_STATE_*.
+func (s *LogPageSanitizeStatus) State() uint8 {
+	return uint8(getBitsValue(uint64(s.Sstat), 0, 2))
+}
+
+// CompletedPasses returns the number of completed passes of an overwrite operation.
+func (s *LogPageSanitizeStatus) CompletedPasses() uint8 {
+	return uint8(getBitsValue(uint64(s.Sstat), 3, 7))
+}
+
+// GlobalDataErased reports whether no user data has been written since the last sanitize or
+// manufacture.
+func (s *LogPageSanitizeStatus) GlobalDataErased() bool {
+	return getBitsValue(uint64(s.Sstat), 8, 8) != 0
+}
+
+// EstimatedTime returns the time the controller estimates for action. ok is false if the
+// controller does not report an estimate.
+func (s *LogPageSanitizeStatus) EstimatedTime(action uint8, noDealloc bool) (d time.Duration, ok bool) {
+	var t uint32
+
+	switch action {
+	case SANITIZE_ACTION_OVERWRITE:
+		t = s.Eto
+		if noDealloc {
+			t = s.Etond
+		}
+	case SANITIZE_ACTION_BLOCK_ERASE:
+		t = s.Etbe
+		if noDealloc {
+			t = s.Etbend
+		}
+	case SANITIZE_ACTION_CRYPTO_ERASE:
+		t = s.Etce
+		if noDealloc {
+			t = s.Etcend
+		}
+	default:
+		return 0, false
+	}
+
+	// 0xffffffff means no estimate, and the No-Deallocate fields are 0 if not reported
+	if t == 0xffffffff || t == 0 {
+		return 0, false
+	}
+
+	return time.Duration(t) * time.Second, true
+}
+
+// Sanitize starts a sanitize operation, which affects all namespaces and runs in the background.
+// Use GetSanitizeStatus or WaitSanitize to follow it.
+func (d *NVMeDevice) Sanitize(p SanitizeParams) error {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return err
+	}
+
+	if idCtrl.Sanicap&(SANICAP_CRYPTO_ERASE|SANICAP_BLOCK_ERASE|SANICAP_OVERWRITE) == 0 {
+		return fmt.Errorf("%w: sanitize", ErrNotSupported)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_SANITIZE, "sanitize"); err != nil {
+		return err
+	}
+
+	switch p.Action {
+	case SANITIZE_ACTION_EXIT_FAILURE:
+	case SANITIZE_ACTION_BLOCK_ERASE:
+		if !idCtrl.HasSanicap(SANICAP_BLOCK_ERASE) {
+			return fmt.Errorf("%w: block erase sanitize", ErrNotSupported)
+		}
+	case SANITIZE_ACTION_OVERWRITE:
+		if !idCtrl.HasSanicap(SANICAP_OVERWRITE) {
+			return fmt.Errorf("%w: overwrite sanitize", ErrNotSupported)
+		}
+	case SANITIZE_ACTION_CRYPTO_ERASE:
+		if !idCtrl.HasSanicap(SANICAP_CRYPTO_ERASE) {
+			return fmt.Errorf("%w: crypto erase sanitize", ErrNotSupported)
+		}
+	default:
+		return fmt.Errorf("invalid sanitize action %d", p.Action)
+	}
+
+	if p.NoDeallocate && idCtrl.HasSanicap(SANICAP_NO_DEALLOC_INHB) {
+		return fmt.Errorf("%w: no-deallocate after sanitize", ErrNotSupported)
+	}
+
+	if p.OverwritePasses > 16 {
+		return fmt.Errorf("invalid overwrite pass count %d", p.OverwritePasses)
+	}
+
+	var ause, oipbp, ndas uint32
+	if p.AllowUnrestrictedExit {
+		ause = 1
+	}
+	if p.OverwriteInvert {
+		oipbp = 1
+	}
+	if p.NoDeallocate {
+		ndas = 1
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_SANITIZE,
+		Cdw10: buildCdw(SanitizeCdw10BitInfo, SanitizeCdw10{
+			SANACT: uint32(p.Action),
+			AUSE:   ause,
+			OWPASS: uint32(p.OverwritePasses & 0xf), // 0 means 16 passes
+			OIPBP:  oipbp,
+			NDAS:   ndas,
+		}),
+		Cdw11: p.OverwritePattern,
+	}
+
+	return d.transport.SubmitAdmin(&cmd)
+}
+
+// GetSanitizeStatus reads the Sanitize Status log page.
+func (d *NVMeDevice) GetSanitizeStatus() (LogPageSanitizeStatus, error) {
+	if err := d.checkLogPage(LOGPAGE_SANITIZE_STATUS, "sanitize status log"); err != nil {
+		return LogPageSanitizeStatus{}, err
+	}
+
+	buf := make([]byte, 512)
+
+	cdw10 := buildCdw(LogPageCdw10BitInfo, LogPageCdw10{
+		LID:   uint32(LOGPAGE_SANITIZE_STATUS),
+		NUMDL: ((uint32(len(buf)) / 4) - 1),
+	})
+
+	if err := d.GetLogPageRaw(0, cdw10, 0, 0, 0, 0, buf); err != nil {
+		return LogPageSanitizeStatus{}, err
+	}
+
+	var s LogPageSanitizeStatus
+	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &s)
+
+	return s, nil
+}
+
+// WaitSanitize polls the Sanitize Status log every interval until no sanitize operation is in
+// progress, calling progress (if not nil) after each poll. It returns the final status, and an
+// error wrapping ErrSanitizeFailed if the operation failed.
+func (d *NVMeDevice) WaitSanitize(ctx context.Context, interval time.Duration, progress func(LogPageSanitizeStatus)) (LogPageSanitizeStatus, error) {
+	if interval <= 0 {
+		return LogPageSanitizeStatus{}, fmt.Errorf("invalid polling interval %v", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s, err := d.GetSanitizeStatus()
+		if err != nil {
+			return s, err
+		}
+
+		if progress != nil {
+			progress(s)
+		}
+
+		switch s.State() {
+		case SANITIZE_STATE_IN_PROGRESS:
+		case SANITIZE_STATE_FAILED:
+			return s, fmt.Errorf("%w (SCDW10 %#x)", ErrSanitizeFailed, s.Scdw10)
+		default:
+			return s, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return s, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package nvme_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func sanitizeConfig() nvmesim.Config {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Sanicap = nvme.SANICAP_BLOCK_ERASE | nvme.SANICAP_CRYPTO_ERASE
+
+	return cfg
+}
+
+func TestSanitize(t *testing.T) {
+	d := newSimDevice(t, sanitizeConfig())
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Write(0, 1, pattern(512, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Sanitize(nvme.SanitizeParams{Action: nvme.SANITIZE_ACTION_BLOCK_ERASE}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 512)
+	if err := n.Read(0, 1, buf); !nvme.IsStatus(err, nvme.NVME_SCT_GENERIC, nvme.NVME_SC_SANITIZE_IN_PROGRESS) {
+		t.Errorf("read during sanitize: got %v, want Sanitize In Progress", err)
+	}
+
+	polls := 0
+	s, err := d.WaitSanitize(context.Background(), time.Millisecond, func(nvme.LogPageSanitizeStatus) { polls++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.State() != nvme.SANITIZE_STATE_COMPLETED || s.Progress() != 1 || polls < 2 {
+		t.Errorf("state %d progress %v after %d polls", s.State(), s.Progress(), polls)
+	}
+
+	if err := n.Read(0, 1, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, make([]byte, 512)) {
+		t.Error("data survived the sanitize")
+	}
+
+	if err := d.Sanitize(nvme.SanitizeParams{Action: nvme.SANITIZE_ACTION_OVERWRITE}); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("overwrite: got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestWaitSanitizeFailure(t *testing.T) {
+	cfg := sanitizeConfig()
+	cfg.SanitizeFailure = true
+	d := newSimDevice(t, cfg)
+
+	if err := d.Sanitize(nvme.SanitizeParams{Action: nvme.SANITIZE_ACTION_CRYPTO_ERASE}); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := d.WaitSanitize(context.Background(), time.Millisecond, nil)
+	if !errors.Is(err, nvme.ErrSanitizeFailed) || s.State() != nvme.SANITIZE_STATE_FAILED {
+		t.Fatalf("state %d, got %v, want ErrSanitizeFailed", s.State(), err)
+	}
+
+	if err := d.Sanitize(nvme.SanitizeParams{Action: nvme.SANITIZE_ACTION_EXIT_FAILURE}); err != nil {
+		t.Errorf("exit failure mode: %v", err)
+	}
+}
+
+func TestWaitSanitizeInterval(t *testing.T) {
+	d := newSimDevice(t, sanitizeConfig())
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := d.WaitSanitize(context.Background(), interval, nil); err == nil {
+			t.Errorf("interval %v accepted", interval)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// Self-test Code
+	SELF_TEST_SHORT    uint8 = 0x1
+	SELF_TEST_EXTENDED uint8 = 0x2
+	SELF_TEST_VENDOR   uint8 = 0xe
+	SELF_TEST_ABORT    uint8 = 0xf
+
+	// Self-test Result, DSTS bits 3:0 of a result entry
+	SELF_TEST_RESULT_PASSED             uint8 = 0x0
+	SELF_TEST_RESULT_ABORTED            uint8 = 0x1 // Aborted by a Device Self-test command
+	SELF_TEST_RESULT_ABORTED_RESET      uint8 = 0x2
+	SELF_TEST_RESULT_ABORTED_NS_REMOVED uint8 = 0x3
+	SELF_TEST_RESULT_ABORTED_FORMAT     uint8 = 0x4
+	SELF_TEST_RESULT_FATAL              uint8 = 0x5
+	SELF_TEST_RESULT_UNKNOWN_SEGMENT    uint8 = 0x6 // A segment failed, which one is unknown
+	SELF_TEST_RESULT_SEGMENT_FAILED     uint8 = 0x7
+	SELF_TEST_RESULT_ABORTED_UNKNOWN    uint8 = 0x8
+	SELF_TEST_RESULT_ABORTED_SANITIZE   uint8 = 0x9
+	SELF_TEST_RESULT_UNUSED             uint8 = 0xf
+
+	// Valid Diagnostic Information of a result entry
+	SELF_TEST_VDI_NSID uint8 = 1 << 0
+	SELF_TEST_VDI_FLBA uint8 = 1 << 1
+	SELF_TEST_VDI_SCT  uint8 = 1 << 2
+	SELF_TEST_VDI_SC   uint8 = 1 << 3
+)
+
+var (
+	// ErrSelfTestFailed is returned, wrapped, by WaitSelfTest when the self-test found a failure.
+	ErrSelfTestFailed = errors.New("device self-test failed")
+
+	// ErrSelfTestAborted is returned, wrapped, by WaitSelfTest when the self-test was aborted.
+	ErrSelfTestAborted = errors.New("device self-test aborted")
+
+	// ErrNoSelfTestResult is returned by WaitSelfTest when no self-test is in progress and the
+	// newest result entry is unused, e.g. because no self-test was started.
+	ErrNoSelfTestResult = errors.New("no device self-test result logged")
+)
+
+var selfTestResultMessages = map[uint8]string{
+	SELF_TEST_RESULT_PASSED:             "Completed without error",
+	SELF_TEST_RESULT_ABORTED:            "Aborted by a Device Self-test command",
+	SELF_TEST_RESULT_ABORTED_RESET:      "Aborted by a Controller Level Reset",
+	SELF_TEST_RESULT_ABORTED_NS_REMOVED: "Aborted due to a removal of a namespace",
+	SELF_TEST_RESULT_ABORTED_FORMAT:     "Aborted due to a Format NVM command",
+	SELF_TEST_RESULT_FATAL:              "Fatal or unknown test error",
+	SELF_TEST_RESULT_UNKNOWN_SEGMENT:    "Completed with an unknown failed segment",
+	SELF_TEST_RESULT_SEGMENT_FAILED:     "Completed with one or more failed segments",
+	SELF_TEST_RESULT_ABORTED_UNKNOWN:    "Aborted for unknown reason",
+	SELF_TEST_RESULT_ABORTED_SANITIZE:   "Aborted due to a sanitize operation",
+	SELF_TEST_RESULT_UNUSED:             "Entry not used",
+}
+
+var SelfTestCdw10BitInfo = cdwBitInfo{
+	{
+		name: "STC", bitStart: 0,
+	},
+}
+
+type SelfTestCdw10 struct {
+	STC uint32
+}
+
+// SelfTestResult is one entry of the Device Self-test log.
+type SelfTestResult struct {
+	Dsts         uint8 // Device Self-test Status: result in bits 3:0, self-test code in bits 7:4
+	Segment      uint8 // Number of the first failing segment
+	Vdi          uint8 // Valid Diagnostic Information
+	Rsvd3        uint8
+	PowerOnHours uint64
+	Nsid         uint32
+	FailingLba   uint64
+	Sct          uint8
+	Sc           uint8
+	Vs           [2]byte
+} // 28 bytes
+
+// LogPageSelfTest is the Device Self-test log page.
+type LogPageSelfTest struct {
+	CurrentOperation  uint8 // Self-test code of the operation in progress, 0 if none
+	CurrentCompletion uint8 // Percentage complete of the operation in progress
+	Rsvd2             [2]byte
+	Results           [20]SelfTestResult // Newest first
+} // 564 bytes
+
+// SelfTestResultMessage returns a description of a self-test result code.
+func SelfTestResultMessage(result uint8) string {
+	if msg, ok := selfTestResultMessages[result]; ok {
+		return msg
+	}
+
+	return fmt.Sprintf("Reserved result %#x", result)
+}
+
+// Result returns the self-test result, SELF_TEST_RESULT_*.
+func (r *SelfTestResult) Result() uint8 {
+	return uint8(getBitsValue(uint64(r.Dsts), 0, 3))
+}
+
+// Code returns the self-test code of the operation, SELF_TEST_*.
+func (r *SelfTestResult) Code() uint8 {
+	return uint8(getBitsValue(uint64(r.Dsts), 4, 7))
+}
+
+func (r *SelfTestResult) HasVdi(mask uint8) bool {
+	return r.Vdi&mask == mask
+}
+
+// Failed reports whether the self-test completed and found a failure.
+func (r *SelfTestResult) Failed() bool {
+	switch r.Result() {
+	case SELF_TEST_RESULT_FATAL, SELF_TEST_RESULT_UNKNOWN_SEGMENT, SELF_TEST_RESULT_SEGMENT_FAILED:
+		return true
+	}
+
+	return false
+}
+
+// Aborted reports whether the self-test was aborted before it completed.
+func (r *SelfTestResult) Aborted() bool {
+	switch r.Result() {
+	case SELF_TEST_RESULT_ABORTED, SELF_TEST_RESULT_ABORTED_RESET, SELF_TEST_RESULT_ABORTED_NS_REMOVED,
+		SELF_TEST_RESULT_ABORTED_FORMAT, SELF_TEST_RESULT_ABORTED_UNKNOWN, SELF_TEST_RESULT_ABORTED_SANITIZE:
+		return true
+	}
+
+	return false
+}
+
+// StatusMessage describes the status of the command that failed, if the entry reports one.
+func (r *SelfTestResult) StatusMessage() string {
+	if !r.HasVdi(SELF_TEST_VDI_SCT | SELF_TEST_VDI_SC) {
+		return ""
+	}
+
+	return StatusMessage(r.Sct&0x7, r.Sc)
+}
+
+// InProgress reports whether a self-test operation is in progress.
+func (l *LogPageSelfTest) InProgress() bool {
+	return l.CurrentOperation&0xf != 0
+}
+
+// Progress returns the percentage complete of the self-test in progress.
+func (l *LogPageSelfTest) Progress() uint8 {
+	return l.CurrentCompletion & 0x7f
+}
+
+// Entries returns the used result entries, newest first.
+func (l *LogPageSelfTest) Entries() []SelfTestResult {
+	var entries []SelfTestResult
+	for _, r := range l.Results {
+		if r.Result() != SELF_TEST_RESULT_UNUSED {
+			entries = append(entries, r)
+		}
+	}
+
+	return entries
+}
+
+func (d *NVMeDevice) selfTest(nsid uint32, code uint8) error {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return err
+	}
+
+	if !idCtrl.HasOacs(OACS_SELF_TEST) {
+		return fmt.Errorf("%w: device self-test", ErrNotSupported)
+	}
+
+	if err := d.checkAdminCommand(NVME_ADMIN_DEVICE_SELF_TEST, "device self-test"); err != nil {
+		return err
+	}
+
+	cmd := Command{
+		Opcode: NVME_ADMIN_DEVICE_SELF_TEST,
+		Nsid:   nsid,
+		Cdw10:  buildCdw(SelfTestCdw10BitInfo, SelfTestCdw10{STC: uint32(code)}),
+	}
+
+	return d.transport.SubmitAdmin(&cmd)
+}
+
+// StartSelfTest starts a self-test with code SELF_TEST_SHORT, SELF_TEST_EXTENDED or
+// SELF_TEST_VENDOR. nsid selects the namespace tested: 0 tests none, only the controller, and
+// NSID_ALL tests all active namespaces.
+func (d *NVMeDevice) StartSelfTest(nsid uint32, code uint8) error {
+	switch code {
+	case SELF_TEST_SHORT, SELF_TEST_EXTENDED, SELF_TEST_VENDOR:
+	default:
+		return fmt.Errorf("invalid self-test code %#x", code)
+	}
+
+	return d.selfTest(nsid, code)
+}
+
+// AbortSelfTest aborts the self-test in progress.
+func (d *NVMeDevice) AbortSelfTest() error {
+	return d.selfTest(NSID_ALL, SELF_TEST_ABORT)
+}
+
+// GetSelfTestLog reads the Device Self-test log page.
+func (d *NVMeDevice) GetSelfTestLog() (LogPageSelfTest, error) {
+	if err := d.checkLogPage(LOGPAGE_DEVICE_SELF_TEST, "device self-test log"); err != nil {
+		return LogPageSelfTest{}, err
+	}
+
+	buf := make([]byte, 564)
+
+	cdw10 := buildCdw(LogPageCdw10BitInfo, LogPageCdw10{
+		LID:   uint32(LOGPAGE_DEVICE_SELF_TEST),
+		NUMDL: ((uint32(len(buf)) / 4) - 1),
+	})
+
+	if err := d.GetLogPageRaw(0, cdw10, 0, 0, 0, 0, buf); err != nil {
+		return LogPageSelfTest{}, err
+	}
+
+	var l LogPageSelfTest
+	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &l)
+
+	return l, nil
+}
+
+// WaitSelfTest polls the Device Self-test log every interval until no self-test is in progress,
+// calling progress (if not nil) after each poll. It returns the newest result, and an error
+// wrapping ErrSelfTestFailed or ErrSelfTestAborted unless the self-test passed.
+func (d *NVMeDevice) WaitSelfTest(ctx context.Context, interval time.Duration, progress func(LogPageSelfTest)) (SelfTestResult, error) {
+	if interval <= 0 {
+		return SelfTestResult{}, fmt.Errorf("invalid polling interval %v", interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		l, err := d.GetSelfTestLog()
+		if err != nil {
+			return SelfTestResult{}, err
+		}
+
+		if progress != nil {
+			progress(l)
+		}
+
+		if !l.InProgress() {
+			r := l.Results[0]
+			switch {
+			case r.Result() == SELF_TEST_RESULT_UNUSED:
+				return r, ErrNoSelfTestResult
+			case r.Failed():
+				return r, fmt.Errorf("%w: %s (segment %d)", ErrSelfTestFailed, SelfTestResultMessage(r.Result()), r.Segment)
+			case r.Aborted():
+				return r, fmt.Errorf("%w: %s", ErrSelfTestAborted, SelfTestResultMessage(r.Result()))
+			}
+			return r, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return SelfTestResult{}, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package nvme_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestSelfTest(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	if err := d.StartSelfTest(1, nvme.SELF_TEST_EXTENDED); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.StartSelfTest(1, nvme.SELF_TEST_SHORT); !nvme.IsStatus(err, nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_SELF_TEST_IN_PROGRESS) {
+		t.Errorf("second self-test: got %v, want Device Self-test In Progress", err)
+	}
+
+	var progress []uint8
+	r, err := d.WaitSelfTest(context.Background(), time.Millisecond, func(l nvme.LogPageSelfTest) {
+		if l.InProgress() {
+			progress = append(progress, l.Progress())
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Result() != nvme.SELF_TEST_RESULT_PASSED || r.Code() != nvme.SELF_TEST_EXTENDED || r.Nsid != 1 {
+		t.Errorf("result %d code %d NSID %d, want a passed extended self-test of namespace 1", r.Result(), r.Code(), r.Nsid)
+	}
+	if len(progress) < 2 || progress[len(progress)-1] <= progress[0] {
+		t.Errorf("progress %v does not advance", progress)
+	}
+
+	// An aborted self-test is reported as such
+	if err := d.StartSelfTest(0, nvme.SELF_TEST_SHORT); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AbortSelfTest(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.WaitSelfTest(context.Background(), time.Millisecond, nil); !errors.Is(err, nvme.ErrSelfTestAborted) {
+		t.Errorf("got %v, want ErrSelfTestAborted", err)
+	}
+
+	l, err := d.GetSelfTestLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Entries()) != 2 {
+		t.Errorf("%d result entries, want 2", len(l.Entries()))
+	}
+}
+
+func TestWaitSelfTestFailure(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.SelfTestFailure = true
+	d := newSimDevice(t, cfg)
+
+	if err := d.StartSelfTest(nvme.NSID_ALL, nvme.SELF_TEST_SHORT); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := d.WaitSelfTest(context.Background(), time.Millisecond, nil)
+	if !errors.Is(err, nvme.ErrSelfTestFailed) || !r.Failed() || r.Segment != 2 {
+		t.Errorf("segment %d, got %v, want ErrSelfTestFailed in segment 2", r.Segment, err)
+	}
+}
+
+func TestWaitSelfTestNoResult(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	if _, err := d.WaitSelfTest(context.Background(), time.Millisecond, nil); !errors.Is(err, nvme.ErrNoSelfTestResult) {
+		t.Errorf("got %v, want ErrNoSelfTestResult", err)
+	}
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := d.WaitSelfTest(context.Background(), interval, nil); err == nil {
+			t.Errorf("interval %v accepted", interval)
+		}
+	}
+}
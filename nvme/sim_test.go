@@ -0,0 +1,74 @@
+package nvme_test
+
+import (
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+// newSimDevice returns an open device backed by a simulated controller, closed when the test ends.
+func newSimDevice(t *testing.T, cfg nvmesim.Config) *nvme.NVMeDevice {
+	t.Helper()
+
+	d := nvmesim.NewDevice("sim0", cfg)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	return d
+}
+
+// newSimNamespace returns a handle to namespace 1 of a new simulated controller.
+func newSimNamespace(t *testing.T, cfg nvmesim.Config) *nvme.NVMeNamespace {
+	t.Helper()
+
+	n, err := newSimDevice(t, cfg).Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+// pattern returns size bytes of a pattern that differs between seeds.
+func pattern(size int, seed byte) []byte {
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(i) ^ seed
+	}
+
+	return buf
+}
+
+// countingTransport is a simulated controller that counts the admin and I/O commands it receives
+// by opcode.
+type countingTransport struct {
+	*nvmesim.Controller
+	admin map[uint8]int
+	io    map[uint8]int
+}
+
+func newCountingDevice(t *testing.T, cfg nvmesim.Config) (*nvme.NVMeDevice, *countingTransport) {
+	t.Helper()
+
+	tr := &countingTransport{Controller: nvmesim.New(cfg), admin: make(map[uint8]int), io: make(map[uint8]int)}
+	d := nvme.NewNVMeDeviceWithTransport("sim0", tr)
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	return d, tr
+}
+
+func (t *countingTransport) SubmitAdmin(cmd *nvme.Command) error {
+	t.admin[cmd.Opcode]++
+	return t.Controller.SubmitAdmin(cmd)
+}
+
+func (t *countingTransport) SubmitIO(cmd *nvme.Command) error {
+	t.io[cmd.Opcode]++
+	return t.Controller.SubmitIO(cmd)
+}
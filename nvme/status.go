@@ -0,0 +1,304 @@
+package nvme
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// cf. NVM Express Base Specification 2.0c, figure 93: Status Code - Status Code Type Values
+	NVME_SCT_GENERIC      uint8 = 0x0
+	NVME_SCT_CMD_SPECIFIC uint8 = 0x1
+	NVME_SCT_MEDIA        uint8 = 0x2
+	NVME_SCT_PATH         uint8 = 0x3
+	NVME_SCT_VENDOR       uint8 = 0x7
+)
+
+const (
+	// Generic Command Status (SCT 0h)
+	NVME_SC_SUCCESS              uint8 = 0x00
+	NVME_SC_INVALID_OPCODE       uint8 = 0x01
+	NVME_SC_INVALID_FIELD        uint8 = 0x02
+	NVME_SC_CMDID_CONFLICT       uint8 = 0x03
+	NVME_SC_DATA_XFER_ERROR      uint8 = 0x04
+	NVME_SC_POWER_LOSS           uint8 = 0x05
+	NVME_SC_INTERNAL             uint8 = 0x06
+	NVME_SC_ABORT_REQ            uint8 = 0x07
+	NVME_SC_INVALID_NS           uint8 = 0x0b
+	NVME_SC_CMD_SEQ_ERROR        uint8 = 0x0c
+	NVME_SC_SANITIZE_FAILED      uint8 = 0x1c
+	NVME_SC_SANITIZE_IN_PROGRESS uint8 = 0x1d
+	NVME_SC_NS_WRITE_PROTECTED   uint8 = 0x20
+	NVME_SC_CMD_INTERRUPTED      uint8 = 0x21
+	NVME_SC_TRANSIENT_TR_ERR     uint8 = 0x22
+	NVME_SC_LBA_RANGE            uint8 = 0x80
+	NVME_SC_CAP_EXCEEDED         uint8 = 0x81
+	NVME_SC_NS_NOT_READY         uint8 = 0x82
+	NVME_SC_RESERVATION_CONFLICT uint8 = 0x83
+	NVME_SC_FORMAT_IN_PROGRESS   uint8 = 0x84
+
+	// Command Specific Status (SCT 1h)
+	NVME_SC_INVALID_FW_SLOT        uint8 = 0x06
+	NVME_SC_INVALID_FW_IMAGE       uint8 = 0x07
+	NVME_SC_INVALID_LOG_PAGE       uint8 = 0x09
+	NVME_SC_INVALID_FORMAT         uint8 = 0x0a
+	NVME_SC_FW_NEEDS_CONV_RESET    uint8 = 0x0b
+	NVME_SC_FEATURE_NOT_SAVEABLE   uint8 = 0x0d
+	NVME_SC_FEATURE_NOT_CHANGEABLE uint8 = 0x0e
+	NVME_SC_FEATURE_NOT_PER_NS     uint8 = 0x0f
+	NVME_SC_FW_NEEDS_SUBSYS_RESET  uint8 = 0x10
+	NVME_SC_FW_NEEDS_RESET         uint8 = 0x11
+	NVME_SC_FW_NEEDS_MAX_TIME      uint8 = 0x12
+	NVME_SC_FW_ACTIVATE_PROHIBITED uint8 = 0x13
+	NVME_SC_OVERLAPPING_RANGE      uint8 = 0x14
+	NVME_SC_NS_INSUFFICIENT_CAP    uint8 = 0x15
+	NVME_SC_NS_ID_UNAVAILABLE      uint8 = 0x16
+	NVME_SC_NS_ALREADY_ATTACHED    uint8 = 0x18
+	NVME_SC_NS_IS_PRIVATE          uint8 = 0x19
+	NVME_SC_NS_NOT_ATTACHED        uint8 = 0x1a
+	NVME_SC_CTRL_LIST_INVALID      uint8 = 0x1c
+	NVME_SC_SELF_TEST_IN_PROGRESS  uint8 = 0x1d
+	NVME_SC_CONFLICTING_ATTRS      uint8 = 0x80
+	NVME_SC_INVALID_PI             uint8 = 0x81
+	NVME_SC_READ_ONLY_RANGE        uint8 = 0x82
+	NVME_SC_CMD_SIZE_LIMIT         uint8 = 0x83
+
+	// Media and Data Integrity Errors (SCT 2h)
+	NVME_SC_WRITE_FAULT              uint8 = 0x80
+	NVME_SC_UNRECOVERED_READ         uint8 = 0x81
+	NVME_SC_GUARD_CHECK              uint8 = 0x82
+	NVME_SC_APPTAG_CHECK             uint8 = 0x83
+	NVME_SC_REFTAG_CHECK             uint8 = 0x84
+	NVME_SC_COMPARE_FAILED           uint8 = 0x85
+	NVME_SC_ACCESS_DENIED            uint8 = 0x86
+	NVME_SC_DEALLOCATED_OR_UNWRITTEN uint8 = 0x87
+)
+
+type statusKey struct {
+	sct uint8
+	sc  uint8
+}
+
+// statusMessages catalogs the status codes defined by the NVM Express Base Specification 2.0c
+// (figures 94 - 97) and the NVM Command Set Specification 1.0c.
+var statusMessages = map[statusKey]string{
+	{NVME_SCT_GENERIC, 0x00}: "Successful Completion",
+	{NVME_SCT_GENERIC, 0x01}: "Invalid Command Opcode",
+	{NVME_SCT_GENERIC, 0x02}: "Invalid Field in Command",
+	{NVME_SCT_GENERIC, 0x03}: "Command ID Conflict",
+	{NVME_SCT_GENERIC, 0x04}: "Data Transfer Error",
+	{NVME_SCT_GENERIC, 0x05}: "Commands Aborted due to Power Loss Notification",
+	{NVME_SCT_GENERIC, 0x06}: "Internal Error",
+	{NVME_SCT_GENERIC, 0x07}: "Command Abort Requested",
+	{NVME_SCT_GENERIC, 0x08}: "Command Aborted due to SQ Deletion",
+	{NVME_SCT_GENERIC, 0x09}: "Command Aborted due to Failed Fused Command",
+	{NVME_SCT_GENERIC, 0x0a}: "Command Aborted due to Missing Fused Command",
+	{NVME_SCT_GENERIC, 0x0b}: "Invalid Namespace or Format",
+	{NVME_SCT_GENERIC, 0x0c}: "Command Sequence Error",
+	{NVME_SCT_GENERIC, 0x0d}: "Invalid SGL Segment Descriptor",
+	{NVME_SCT_GENERIC, 0x0e}: "Invalid Number of SGL Descriptors",
+	{NVME_SCT_GENERIC, 0x0f}: "Data SGL Length Invalid",
+	{NVME_SCT_GENERIC, 0x10}: "Metadata SGL Length Invalid",
+	{NVME_SCT_GENERIC, 0x11}: "SGL Descriptor Type Invalid",
+	{NVME_SCT_GENERIC, 0x12}: "Invalid Use of Controller Memory Buffer",
+	{NVME_SCT_GENERIC, 0x13}: "PRP Offset Invalid",
+	{NVME_SCT_GENERIC, 0x14}: "Atomic Write Unit Exceeded",
+	{NVME_SCT_GENERIC, 0x15}: "Operation Denied",
+	{NVME_SCT_GENERIC, 0x16}: "SGL Offset Invalid",
+	{NVME_SCT_GENERIC, 0x18}: "Host Identifier Inconsistent Format",
+	{NVME_SCT_GENERIC, 0x19}: "Keep Alive Timer Expired",
+	{NVME_SCT_GENERIC, 0x1a}: "Keep Alive Timeout Invalid",
+	{NVME_SCT_GENERIC, 0x1b}: "Command Aborted due to Preempt and Abort",
+	{NVME_SCT_GENERIC, 0x1c}: "Sanitize Failed",
+	{NVME_SCT_GENERIC, 0x1d}: "Sanitize In Progress",
+	{NVME_SCT_GENERIC, 0x1e}: "SGL Data Block Granularity Invalid",
+	{NVME_SCT_GENERIC, 0x1f}: "Command Not Supported for Queue in CMB",
+	{NVME_SCT_GENERIC, 0x20}: "Namespace is Write Protected",
+	{NVME_SCT_GENERIC, 0x21}: "Command Interrupted",
+	{NVME_SCT_GENERIC, 0x22}: "Transient Transport Error",
+	{NVME_SCT_GENERIC, 0x23}: "Command Prohibited by Command and Feature Lockdown",
+	{NVME_SCT_GENERIC, 0x24}: "Admin Command Media Not Ready",
+	{NVME_SCT_GENERIC, 0x80}: "LBA Out of Range",
+	{NVME_SCT_GENERIC, 0x81}: "Capacity Exceeded",
+	{NVME_SCT_GENERIC, 0x82}: "Namespace Not Ready",
+	{NVME_SCT_GENERIC, 0x83}: "Reservation Conflict",
+	{NVME_SCT_GENERIC, 0x84}: "Format In Progress",
+
+	{NVME_SCT_CMD_SPECIFIC, 0x00}: "Completion Queue Invalid",
+	{NVME_SCT_CMD_SPECIFIC, 0x01}: "Invalid Queue Identifier",
+	{NVME_SCT_CMD_SPECIFIC, 0x02}: "Invalid Queue Size",
+	{NVME_SCT_CMD_SPECIFIC, 0x03}: "Abort Command Limit Exceeded",
+	{NVME_SCT_CMD_SPECIFIC, 0x05}: "Asynchronous Event Request Limit Exceeded",
+	{NVME_SCT_CMD_SPECIFIC, 0x06}: "Invalid Firmware Slot",
+	{NVME_SCT_CMD_SPECIFIC, 0x07}: "Invalid Firmware Image",
+	{NVME_SCT_CMD_SPECIFIC, 0x08}: "Invalid Interrupt Vector",
+	{NVME_SCT_CMD_SPECIFIC, 0x09}: "Invalid Log Page",
+	{NVME_SCT_CMD_SPECIFIC, 0x0a}: "Invalid Format",
+	{NVME_SCT_CMD_SPECIFIC, 0x0b}: "Firmware Activation Requires Conventional Reset",
+	{NVME_SCT_CMD_SPECIFIC, 0x0c}: "Invalid Queue Deletion",
+	{NVME_SCT_CMD_SPECIFIC, 0x0d}: "Feature Identifier Not Saveable",
+	{NVME_SCT_CMD_SPECIFIC, 0x0e}: "Feature Not Changeable",
+	{NVME_SCT_CMD_SPECIFIC, 0x0f}: "Feature Not Namespace Specific",
+	{NVME_SCT_CMD_SPECIFIC, 0x10}: "Firmware Activation Requires NVM Subsystem Reset",
+	{NVME_SCT_CMD_SPECIFIC, 0x11}: "Firmware Activation Requires Controller Level Reset",
+	{NVME_SCT_CMD_SPECIFIC, 0x12}: "Firmware Activation Requires Maximum Time Violation",
+	{NVME_SCT_CMD_SPECIFIC, 0x13}: "Firmware Activation Prohibited",
+	{NVME_SCT_CMD_SPECIFIC, 0x14}: "Overlapping Range",
+	{NVME_SCT_CMD_SPECIFIC, 0x15}: "Namespace Insufficient Capacity",
+	{NVME_SCT_CMD_SPECIFIC, 0x16}: "Namespace Identifier Unavailable",
+	{NVME_SCT_CMD_SPECIFIC, 0x18}: "Namespace Already Attached",
+	{NVME_SCT_CMD_SPECIFIC, 0x19}: "Namespace Is Private",
+	{NVME_SCT_CMD_SPECIFIC, 0x1a}: "Namespace Not Attached",
+	{NVME_SCT_CMD_SPECIFIC, 0x1b}: "Thin Provisioning Not Supported",
+	{NVME_SCT_CMD_SPECIFIC, 0x1c}: "Controller List Invalid",
+	{NVME_SCT_CMD_SPECIFIC, 0x1d}: "Device Self-test In Progress",
+	{NVME_SCT_CMD_SPECIFIC, 0x1e}: "Boot Partition Write Prohibited",
+	{NVME_SCT_CMD_SPECIFIC, 0x1f}: "Invalid Controller Identifier",
+	{NVME_SCT_CMD_SPECIFIC, 0x20}: "Invalid Secondary Controller State",
+	{NVME_SCT_CMD_SPECIFIC, 0x21}: "Invalid Number of Controller Resources",
+	{NVME_SCT_CMD_SPECIFIC, 0x22}: "Invalid Resource Identifier",
+	{NVME_SCT_CMD_SPECIFIC, 0x23}: "Sanitize Prohibited While Persistent Memory Region is Enabled",
+	{NVME_SCT_CMD_SPECIFIC, 0x24}: "ANA Group Identifier Invalid",
+	{NVME_SCT_CMD_SPECIFIC, 0x25}: "ANA Attach Failed",
+	{NVME_SCT_CMD_SPECIFIC, 0x26}: "Insufficient Capacity",
+	{NVME_SCT_CMD_SPECIFIC, 0x27}: "Namespace Attachment Limit Exceeded",
+	{NVME_SCT_CMD_SPECIFIC, 0x28}: "Prohibition of Command Execution Not Supported",
+	{NVME_SCT_CMD_SPECIFIC, 0x29}: "I/O Command Set Not Supported",
+	{NVME_SCT_CMD_SPECIFIC, 0x2a}: "I/O Command Set Not Enabled",
+	{NVME_SCT_CMD_SPECIFIC, 0x2b}: "I/O Command Set Combination Rejected",
+	{NVME_SCT_CMD_SPECIFIC, 0x2c}: "Invalid I/O Command Set",
+	{NVME_SCT_CMD_SPECIFIC, 0x2d}: "Identifier Unavailable",
+	{NVME_SCT_CMD_SPECIFIC, 0x80}: "Conflicting Attributes",
+	{NVME_SCT_CMD_SPECIFIC, 0x81}: "Invalid Protection Information",
+	{NVME_SCT_CMD_SPECIFIC, 0x82}: "Attempted Write to Read Only Range",
+	{NVME_SCT_CMD_SPECIFIC, 0x83}: "Command Size Limit Exceeded",
+
+	{NVME_SCT_MEDIA, 0x80}: "Write Fault",
+	{NVME_SCT_MEDIA, 0x81}: "Unrecovered Read Error",
+	{NVME_SCT_MEDIA, 0x82}: "End-to-end Guard Check Error",
+	{NVME_SCT_MEDIA, 0x83}: "End-to-end Application Tag Check Error",
+	{NVME_SCT_MEDIA, 0x84}: "End-to-end Reference Tag Check Error",
+	{NVME_SCT_MEDIA, 0x85}: "Compare Failure",
+	{NVME_SCT_MEDIA, 0x86}: "Access Denied",
+	{NVME_SCT_MEDIA, 0x87}: "Deallocated or Unwritten Logical Block",
+	{NVME_SCT_MEDIA, 0x88}: "End-to-end Storage Tag Check Error",
+
+	{NVME_SCT_PATH, 0x00}: "Internal Path Error",
+	{NVME_SCT_PATH, 0x01}: "Asymmetric Access Persistent Loss",
+	{NVME_SCT_PATH, 0x02}: "Asymmetric Access Inaccessible",
+	{NVME_SCT_PATH, 0x03}: "Asymmetric Access Transition",
+	{NVME_SCT_PATH, 0x60}: "Controller Pathing Error",
+	{NVME_SCT_PATH, 0x70}: "Host Pathing Error",
+	{NVME_SCT_PATH, 0x71}: "Command Aborted By Host",
+}
+
+// StatusMessage returns the human-readable description of a status code.
+func StatusMessage(sct, sc uint8) string {
+	if msg, ok := statusMessages[statusKey{sct, sc}]; ok {
+		return msg
+	}
+
+	if sct == NVME_SCT_VENDOR {
+		return "Vendor Specific"
+	}
+
+	return "Unknown Status"
+}
+
+// StatusError is returned when a command completes with a non-successful NVMe status. It carries
+// the decoded Status Field and Dword 0 of the completion queue entry (cf. NVM Express Base
+// Specification 2.0c, figure 92: Completion Queue Entry: Status Field).
+type StatusError struct {
+	SCT    uint8  // Status Code Type
+	SC     uint8  // Status Code
+	CRD    uint8  // Command Retry Delay, selects CRDT1-3 from Identify Controller
+	More   bool   // More information available in the Error Information log
+	DNR    bool   // Do Not Retry
+	Result uint32 // Command specific completion dword 0
+}
+
+// NewStatusError decodes a Status Field without the phase tag (i.e. completion dword 3 bits 31:17
+// shifted down by 17, as returned by the Linux passthrough ioctls).
+func NewStatusError(status uint16, result uint32) *StatusError {
+	return &StatusError{
+		SC:     uint8(getBitsValue(uint64(status), 0, 7)),
+		SCT:    uint8(getBitsValue(uint64(status), 8, 10)),
+		CRD:    uint8(getBitsValue(uint64(status), 11, 12)),
+		More:   getBitsValue(uint64(status), 13, 13) != 0,
+		DNR:    getBitsValue(uint64(status), 14, 14) != 0,
+		Result: result,
+	}
+}
+
+// Status encodes the error back into a Status Field without the phase tag.
+func (e *StatusError) Status() uint16 {
+	s := uint16(e.SC) | uint16(e.SCT&0x7)<<8 | uint16(e.CRD&0x3)<<11
+	if e.More {
+		s |= 1 << 13
+	}
+	if e.DNR {
+		s |= 1 << 14
+	}
+
+	return s
+}
+
+func (e *StatusError) Message() string {
+	return StatusMessage(e.SCT, e.SC)
+}
+
+func (e *StatusError) Error() string {
+	var flags []string
+	if e.DNR {
+		flags = append(flags, "DNR")
+	}
+	if e.More {
+		flags = append(flags, "MORE")
+	}
+	if e.CRD != 0 {
+		flags = append(flags, fmt.Sprintf("CRD %d", e.CRD))
+	}
+
+	s := fmt.Sprintf("NVMe status: %s (SCT %#x, SC 0x%02x", e.Message(), e.SCT, e.SC)
+	if len(flags) > 0 {
+		s += ", " + strings.Join(flags, ", ")
+	}
+
+	return s + ")"
+}
+
+// IsStatus reports whether err is a StatusError with the given status code type and code.
+func IsStatus(err error, sct, sc uint8) bool {
+	var se *StatusError
+
+	return errors.As(err, &se) && se.SCT == sct && se.SC == sc
+}
+
+func IsInvalidOpcode(err error) bool {
+	return IsStatus(err, NVME_SCT_GENERIC, NVME_SC_INVALID_OPCODE)
+}
+
+func IsInvalidField(err error) bool {
+	return IsStatus(err, NVME_SCT_GENERIC, NVME_SC_INVALID_FIELD)
+}
+
+func IsInvalidNamespace(err error) bool {
+	return IsStatus(err, NVME_SCT_GENERIC, NVME_SC_INVALID_NS)
+}
+
+func IsLbaOutOfRange(err error) bool {
+	return IsStatus(err, NVME_SCT_GENERIC, NVME_SC_LBA_RANGE)
+}
+
+func IsCompareFailure(err error) bool {
+	return IsStatus(err, NVME_SCT_MEDIA, NVME_SC_COMPARE_FAILED)
+}
+
+// IsRetryable reports whether err is an NVMe status that the controller allows to be retried,
+// i.e. the Do Not Retry bit is clear.
+func IsRetryable(err error) bool {
+	var se *StatusError
+
+	return errors.As(err, &se) && !se.DNR
+}
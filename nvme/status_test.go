@@ -0,0 +1,104 @@
+package nvme_test
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+func TestNewStatusError(t *testing.T) {
+	for _, tc := range []struct {
+		status uint16
+		want   nvme.StatusError
+	}{
+		{0x0002, nvme.StatusError{SCT: nvme.NVME_SCT_GENERIC, SC: nvme.NVME_SC_INVALID_FIELD}},
+		{0x4002, nvme.StatusError{SCT: nvme.NVME_SCT_GENERIC, SC: nvme.NVME_SC_INVALID_FIELD, DNR: true}},
+		{0x2285, nvme.StatusError{SCT: nvme.NVME_SCT_MEDIA, SC: 0x85, More: true}},
+		{0x1109, nvme.StatusError{SCT: nvme.NVME_SCT_CMD_SPECIFIC, SC: nvme.NVME_SC_INVALID_LOG_PAGE, CRD: 2}},
+		{0x7fff, nvme.StatusError{SCT: nvme.NVME_SCT_VENDOR, SC: 0xff, CRD: 3, More: true, DNR: true}},
+	} {
+		se := nvme.NewStatusError(tc.status, 0x1234)
+		tc.want.Result = 0x1234
+		if *se != tc.want {
+			t.Errorf("status %#04x: decoded %+v, want %+v", tc.status, *se, tc.want)
+		}
+		if se.Status() != tc.status {
+			t.Errorf("status %#04x: encoded back as %#04x", tc.status, se.Status())
+		}
+	}
+}
+
+func TestStatusErrorText(t *testing.T) {
+	for _, tc := range []struct {
+		err  *nvme.StatusError
+		want string
+	}{
+		{&nvme.StatusError{SCT: nvme.NVME_SCT_GENERIC, SC: nvme.NVME_SC_INVALID_FIELD, DNR: true},
+			"NVMe status: Invalid Field in Command (SCT 0x0, SC 0x02, DNR)"},
+		{&nvme.StatusError{SCT: nvme.NVME_SCT_MEDIA, SC: 0x81, More: true, CRD: 1},
+			"NVMe status: Unrecovered Read Error (SCT 0x2, SC 0x81, MORE, CRD 1)"},
+		{&nvme.StatusError{SCT: nvme.NVME_SCT_VENDOR, SC: 0xc0},
+			"NVMe status: Vendor Specific (SCT 0x7, SC 0xc0)"},
+		{&nvme.StatusError{SCT: nvme.NVME_SCT_CMD_SPECIFIC, SC: 0x7f},
+			"NVMe status: Unknown Status (SCT 0x1, SC 0x7f)"},
+	} {
+		if got := tc.err.Error(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestIsStatus(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		is   func(error) bool
+		sct  uint8
+		sc   uint8
+	}{
+		{"IsInvalidOpcode", nvme.IsInvalidOpcode, nvme.NVME_SCT_GENERIC, nvme.NVME_SC_INVALID_OPCODE},
+		{"IsInvalidField", nvme.IsInvalidField, nvme.NVME_SCT_GENERIC, nvme.NVME_SC_INVALID_FIELD},
+		{"IsInvalidNamespace", nvme.IsInvalidNamespace, nvme.NVME_SCT_GENERIC, nvme.NVME_SC_INVALID_NS},
+		{"IsLbaOutOfRange", nvme.IsLbaOutOfRange, nvme.NVME_SCT_GENERIC, nvme.NVME_SC_LBA_RANGE},
+		{"IsCompareFailure", nvme.IsCompareFailure, nvme.NVME_SCT_MEDIA, nvme.NVME_SC_COMPARE_FAILED},
+	} {
+		err := fmt.Errorf("wrapped: %w", &nvme.StatusError{SCT: tc.sct, SC: tc.sc})
+		if !tc.is(err) || !nvme.IsStatus(err, tc.sct, tc.sc) {
+			t.Errorf("%s does not match a wrapped SCT %#x SC %#x", tc.name, tc.sct, tc.sc)
+		}
+
+		// Same code, other type
+		if tc.is(&nvme.StatusError{SCT: nvme.NVME_SCT_CMD_SPECIFIC, SC: tc.sc}) {
+			t.Errorf("%s matches SCT 0x1 SC %#x", tc.name, tc.sc)
+		}
+		if tc.is(errors.New("not a status")) || tc.is(nil) {
+			t.Errorf("%s matches a non-status error", tc.name)
+		}
+	}
+
+	if !nvme.IsRetryable(&nvme.StatusError{}) || nvme.IsRetryable(&nvme.StatusError{DNR: true}) || nvme.IsRetryable(syscall.EIO) {
+		t.Error("IsRetryable does not follow the DNR bit")
+	}
+}
+
+func TestCompletionError(t *testing.T) {
+	if err := nvme.CompletionError(0, nil, 0); err != nil {
+		t.Errorf("successful completion: %v", err)
+	}
+
+	// Errors of the ioctl itself are passed on unchanged
+	if err := nvme.CompletionError(0, syscall.ENOTTY, 0); err != syscall.ENOTTY {
+		t.Errorf("ioctl failure: got %v, want ENOTTY", err)
+	}
+
+	err := nvme.CompletionError(0x4181, nil, 7)
+	var se *nvme.StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("positive return: got %v, want a StatusError", err)
+	}
+	if se.SCT != nvme.NVME_SCT_CMD_SPECIFIC || se.SC != 0x81 || !se.DNR || se.Result != 7 {
+		t.Errorf("positive return decoded as %+v", *se)
+	}
+}
@@ -0,0 +1,141 @@
+package nvme
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	// TELEMETRY_BLOCK_SIZE is the unit of the data area last block fields
+	TELEMETRY_BLOCK_SIZE = 512
+
+	// Log Specific Parameter of the Telemetry Host-Initiated log
+	TELEMETRY_LSP_CREATE uint8 = 1 << 0
+)
+
+// TelemetryHeader is the header of the Telemetry Host-Initiated and Controller-Initiated log
+// pages, i.e. their first 512 byte block.
+type TelemetryHeader struct {
+	Lid       uint8
+	Rsvd1     [4]byte
+	IEEE      [3]byte
+	Da1lb     uint16 // Data Area 1 Last Block
+	Da2lb     uint16
+	Da3lb     uint16
+	Rsvd14    [2]byte
+	Da4lb     uint32
+	Rsvd20    [361]byte
+	HostDgn   uint8 // Host-Initiated Data Generation Number
+	CtrlAvail uint8 // Controller-Initiated Data Available
+	CtrlDgn   uint8 // Controller-Initiated Data Generation Number
+	ReasonID  [128]byte
+} // 512 bytes
+
+// LastBlock returns the last block of data area 1 to 4. Each data area ends where the next one
+// starts, so this is also the number of blocks after the header needed to hold areas 1 to area.
+func (h *TelemetryHeader) LastBlock(area uint8) uint32 {
+	switch area {
+	case 1:
+		return uint32(h.Da1lb)
+	case 2:
+		return uint32(h.Da2lb)
+	case 3:
+		return uint32(h.Da3lb)
+	case 4:
+		return h.Da4lb
+	}
+
+	return 0
+}
+
+// TelemetryOptions selects the telemetry log captured by CaptureTelemetry.
+type TelemetryOptions struct {
+	Controller bool  // Read the controller-initiated instead of the host-initiated log
+	Create     bool  // Host-initiated only: have the controller capture new telemetry data
+	DataArea   uint8 // Last data area to read, 1 to 4; 0 reads area 3, or 4 if it is enabled
+}
+
+// extendedTelemetryEnabled reports whether data area 4 is supported by the controller and
+// enabled by the host through the Host Behavior Support feature.
+func (d *NVMeDevice) extendedTelemetryEnabled(idCtrl *NvmeIdentController) bool {
+	if !idCtrl.HasLpa(LPA_TELEMETRY_AREA4) {
+		return false
+	}
+
+	hb, err := d.GetHostBehavior(FEATURE_SEL_CURRENT)
+
+	return err == nil && hb.ETDAS
+}
+
+// CaptureTelemetry reads a telemetry log page and writes it to w: the header followed by data
+// areas 1 through opts.DataArea. This is the layout nvme-cli's telemetry-log command produces. The
+// header is returned so callers can inspect the generation numbers and reason identifier.
+func (d *NVMeDevice) CaptureTelemetry(ctx context.Context, w io.Writer, opts TelemetryOptions) (TelemetryHeader, error) {
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		return TelemetryHeader{}, err
+	}
+
+	if !idCtrl.HasLpa(LPA_TELEMETRY) {
+		return TelemetryHeader{}, fmt.Errorf("%w: telemetry", ErrNotSupported)
+	}
+
+	lid, lsp := LOGPAGE_TELEMETRY_HOST, uint8(0)
+	if opts.Controller {
+		lid = LOGPAGE_TELEMETRY_CTRL
+	} else if opts.Create {
+		lsp = TELEMETRY_LSP_CREATE
+	}
+
+	if err := d.checkLogPage(lid, "telemetry log"); err != nil {
+		return TelemetryHeader{}, err
+	}
+
+	area := opts.DataArea
+	switch {
+	case area == 0:
+		area = 3
+		if d.extendedTelemetryEnabled(&idCtrl) {
+			area = 4
+		}
+	case area == 4:
+		if !d.extendedTelemetryEnabled(&idCtrl) {
+			return TelemetryHeader{}, fmt.Errorf("%w: telemetry data area 4 is not enabled", ErrNotSupported)
+		}
+	case area > 4:
+		return TelemetryHeader{}, fmt.Errorf("invalid telemetry data area %d", area)
+	}
+
+	// Only the header read may create new data, later reads must see the same snapshot
+	buf, err := d.GetLogPage(ctx, LogRequest{Lid: lid, Lsp: lsp, Rae: true, Length: TELEMETRY_BLOCK_SIZE})
+	if err != nil {
+		return TelemetryHeader{}, err
+	}
+
+	var hdr TelemetryHeader
+	binary.Read(bytes.NewBuffer(buf[:]), NativeEndian, &hdr)
+
+	if _, err := w.Write(buf); err != nil {
+		return hdr, err
+	}
+
+	last := hdr.LastBlock(area)
+	if last == 0 {
+		return hdr, nil
+	}
+
+	req := LogRequest{
+		Lid:    lid,
+		Offset: TELEMETRY_BLOCK_SIZE,
+		Length: int(last) * TELEMETRY_BLOCK_SIZE,
+	}
+
+	if err := d.streamLogPage(ctx, &idCtrl, req, w); err != nil {
+		return hdr, err
+	}
+
+	return hdr, nil
+}
@@ -0,0 +1,63 @@
+package nvme_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func TestCaptureTelemetry(t *testing.T) {
+	cfg := nvmesim.DefaultConfig()
+	cfg.Telemetry = true
+	cfg.Mdts = 1 // Capture in 8 KiB pieces
+	d := newSimDevice(t, cfg)
+
+	for _, c := range []struct {
+		opts      nvme.TelemetryOptions
+		lastBlock int
+		dgn       uint8
+	}{
+		{nvme.TelemetryOptions{Create: true}, 52, 1},
+		{nvme.TelemetryOptions{DataArea: 1}, 4, 1},
+		{nvme.TelemetryOptions{Create: true, DataArea: 2}, 20, 2},
+		{nvme.TelemetryOptions{Controller: true}, 52, 0},
+	} {
+		var b bytes.Buffer
+		h, err := d.CaptureTelemetry(context.Background(), &b, c.opts)
+		if err != nil {
+			t.Fatalf("%+v: %v", c.opts, err)
+		}
+
+		if b.Len() != (c.lastBlock+1)*nvme.TELEMETRY_BLOCK_SIZE {
+			t.Errorf("%+v: captured %d bytes, want %d blocks", c.opts, b.Len(), c.lastBlock+1)
+			continue
+		}
+		if h.LastBlock(3) != 52 || (!c.opts.Controller && h.HostDgn != c.dgn) {
+			t.Errorf("%+v: header %+v", c.opts, h)
+		}
+
+		data := b.Bytes()
+		for i := nvme.TELEMETRY_BLOCK_SIZE; i < len(data); i++ {
+			if data[i] != uint8(i/nvme.TELEMETRY_BLOCK_SIZE)^c.dgn {
+				t.Errorf("%+v: wrong data at offset %d", c.opts, i)
+				break
+			}
+		}
+	}
+
+	if _, err := d.CaptureTelemetry(context.Background(), &bytes.Buffer{}, nvme.TelemetryOptions{DataArea: 4}); err == nil {
+		t.Error("capture of data area 4 succeeded without extended telemetry")
+	}
+}
+
+func TestCaptureTelemetryNotSupported(t *testing.T) {
+	d := newSimDevice(t, nvmesim.DefaultConfig())
+
+	if _, err := d.CaptureTelemetry(context.Background(), &bytes.Buffer{}, nvme.TelemetryOptions{}); !errors.Is(err, nvme.ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+}
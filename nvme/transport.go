@@ -0,0 +1,42 @@
+package nvme
+
+// Command is a transport-independent NVMe command. The fields mirror the common command format
+// (NVM Express Base Specification 2.0c, figure 88), with the data and metadata pointers replaced
+// by Go slices so that transports which do not talk to a kernel driver can inspect them directly.
+type Command struct {
+	Opcode    uint8
+	Flags     uint8
+	Nsid      uint32
+	Cdw2      uint32
+	Cdw3      uint32
+	Metadata  []byte
+	Data      []byte
+	Cdw10     uint32
+	Cdw11     uint32
+	Cdw12     uint32
+	Cdw13     uint32
+	Cdw14     uint32
+	Cdw15     uint32
+	TimeoutMs uint32
+	Result    uint32 // Dword 0 of the completion queue entry, set by the transport
+}
+
+// Transport delivers commands to an NVMe controller. NVMeDevice dispatches every admin and I/O
+// command through its transport, so alternative implementations (fakes, recorders, remote
+// transports) can be plugged in with NewNVMeDeviceWithTransport.
+//
+// SubmitAdmin and SubmitIO must fill in cmd.Result on completion. For commands that transfer
+// data from the controller, the response is written into cmd.Data. A command that completes with
+// a non-successful NVMe status must be reported as a *StatusError.
+type Transport interface {
+	Open() error
+	Close() error
+	SubmitAdmin(cmd *Command) error
+	SubmitIO(cmd *Command) error
+}
+
+// Rescanner is implemented by transports that can make the host rediscover the controller's
+// namespaces, e.g. after they have been created, deleted, attached or detached.
+type Rescanner interface {
+	Rescan() error
+}
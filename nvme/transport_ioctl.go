@@ -0,0 +1,117 @@
+package nvme
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"github.com/AaronFei/go-nvme/ioctl"
+
+	"golang.org/x/sys/unix"
+)
+
+// IoctlTransport submits commands to the Linux NVMe driver via the passthrough ioctls of a
+// controller (/dev/nvmeX) or namespace (/dev/nvmeXnY) device node.
+type IoctlTransport struct {
+	Path string
+	fd   int
+	nsid uint32 // Namespace implied by the device node, 0 for controller nodes
+}
+
+func NewIoctlTransport(path string) *IoctlTransport {
+	return &IoctlTransport{Path: path, fd: -1}
+}
+
+func (t *IoctlTransport) Open() (err error) {
+	t.fd, err = unix.Open(t.Path, unix.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+
+	// Only namespace nodes answer NVME_IOCTL_ID, so an error simply means there is no implied NSID
+	if nsid, err := ioctl.IoctlRet(uintptr(t.fd), NVME_IOCTL_ID, 0); err == nil {
+		t.nsid = uint32(nsid)
+	}
+
+	return nil
+}
+
+func (t *IoctlTransport) Close() error {
+	err := unix.Close(t.fd)
+	t.fd = -1
+	return err
+}
+
+func (t *IoctlTransport) SubmitAdmin(cmd *Command) error {
+	return t.submit(NVME_IOCTL_ADMIN_CMD, cmd)
+}
+
+// SubmitIO submits an NVM command. A zero cmd.Nsid is replaced by the namespace of the device
+// node, if any.
+func (t *IoctlTransport) SubmitIO(cmd *Command) error {
+	if cmd.Nsid == 0 {
+		cmd.Nsid = t.nsid
+	}
+
+	return t.submit(NVME_IOCTL_IO_CMD, cmd)
+}
+
+// Rescan triggers a namespace rescan by the kernel. Only controller nodes support it.
+func (t *IoctlTransport) Rescan() error {
+	if t.nsid != 0 {
+		return fmt.Errorf("%s: namespace rescan requires the controller device node", t.Path)
+	}
+
+	return ioctl.Ioctl(uintptr(t.fd), NVME_IOCTL_RESCAN, 0)
+}
+
+func (t *IoctlTransport) submit(req uintptr, cmd *Command) error {
+	pt := nvmePassthruCommand{
+		opcode:       cmd.Opcode,
+		flags:        cmd.Flags,
+		nsid:         cmd.Nsid,
+		cdw2:         cmd.Cdw2,
+		cdw3:         cmd.Cdw3,
+		metadata:     bufAddr(cmd.Metadata),
+		addr:         bufAddr(cmd.Data),
+		metadata_len: uint32(len(cmd.Metadata)),
+		data_len:     uint32(len(cmd.Data)),
+		cdw10:        cmd.Cdw10,
+		cdw11:        cmd.Cdw11,
+		cdw12:        cmd.Cdw12,
+		cdw13:        cmd.Cdw13,
+		cdw14:        cmd.Cdw14,
+		cdw15:        cmd.Cdw15,
+		timeout_ms:   cmd.TimeoutMs,
+	}
+
+	status, err := ioctl.IoctlRet(uintptr(t.fd), req, uintptr(unsafe.Pointer(&pt)))
+	runtime.KeepAlive(cmd)
+
+	cmd.Result = pt.result
+
+	return completionError(status, err, pt.result)
+}
+
+// completionError converts the outcome of a passthrough ioctl into an error. A positive return
+// value is the NVMe status field of the completion.
+func completionError(status uintptr, err error, result uint32) error {
+	if err != nil {
+		return err
+	}
+
+	if status != 0 {
+		return NewStatusError(uint16(status), result)
+	}
+
+	return nil
+}
+
+// bufAddr returns the address of the first byte of buf, or 0 for an empty buffer.
+func bufAddr(buf []byte) uint64 {
+	if len(buf) == 0 {
+		return 0
+	}
+
+	return uint64(uintptr(unsafe.Pointer(&buf[0])))
+}
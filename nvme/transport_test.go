@@ -0,0 +1,127 @@
+package nvme_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// recordingTransport records the commands it receives and answers them with respond, if set.
+type recordingTransport struct {
+	open    bool
+	admin   []nvme.Command
+	io      []nvme.Command
+	respond func(cmd *nvme.Command) error
+}
+
+func (t *recordingTransport) Open() error {
+	t.open = true
+	return nil
+}
+
+func (t *recordingTransport) Close() error {
+	t.open = false
+	return nil
+}
+
+func (t *recordingTransport) SubmitAdmin(cmd *nvme.Command) error {
+	t.admin = append(t.admin, *cmd)
+	if t.respond != nil {
+		return t.respond(cmd)
+	}
+
+	return nil
+}
+
+func (t *recordingTransport) SubmitIO(cmd *nvme.Command) error {
+	t.io = append(t.io, *cmd)
+	if t.respond != nil {
+		return t.respond(cmd)
+	}
+
+	return nil
+}
+
+func TestTransportDispatch(t *testing.T) {
+	tr := &recordingTransport{
+		respond: func(cmd *nvme.Command) error {
+			if cmd.Opcode == nvme.NVME_ADMIN_IDENTIFY {
+				binary.LittleEndian.PutUint16(cmd.Data[0:], 0x1b36)
+				copy(cmd.Data[4:24], "SERIAL              ")
+			}
+			return nil
+		},
+	}
+	d := nvme.NewNVMeDeviceWithTransport("fake0", tr)
+
+	if d.Transport() != tr {
+		t.Error("Transport does not return the transport passed in")
+	}
+	if err := d.Open(); err != nil || !tr.open {
+		t.Fatalf("open: %v", err)
+	}
+
+	idCtrl, err := d.IdentifyController()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.admin) != 1 {
+		t.Fatalf("%d admin commands, want 1", len(tr.admin))
+	}
+	if c := tr.admin[0]; c.Opcode != nvme.NVME_ADMIN_IDENTIFY || c.Cdw10 != 1 || len(c.Data) != 4096 {
+		t.Errorf("identify command %+v", c)
+	}
+	if idCtrl.VendorID != 0x1b36 || d.ModelInfo.SerialNumber != "SERIAL" {
+		t.Errorf("VID %#x serial %q, want 0x1b36 SERIAL", idCtrl.VendorID, d.ModelInfo.SerialNumber)
+	}
+
+	// The starting LBA is split over CDW10 and CDW11, the block count is 0's based
+	if err := d.Read(0x123456789, 8, make([]byte, 4096)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write(7, 1, 0, make([]byte, 512)); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.io) != 2 {
+		t.Fatalf("%d I/O commands, want 2", len(tr.io))
+	}
+	if c := tr.io[0]; c.Opcode != nvme.NVME_NVM_CMD_READ || c.Cdw10 != 0x23456789 || c.Cdw11 != 0x1 || c.Cdw12 != 7 || len(c.Data) != 4096 {
+		t.Errorf("read command %+v", c)
+	}
+	if c := tr.io[1]; c.Opcode != nvme.NVME_NVM_CMD_WRITE || c.Cdw10 != 7 || c.Cdw11 != 0 || c.Cdw12 != 0 {
+		t.Errorf("write command %+v", c)
+	}
+
+	if err := d.GetLogPageRaw(1, 2, 3, 4, 5, 6, make([]byte, 512)); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.admin[1]; c.Opcode != nvme.NVME_ADMIN_GET_LOG_PAGE || c.Nsid != 1 || c.Cdw10 != 2 || c.Cdw11 != 3 || c.Cdw12 != 4 || c.Cdw13 != 5 || c.Cdw14 != 6 {
+		t.Errorf("get log page command %+v", c)
+	}
+
+	if err := d.Close(); err != nil || tr.open {
+		t.Errorf("close: %v", err)
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	errFake := errors.New("fake transport failure")
+	d := nvme.NewNVMeDeviceWithTransport("fake0", &recordingTransport{
+		respond: func(*nvme.Command) error { return errFake },
+	})
+
+	if _, err := d.IdentifyController(); !errors.Is(err, errFake) {
+		t.Errorf("identify: got %v, want the transport error", err)
+	}
+	if err := d.Read(0, 1, make([]byte, 512)); !errors.Is(err, errFake) {
+		t.Errorf("read: got %v, want the transport error", err)
+	}
+}
+
+func TestIoctlTransportOpenMissingNode(t *testing.T) {
+	if err := nvme.NewIoctlTransport("/nonexistent/nvme0").Open(); err == nil {
+		t.Error("opening a missing device node succeeded")
+	}
+}
@@ -0,0 +1,262 @@
+package nvme
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Uint128 is an unsigned 128-bit integer, as used by NVMe for capacities and SMART counters.
+// Its memory layout matches the little-endian on-the-wire encoding, so it can be decoded as part
+// of a struct with binary.Read.
+type Uint128 struct {
+	Lo uint64
+	Hi uint64
+}
+
+// Uint128FromLE decodes a 16-byte little-endian value.
+func Uint128FromLE(b []byte) Uint128 {
+	return Uint128{
+		Lo: binary.LittleEndian.Uint64(b[0:8]),
+		Hi: binary.LittleEndian.Uint64(b[8:16]),
+	}
+}
+
+// Uint128From64 returns v as a Uint128.
+func Uint128From64(v uint64) Uint128 {
+	return Uint128{Lo: v}
+}
+
+// PutLE encodes u as 16 little-endian bytes into b.
+func (u Uint128) PutLE(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:8], u.Lo)
+	binary.LittleEndian.PutUint64(b[8:16], u.Hi)
+}
+
+func (u Uint128) IsZero() bool {
+	return u.Lo == 0 && u.Hi == 0
+}
+
+// Cmp returns -1, 0 or +1 depending on whether u is less than, equal to or greater than v.
+func (u Uint128) Cmp(v Uint128) int {
+	switch {
+	case u.Hi < v.Hi, u.Hi == v.Hi && u.Lo < v.Lo:
+		return -1
+	case u == v:
+		return 0
+	}
+
+	return 1
+}
+
+// Add returns u+v, and whether the sum overflowed.
+func (u Uint128) Add(v Uint128) (Uint128, bool) {
+	lo, carry := bits.Add64(u.Lo, v.Lo, 0)
+	hi, carry := bits.Add64(u.Hi, v.Hi, carry)
+
+	return Uint128{Lo: lo, Hi: hi}, carry != 0
+}
+
+// Sub returns u-v, and whether v was greater than u (in which case the result wrapped around).
+// Use it to compute the delta between two samples of a counter.
+func (u Uint128) Sub(v Uint128) (Uint128, bool) {
+	lo, borrow := bits.Sub64(u.Lo, v.Lo, 0)
+	hi, borrow := bits.Sub64(u.Hi, v.Hi, borrow)
+
+	return Uint128{Lo: lo, Hi: hi}, borrow != 0
+}
+
+// Mul64 returns u*m, and whether the product overflowed.
+func (u Uint128) Mul64(m uint64) (Uint128, bool) {
+	hi, lo := bits.Mul64(u.Lo, m)
+	carry, hiLo := bits.Mul64(u.Hi, m)
+	hi, c := bits.Add64(hi, hiLo, 0)
+
+	return Uint128{Lo: lo, Hi: hi}, carry != 0 || c != 0
+}
+
+// div64 returns u/d and u%d.
+func (u Uint128) div64(d uint64) (Uint128, uint64) {
+	var q Uint128
+	var r uint64
+
+	q.Hi, r = bits.Div64(0, u.Hi, d)
+	q.Lo, r = bits.Div64(r, u.Lo, d)
+
+	return q, r
+}
+
+// Uint64 returns u as a uint64, and false if it does not fit.
+func (u Uint128) Uint64() (uint64, bool) {
+	return u.Lo, u.Hi == 0
+}
+
+// Bytes converts a count of unit-sized units to bytes, saturating at the maximum Uint128.
+func (u Uint128) Bytes(unit uint64) Uint128 {
+	b, overflow := u.Mul64(unit)
+	if overflow {
+		return Uint128{Lo: ^uint64(0), Hi: ^uint64(0)}
+	}
+
+	return b
+}
+
+// FormatBytes formats u as a byte count with a decimal unit suffix and 3 significant digits.
+func (u Uint128) FormatBytes() string {
+	suffixes := [...]string{"B", "KB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB", "RB", "QB"}
+
+	var i int
+	var r uint64
+	v := u
+	for ; i < len(suffixes)-1 && v.Cmp(Uint128From64(1000)) > 0; i++ {
+		v, r = v.div64(1000)
+	}
+
+	if i == 0 {
+		return fmt.Sprintf("%d %s", u.Lo, suffixes[i])
+	}
+
+	// Remaining value is at most 1000 after the loop, or the overflow of the largest suffix
+	n, _ := v.Uint64()
+	return fmt.Sprintf("%.3g %s", float64(n)+float64(r)/1000, suffixes[i])
+}
+
+// String returns u in decimal.
+func (u Uint128) String() string {
+	return u.chunks(10, 1e19, 19)
+}
+
+// chunks returns u in base, converting n digits at a time by dividing by div, which is base to
+// the power of n.
+func (u Uint128) chunks(base int, div uint64, n int) string {
+	if u.Hi == 0 {
+		return strconv.FormatUint(u.Lo, base)
+	}
+
+	q, r := u.div64(div)
+	digits := strconv.FormatUint(r, base)
+
+	return q.chunks(base, div, n) + strings.Repeat("0", n-len(digits)) + digits
+}
+
+// text returns u in base 2 or 16, whose digits split evenly between Hi and Lo.
+func (u Uint128) text(base int) string {
+	lo := strconv.FormatUint(u.Lo, base)
+	if u.Hi == 0 {
+		return lo
+	}
+
+	n := 64 / bits.Len(uint(base-1))
+
+	return strconv.FormatUint(u.Hi, base) + strings.Repeat("0", n-len(lo)) + lo
+}
+
+// Format implements fmt.Formatter for the integer verbs, honoring width, precision and flags.
+// %v and %s print the decimal value.
+func (u Uint128) Format(f fmt.State, verb rune) {
+	var digits, prefix string
+
+	switch verb {
+	case 'd', 'v', 's':
+		digits = u.String()
+	case 'x':
+		digits, prefix = u.text(16), "0x"
+	case 'X':
+		digits, prefix = strings.ToUpper(u.text(16)), "0X"
+	case 'b':
+		digits, prefix = u.text(2), "0b"
+	case 'o':
+		digits, prefix = u.chunks(8, 1<<63, 21), "0"
+	case 'O':
+		digits, prefix = u.chunks(8, 1<<63, 21), "0o"
+	default:
+		fmt.Fprintf(f, "%%!%c(nvme.Uint128=%s)", verb, u.String())
+		return
+	}
+
+	sign := ""
+	if f.Flag('+') {
+		sign = "+"
+	} else if f.Flag(' ') {
+		sign = " "
+	}
+
+	// As for the built-in integers, the 0 flag pads the digits to the width less the sign, and
+	// precision takes precedence over it
+	width, hasWidth := f.Width()
+	prec, hasPrec := f.Precision()
+	if !hasPrec && hasWidth && f.Flag('0') && !f.Flag('-') {
+		prec = width - len(sign)
+	}
+	if hasPrec && prec == 0 && u.IsZero() {
+		digits = ""
+	}
+	if len(digits) < prec {
+		digits = strings.Repeat("0", prec-len(digits)) + digits
+	}
+
+	if verb != 'O' && (!f.Flag('#') || verb == 'o' && strings.HasPrefix(digits, "0")) {
+		prefix = ""
+	}
+
+	out := sign + prefix + digits
+	if pad := width - len(out); pad > 0 {
+		if f.Flag('-') {
+			out += strings.Repeat(" ", pad)
+		} else {
+			out = strings.Repeat(" ", pad) + out
+		}
+	}
+
+	io.WriteString(f, out)
+}
+
+// ParseUint128 parses a decimal string.
+func ParseUint128(s string) (Uint128, error) {
+	if s == "" {
+		return Uint128{}, fmt.Errorf("invalid 128-bit integer %q", s)
+	}
+
+	var u Uint128
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return Uint128{}, fmt.Errorf("invalid 128-bit integer %q", s)
+		}
+
+		var o1, o2 bool
+		u, o1 = u.Mul64(10)
+		u, o2 = u.Add(Uint128From64(uint64(c - '0')))
+		if o1 || o2 {
+			return Uint128{}, fmt.Errorf("128-bit integer %q out of range", s)
+		}
+	}
+
+	return u, nil
+}
+
+func (u Uint128) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *Uint128) UnmarshalText(b []byte) (err error) {
+	*u, err = ParseUint128(string(b))
+	return err
+}
+
+// MarshalJSON encodes u as a JSON number. Decoders that store numbers as float64 lose precision
+// above 2^53.
+func (u Uint128) MarshalJSON() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a string holding a decimal number.
+func (u *Uint128) UnmarshalJSON(b []byte) error {
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		b = b[1 : len(b)-1]
+	}
+
+	return u.UnmarshalText(b)
+}
@@ -0,0 +1,78 @@
+package nvme_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+func TestUint128Format(t *testing.T) {
+	big := nvme.Uint128{Hi: 1, Lo: 2} // 2^64 + 2
+	max := nvme.Uint128{Hi: ^uint64(0), Lo: ^uint64(0)}
+
+	for _, tc := range []struct {
+		format string
+		v      nvme.Uint128
+		want   string
+	}{
+		{"%d", nvme.Uint128From64(42), "42"},
+		{"%v", big, "18446744073709551618"},
+		{"%s", big, "18446744073709551618"},
+		{"%6d", nvme.Uint128From64(42), "    42"},
+		{"%-6d|", nvme.Uint128From64(42), "42    |"},
+		{"%06d", nvme.Uint128From64(42), "000042"},
+		{"%x", big, "10000000000000002"},
+		{"%#X", nvme.Uint128From64(255), "0XFF"},
+		{"%08x", nvme.Uint128From64(255), "000000ff"},
+		{"%b", nvme.Uint128From64(5), "101"},
+		{"%o", nvme.Uint128From64(8), "10"},
+		{"%d", max, "340282366920938463463374607431768211455"},
+		{"%030d", big, "000000000018446744073709551618"},
+		{"%+.5d", nvme.Uint128From64(42), "+00042"},
+		{"%.0d", nvme.Uint128{}, ""},
+		{"%#x", big, "0x10000000000000002"},
+		{"%-20x|", big, "10000000000000002   |"},
+		{"%X", max, "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"},
+		{"%#08b", nvme.Uint128From64(5), "0b00000101"},
+		{"%#8o", nvme.Uint128From64(0), "       0"},
+		{"%+06d", nvme.Uint128From64(42), "+00042"},
+		{"%b", big, "1" + strings.Repeat("0", 62) + "10"},
+		{"%o", big, "2000000000000000000002"},
+		{"%#o", nvme.Uint128From64(8), "010"},
+		{"%O", max, "0o3777777777777777777777777777777777777777777"},
+		{"%q", nvme.Uint128From64(1), "%!q(nvme.Uint128=1)"},
+	} {
+		if got := fmt.Sprintf(tc.format, tc.v); got != tc.want {
+			t.Errorf("Sprintf(%q): got %q, want %q", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestUint128JSON(t *testing.T) {
+	big := nvme.Uint128{Hi: 1, Lo: 2}
+
+	b, err := json.Marshal(big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "18446744073709551618" {
+		t.Errorf("marshaled %s", b)
+	}
+
+	for _, in := range []string{`18446744073709551618`, `"18446744073709551618"`} {
+		var u nvme.Uint128
+		if err := json.Unmarshal([]byte(in), &u); err != nil || u != big {
+			t.Errorf("unmarshal %s: %v, %v", in, u, err)
+		}
+	}
+
+	for _, in := range []string{`""`, `"123`, `""123""`, `"12a"`, `-1`, `340282366920938463463374607431768211456`} {
+		var u nvme.Uint128
+		if err := u.UnmarshalJSON([]byte(in)); err == nil {
+			t.Errorf("unmarshal %s: got %v, want an error", in, u)
+		}
+	}
+}
@@ -16,8 +16,6 @@ package nvme
 
 import (
 	"encoding/binary"
-	"fmt"
-	"math/big"
 	"reflect"
 	"unsafe"
 )
@@ -37,39 +35,6 @@ func init() {
 	}
 }
 
-func formatBigBytes(v *big.Int) string {
-	var i int
-
-	suffixes := [...]string{"B", "KB", "MB", "GB", "TB", "PB", "EB", "ZB", "YB"}
-	d := big.NewInt(1)
-
-	for i = 0; i < len(suffixes)-1; i++ {
-		if v.Cmp(new(big.Int).Mul(d, big.NewInt(1000))) == 1 {
-			d.Mul(d, big.NewInt(1000))
-		} else {
-			break
-		}
-	}
-
-	if i == 0 {
-		return fmt.Sprintf("%d %s", v, suffixes[i])
-	} else {
-		// Print 3 significant digits
-		return fmt.Sprintf("%.3g %s", new(big.Float).SetInt(v.Div(v, d)), suffixes[i])
-	}
-}
-
-// le128ToBigInt takes a little-endian 16-byte slice and returns a *big.Int representing it.
-func le128ToBigInt(buf [16]byte) *big.Int {
-	// Int.SetBytes() expects big-endian input, so reverse the bytes locally first
-	rev := make([]byte, 16)
-	for x := 0; x < 16; x++ {
-		rev[x] = buf[16-x-1]
-	}
-
-	return new(big.Int).SetBytes(rev)
-}
-
 // getBitsValue returns the value of a bit field in a uint64
 func getBitsValue(data uint64, start, end uint8) uint64 {
 	return (data >> start) & ((1 << (end - start + 1)) - 1)
@@ -0,0 +1,93 @@
+package nvme
+
+import (
+	"fmt"
+)
+
+const (
+	// Protection Information Action and Check bits (PRINFO)
+	PRINFO_CHECK_REFTAG uint8 = 1 << 0
+	PRINFO_CHECK_APPTAG uint8 = 1 << 1
+	PRINFO_CHECK_GUARD  uint8 = 1 << 2
+	PRINFO_PRACT        uint8 = 1 << 3
+)
+
+var VerifyCdw12BitInfo = cdwBitInfo{
+	{
+		name: "NLB", bitStart: 0,
+	},
+	{
+		name: "PRINFO", bitStart: 26,
+	},
+}
+
+type VerifyCdw12 struct {
+	NLB    uint32
+	PRINFO uint32
+}
+
+// ProtectionInfo selects the end-to-end protection information checks of a command and the
+// expected tag values.
+type ProtectionInfo struct {
+	PRINFO     uint8  // PRINFO_* bits
+	RefTag     uint32 // Expected Initial Logical Block Reference Tag
+	AppTag     uint16 // Expected Logical Block Application Tag
+	AppTagMask uint16 // Bits of AppTag that are checked
+}
+
+// checkProtection verifies that the namespace is formatted with protection information if pi
+// requests any checks.
+func (n *NVMeNamespace) checkProtection(pi *ProtectionInfo) error {
+	if pi == nil || pi.PRINFO == 0 {
+		return nil
+	}
+
+	if pi.PRINFO&^(PRINFO_CHECK_REFTAG|PRINFO_CHECK_APPTAG|PRINFO_CHECK_GUARD|PRINFO_PRACT) != 0 {
+		return fmt.Errorf("invalid PRINFO %#x", pi.PRINFO)
+	}
+
+	if getBitsValue(uint64(n.Ident.Dps), 0, 2) == uint64(FORMAT_PI_NONE) {
+		return fmt.Errorf("namespace %d is not formatted with protection information", n.Nsid)
+	}
+
+	return nil
+}
+
+// Verify checks the integrity of length logical blocks starting at lba, including their
+// protection information if pi (which may be nil) requests it, without transferring data.
+func (n *NVMeNamespace) Verify(lba uint64, length uint16, pi *ProtectionInfo) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if length == 0 {
+		return fmt.Errorf("invalid transfer length 0")
+	}
+
+	if err := n.checkProtection(pi); err != nil {
+		return err
+	}
+
+	if err := n.dev.checkOptionalCommand(ONCS_VERIFY, NVME_NVM_CMD_VERIFY, "Verify"); err != nil {
+		return err
+	}
+
+	if pi == nil {
+		pi = &ProtectionInfo{}
+	}
+
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_VERIFY,
+		Nsid:   n.Nsid,
+		Cdw10:  uint32(lba),
+		Cdw11:  uint32(lba >> 32),
+		Cdw12: buildCdw(VerifyCdw12BitInfo, VerifyCdw12{
+			NLB:    uint32(length - 1),
+			PRINFO: uint32(pi.PRINFO),
+		}),
+		Cdw14: pi.RefTag,
+		Cdw15: uint32(pi.AppTag) | uint32(pi.AppTagMask)<<16,
+	}
+
+	return n.dev.transport.SubmitIO(&cmd)
+}
@@ -1,19 +1,20 @@
 package nvme
 
-import (
-	"unsafe"
-
-	"github.com/AaronFei/go-nvme/ioctl"
-)
-
+// Write writes length logical blocks starting at lba to the namespace implied by the device node.
 func (d *NVMeDevice) Write(lba uint64, length uint16, write_hint uint32, buf []byte) error {
+	return d.write(0, lba, length, buf)
+}
+
+func (d *NVMeDevice) write(nsid uint32, lba uint64, length uint16, buf []byte) error {
 
-	cmd := nvmeUserIo{
-		opcode:  NVME_NVM_CMD_WRITE,
-		slba:    lba,
-		addr:    uint64(uintptr(unsafe.Pointer(&(buf)[0]))),
-		nblocks: length - 1,
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_WRITE,
+		Nsid:   nsid,
+		Data:   buf,
+		Cdw10:  uint32(lba),
+		Cdw11:  uint32(lba >> 32),
+		Cdw12:  uint32(length - 1),
 	}
 
-	return ioctl.Ioctl(uintptr(d.fd), NVME_IOCTL_SUBMIT_IO, uintptr(unsafe.Pointer(&cmd)))
+	return d.transport.SubmitIO(&cmd)
 }
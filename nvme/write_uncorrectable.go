@@ -0,0 +1,184 @@
+package nvme
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrNamespaceInUse is returned, wrapped, when a destructive test command is refused because the
+// namespace is mounted, used as swap or held by another block device such as a device-mapper
+// target.
+var ErrNamespaceInUse = errors.New("namespace is in use")
+
+var (
+	// Files listing block devices in use, with the device in the first field
+	inUseFiles         = []string{"/proc/mounts", "/proc/swaps"}
+	sysBlock           = "/sys/block"
+	sysClassNvme       = "/sys/class/nvme"
+	sysClassNvmeSubsys = "/sys/class/nvme-subsystem"
+
+	evalSymlinks = filepath.EvalSymlinks
+
+	// Per-path devices of multipath namespaces, which have no device node
+	nsPathRe = regexp.MustCompile(`^nvme\d+c\d+n\d+$`)
+)
+
+// blockDeviceName returns the name of the block device of the namespace, such as nvme0n1, or ""
+// if the device does not talk to the kernel driver. The block device is looked up by NSID among
+// the namespaces of the controller and of its subsystem, as namespaces of multipath subsystems
+// are named after the subsystem instance rather than the controller instance.
+func (n *NVMeNamespace) blockDeviceName() (string, error) {
+	t, ok := n.dev.transport.(*IoctlTransport)
+	if !ok {
+		return "", nil
+	}
+
+	path := t.Path
+	if resolved, err := evalSymlinks(path); err == nil {
+		path = resolved
+	}
+	node := filepath.Base(path)
+
+	// Controller nodes are listed in the nvme class and in the subsystem they belong to.
+	// Namespace nodes link to their controller, or to their subsystem under multipath.
+	dirs := []string{filepath.Join(sysClassNvme, node)}
+	subsys, _ := filepath.Glob(filepath.Join(sysClassNvmeSubsys, "*", node))
+	for _, s := range subsys {
+		dirs = append(dirs, filepath.Dir(s))
+	}
+	dirs = append(dirs, filepath.Join(sysBlock, node, "device"))
+
+	for _, dir := range dirs {
+		entries, _ := filepath.Glob(filepath.Join(dir, "nvme*n*"))
+		for _, e := range entries {
+			name := filepath.Base(e)
+			if nsPathRe.MatchString(name) {
+				continue
+			}
+
+			b, err := os.ReadFile(filepath.Join(e, "nsid"))
+			if err != nil {
+				continue
+			}
+
+			if nsid, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 32); err == nil && uint32(nsid) == n.Nsid {
+				return name, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%w: no block device found for namespace %d of %s", ErrNamespaceInUse, n.Nsid, t.Path)
+}
+
+// isBlockDeviceOf reports whether the block device name dev is the namespace block device name
+// or one of its partitions.
+func isBlockDeviceOf(dev, name string) bool {
+	if dev == name {
+		return true
+	}
+
+	part, ok := strings.CutPrefix(dev, name+"p")
+	return ok && part != "" && strings.Trim(part, "0123456789") == ""
+}
+
+// InUse reports whether the namespace, or a partition of it, is mounted, used as swap or held
+// by another block device, and if so by what. Namespaces of devices that do not talk to the
+// kernel driver are never in use. If the block device of the namespace, or the device behind a
+// mount or swap source such as /dev/root, cannot be determined, the error wraps
+// ErrNamespaceInUse, as the namespace may be in use.
+func (n *NVMeNamespace) InUse() (bool, string, error) {
+	name, err := n.blockDeviceName()
+	if err != nil || name == "" {
+		return false, "", err
+	}
+
+	for _, path := range inUseFiles {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return false, "", err
+		}
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Fields(s.Text())
+			if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+				continue
+			}
+
+			// Resolve links such as /dev/disk/by-uuid/... to the device node
+			dev, err := evalSymlinks(fields[0])
+			if err != nil {
+				f.Close()
+				return false, "", fmt.Errorf("%w: cannot resolve %s on %s: %v", ErrNamespaceInUse, fields[0], fields[1], err)
+			}
+
+			if isBlockDeviceOf(filepath.Base(dev), name) {
+				f.Close()
+				return true, fmt.Sprintf("%s on %s", fields[0], fields[1]), nil
+			}
+		}
+
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return false, "", err
+		}
+	}
+
+	holders, _ := filepath.Glob(filepath.Join(sysBlock, name, "holders", "*"))
+	parts, _ := filepath.Glob(filepath.Join(sysBlock, name, name+"p*", "holders", "*"))
+	if holders = append(holders, parts...); len(holders) > 0 {
+		return true, "held by " + filepath.Base(holders[0]), nil
+	}
+
+	return false, "", nil
+}
+
+// WriteUncorrectable marks length logical blocks starting at lba as invalid, so that reading
+// them fails with an Unrecovered Read Error until they are written again, for example by Write
+// or WriteZeroes. It is meant for fault injection and refuses to run on a namespace that is in
+// use, cf. InUse.
+func (n *NVMeNamespace) WriteUncorrectable(lba uint64, length uint32) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if length == 0 || length > 1<<16 {
+		return fmt.Errorf("invalid transfer length %d", length)
+	}
+
+	if lba+uint64(length) > n.Ident.Nsze || lba+uint64(length) < lba {
+		return fmt.Errorf("range %d+%d exceeds namespace size %d", lba, length, n.Ident.Nsze)
+	}
+
+	if err := n.dev.checkOptionalCommand(ONCS_WRITE_UNCORR, NVME_NVM_CMD_WRITE_UNCORRECTABLE, "Write Uncorrectable"); err != nil {
+		return err
+	}
+
+	inUse, by, err := n.InUse()
+	if err != nil {
+		return err
+	}
+
+	if inUse {
+		return fmt.Errorf("%w: %s", ErrNamespaceInUse, by)
+	}
+
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_WRITE_UNCORRECTABLE,
+		Nsid:   n.Nsid,
+		Cdw10:  uint32(lba),
+		Cdw11:  uint32(lba >> 32),
+		Cdw12:  uint32(length - 1),
+	}
+
+	return n.dev.transport.SubmitIO(&cmd)
+}
@@ -0,0 +1,180 @@
+package nvme_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+	"github.com/AaronFei/go-nvme/nvmesim"
+)
+
+func isUnrecoveredRead(err error) bool {
+	return nvme.IsStatus(err, nvme.NVME_SCT_MEDIA, nvme.NVME_SC_UNRECOVERED_READ)
+}
+
+func TestWriteUncorrectable(t *testing.T) {
+	n := newSimNamespace(t, nvmesim.DefaultConfig())
+
+	data := pattern(8*512, 4)
+	if err := n.Write(0, 8, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.WriteUncorrectable(4, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 8*512)
+	if err := n.Read(0, 8, buf); !isUnrecoveredRead(err) {
+		t.Errorf("read of marked blocks: got %v, want Unrecovered Read Error", err)
+	}
+	if err := n.Verify(5, 1, nil); !isUnrecoveredRead(err) {
+		t.Errorf("verify of a marked block: got %v, want Unrecovered Read Error", err)
+	}
+	if err := n.Read(0, 4, buf[:4*512]); err != nil {
+		t.Errorf("read of unmarked blocks: %v", err)
+	}
+
+	// Writing the blocks again clears the marks
+	if err := n.Write(4, 1, data[4*512:5*512]); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Read(5, 1, buf[:512]); !isUnrecoveredRead(err) {
+		t.Errorf("read of a block still marked: got %v, want Unrecovered Read Error", err)
+	}
+	if err := n.WriteZeroes(5, 1, nvme.WriteZeroesParams{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Read(0, 8, buf); err != nil {
+		t.Fatal(err)
+	}
+	want := append(append(append([]byte(nil), data[:5*512]...), make([]byte, 512)...), data[6*512:]...)
+	if !bytes.Equal(buf, want) {
+		t.Error("unexpected data after clearing the marks")
+	}
+
+	h, err := n.Device().GetSMART(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := h.MediaErrors.Uint64(); v != 3 {
+		t.Errorf("media errors %v, want 3", h.MediaErrors)
+	}
+
+}
+
+func TestWriteUncorrectableRange(t *testing.T) {
+	d, tr := newCountingDevice(t, nvmesim.DefaultConfig())
+	n, err := d.Namespace(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.WriteUncorrectable(n.Ident.Nsze-1<<16, 1<<16); err != nil {
+		t.Fatalf("maximum length at the end of the namespace: %v", err)
+	}
+
+	for _, r := range []struct {
+		lba    uint64
+		length uint32
+	}{
+		{0, 0},
+		{0, 1<<16 + 1},
+		{n.Ident.Nsze - 1, 2},
+		{^uint64(0), 2},
+	} {
+		if err := n.WriteUncorrectable(r.lba, r.length); err == nil {
+			t.Errorf("%d+%d accepted", r.lba, r.length)
+		}
+	}
+
+	if c := tr.io[nvme.NVME_NVM_CMD_WRITE_UNCORRECTABLE]; c != 1 {
+		t.Errorf("%d Write Uncorrectable commands, want 1", c)
+	}
+}
+
+// writeFiles creates files below root with the given contents, and their directories.
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fakeMultipathHost lays out sysfs for controller nvme0 of subsystem nvme-subsys1, whose
+// namespaces 1 and 2 are nvme1n1 and nvme1n2, with nvme1n2 held by a device-mapper target.
+func fakeMultipathHost(t *testing.T, mounts string) string {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"proc/mounts":                                        mounts,
+		"sys/class/nvme/nvme0/nvme0c0n1/nsid":                "1\n",
+		"sys/class/nvme/nvme0/nvme0c0n2/nsid":                "2\n",
+		"sys/class/nvme-subsystem/nvme-subsys1/nvme0/dev":    "240:0\n",
+		"sys/class/nvme-subsystem/nvme-subsys1/nvme1n1/nsid": "1\n",
+		"sys/class/nvme-subsystem/nvme-subsys1/nvme1n2/nsid": "2\n",
+		"sys/block/nvme1n1/size":                             "0\n",
+		"sys/block/nvme1n2/holders/dm-0":                     "",
+	})
+
+	if err := os.Symlink(filepath.Join(root, "sys/class/nvme-subsystem/nvme-subsys1"), filepath.Join(root, "sys/block/nvme1n1/device")); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestInUse(t *testing.T) {
+	links := map[string]string{
+		"/dev/nvme0":              "/dev/nvme0",
+		"/dev/nvme1n1":            "/dev/nvme1n1",
+		"/dev/disk/by-label/data": "/dev/nvme1n1p2",
+		"/dev/sda1":               "/dev/sda1",
+	}
+	nvme.SetHostRoot(t, fakeMultipathHost(t, "/dev/sda1 / ext4 rw 0 0\n/dev/disk/by-label/data /data ext4 rw 0 0\n"), links)
+
+	for _, tc := range []struct {
+		path  string
+		nsid  uint32
+		inUse bool
+		by    string
+	}{
+		{"/dev/nvme0", 1, true, "/dev/disk/by-label/data on /data"},
+		{"/dev/nvme0", 2, true, "held by dm-0"},
+		{"/dev/nvme1n1", 1, true, "/dev/disk/by-label/data on /data"},
+	} {
+		inUse, by, err := nvme.NewKernelNamespace(tc.path, tc.nsid).InUse()
+		if err != nil || inUse != tc.inUse || by != tc.by {
+			t.Errorf("%s namespace %d: got %v %q %v, want %v %q", tc.path, tc.nsid, inUse, by, err, tc.inUse, tc.by)
+		}
+	}
+
+	// Without a block device the namespace may still be in use through another path
+	if _, _, err := nvme.NewKernelNamespace("/dev/nvme0", 3).InUse(); !errors.Is(err, nvme.ErrNamespaceInUse) {
+		t.Errorf("namespace without a block device: got %v, want ErrNamespaceInUse", err)
+	}
+}
+
+func TestInUseUnresolvedMountSource(t *testing.T) {
+	links := map[string]string{"/dev/nvme0": "/dev/nvme0"}
+
+	nvme.SetHostRoot(t, fakeMultipathHost(t, ""), links)
+	if inUse, _, err := nvme.NewKernelNamespace("/dev/nvme0", 1).InUse(); inUse || err != nil {
+		t.Errorf("unused namespace: got %v, %v", inUse, err)
+	}
+
+	nvme.SetHostRoot(t, fakeMultipathHost(t, "/dev/root / ext4 rw 0 0\n"), links)
+	if _, _, err := nvme.NewKernelNamespace("/dev/nvme0", 1).InUse(); !errors.Is(err, nvme.ErrNamespaceInUse) {
+		t.Errorf("unresolved mount source: got %v, want ErrNamespaceInUse", err)
+	}
+}
@@ -0,0 +1,66 @@
+package nvme
+
+import (
+	"fmt"
+)
+
+var WriteZeroesCdw12BitInfo = cdwBitInfo{
+	{
+		name: "NLB", bitStart: 0,
+	},
+	{
+		name: "DEAC", bitStart: 25,
+	},
+	{
+		name: "FUA", bitStart: 30,
+	},
+}
+
+type WriteZeroesCdw12 struct {
+	NLB  uint32
+	DEAC uint32
+	FUA  uint32
+}
+
+// WriteZeroesParams selects the optional behaviour of WriteZeroes.
+type WriteZeroesParams struct {
+	Deallocate bool // Deallocate the blocks if possible; they read as zeroes if DLFEAT bit 3 is set
+	FUA        bool // Force Unit Access: complete only once the zeroes are on non-volatile media
+}
+
+// WriteZeroes sets length logical blocks starting at lba to zero without transferring data.
+func (n *NVMeNamespace) WriteZeroes(lba uint64, length uint16, p WriteZeroesParams) error {
+	if err := n.sync(); err != nil {
+		return err
+	}
+
+	if length == 0 {
+		return fmt.Errorf("invalid transfer length 0")
+	}
+
+	if err := n.dev.checkOptionalCommand(ONCS_WRITE_ZEROES, NVME_NVM_CMD_WRITE_ZEROES, "Write Zeroes"); err != nil {
+		return err
+	}
+
+	var deac, fua uint32
+	if p.Deallocate {
+		deac = 1
+	}
+	if p.FUA {
+		fua = 1
+	}
+
+	cmd := Command{
+		Opcode: NVME_NVM_CMD_WRITE_ZEROES,
+		Nsid:   n.Nsid,
+		Cdw10:  uint32(lba),
+		Cdw11:  uint32(lba >> 32),
+		Cdw12: buildCdw(WriteZeroesCdw12BitInfo, WriteZeroesCdw12{
+			NLB:  uint32(length - 1),
+			DEAC: deac,
+			FUA:  fua,
+		}),
+	}
+
+	return n.dev.transport.SubmitIO(&cmd)
+}
@@ -0,0 +1,361 @@
+// Package nvmesim implements an in-memory NVMe controller that can stand in for real hardware.
+//
+// A Controller implements nvme.Transport, so an nvme.NVMeDevice opened against it exercises the
+// same command encoding and response decoding paths as one opened against /dev/nvmeX:
+//
+//	d := nvme.NewNVMeDeviceWithTransport("sim0", nvmesim.New(nvmesim.DefaultConfig()))
+//	d.Open()
+//	idCtrl, err := d.IdentifyController()
+package nvmesim
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// Config describes the simulated controller and its namespaces.
+type Config struct {
+	VendorID                uint16
+	SubsysVendorID          uint16
+	SerialNumber            string
+	ModelNumber             string
+	FirmwareRevision        string
+	IEEE                    [3]byte
+	Mdts                    uint8 // Maximum data transfer size as a power of two of 4 KiB pages, 0 for no limit
+	ErrorLogEntries         uint8 // Number of error log entries kept, 0's based like ELPE
+	FirmwareSlots           uint8 // Number of firmware slots, 1 to 7
+	Slot1ReadOnly           bool
+	FirmwareActivateNoReset bool   // Firmware can be activated without a reset
+	WarningTemp             uint16 // Kelvin
+	CriticalTemp            uint16 // Kelvin
+	VolatileCache           bool
+	Sanicap                 uint32           // Sanitize capabilities, 0 if Sanitize is not supported
+	SanitizeFailure         bool             // Sanitize operations fail once they complete
+	FormatProgress          bool             // Report format progress in FPI, cf. formatSteps
+	SelfTestFailure         bool             // Device self-tests fail once they complete
+	Telemetry               bool             // Provide host- and controller-initiated telemetry logs
+	PersistentEvents        bool             // Keep a Persistent Event log
+	CopyFormats             uint16           // Copy descriptor formats supported (OCFS), 0 if Copy is not supported
+	CopyMssrl               uint16           // Maximum Single Source Range Length
+	CopyMcl                 uint32           // Maximum Copy Length
+	CopyMsrc                uint8            // Maximum Source Range Count (0's based)
+	Reservations            bool             // Support reservations of all types, with Persist Through Power Loss
+	NsManagement            bool             // Support Namespace Management and Namespace Attachment
+	MaxNamespaces           uint32           // Reported as NN, at least the highest configured NSID
+	Capacity                uint64           // Total NVM capacity in bytes, 0 for the sum of the namespaces
+	LbaFormats              []nvme.LbaFormat // LBA formats offered for namespaces created by the host
+	Namespaces              []NamespaceConfig
+	SMART                   SMARTConfig
+}
+
+// NamespaceConfig describes a RAM-backed namespace. Blocks are allocated on first write, so large
+// namespaces only consume memory for the data actually written to them.
+type NamespaceConfig struct {
+	Nsid        uint32
+	Size        uint64 // In logical blocks
+	LbaFormats  []nvme.LbaFormat
+	FormatIndex uint8
+	Shared      bool // Reported via NMIC
+}
+
+// SMARTConfig holds the initial SMART / Health Information values. The host read/write command
+// and data unit counters are advanced by I/O submitted to the controller.
+type SMARTConfig struct {
+	CritWarning          uint8
+	EnduranceGrpCritWarn uint8
+	Temperature          uint16 // Kelvin
+	AvailSpare           uint8
+	SpareThresh          uint8
+	PercentUsed          uint8
+	DataUnitsRead        uint64
+	DataUnitsWritten     uint64
+	HostReads            uint64
+	HostWrites           uint64
+	CtrlBusyTime         uint64
+	PowerCycles          uint64
+	PowerOnHours         uint64
+	UnsafeShutdowns      uint64
+	MediaErrors          uint64
+	WarningTempTime      uint32
+	CritCompTime         uint32
+	TempSensor           [8]uint16
+	ThmTempTransCount    [2]uint32 // Thermal management temperature 1 and 2 transitions
+	ThmTempTotalTime     [2]uint32 // Seconds
+}
+
+// DefaultConfig returns the configuration of a small single-namespace drive.
+func DefaultConfig() Config {
+	return Config{
+		VendorID:         0x1b36,
+		SubsysVendorID:   0x1af4,
+		SerialNumber:     "SIM0000001",
+		ModelNumber:      "go-nvme simulated controller",
+		FirmwareRevision: "1.0",
+		Mdts:             5,
+		ErrorLogEntries:  63,
+		FirmwareSlots:    2,
+		WarningTemp:      343,
+		CriticalTemp:     358,
+		VolatileCache:    true,
+		LbaFormats:       []nvme.LbaFormat{{Lbads: 9}, {Lbads: 12}},
+		Namespaces: []NamespaceConfig{
+			{
+				Nsid:       1,
+				Size:       1 << 21,
+				LbaFormats: []nvme.LbaFormat{{Lbads: 9}, {Lbads: 12}},
+			},
+		},
+		SMART: SMARTConfig{
+			Temperature: 308,
+			AvailSpare:  100,
+			SpareThresh: 10,
+		},
+	}
+}
+
+type namespace struct {
+	cfg      NamespaceConfig
+	blocks   map[uint64][]byte
+	attached bool
+	resv     reservation
+	uncorr   map[uint64]bool // Blocks marked by Write Uncorrectable
+	fmtSteps int             // Identify Namespace reads until a format completes
+}
+
+func (ns *namespace) blockSize() uint32 {
+	return 1 << ns.cfg.LbaFormats[ns.cfg.FormatIndex].Lbads
+}
+
+func (ns *namespace) sizeBytes() uint64 {
+	return ns.cfg.Size * uint64(ns.blockSize())
+}
+
+// Controller is a simulated NVMe controller. It is safe for concurrent use.
+type Controller struct {
+	mu               sync.Mutex
+	cfg              Config
+	namespaces       map[uint32]*namespace
+	features         map[uint8]*feature
+	smart            SMARTConfig
+	bytesRead        uint64 // Sub-data-unit remainders, in 512 byte units
+	bytesWrite       uint64
+	errors           []errorEntry
+	errorCount       uint64
+	cid              uint16
+	fwSlots          [7][8]byte
+	activeSlot       uint8
+	nextSlot         uint8 // Slot activated at the next reset, 0 for none
+	fwImage          []byte
+	sanitize         sanitizeState
+	selfTest         selfTestState
+	telemetryHostDgn uint8
+	telemetryCtrlDgn uint8
+	events           [][]byte // Encoded persistent events, oldest first
+	pelContext       []byte   // Persistent Event log snapshot of the reporting context
+	open             bool
+}
+
+// New returns a controller simulating cfg.
+func New(cfg Config) *Controller {
+	c := &Controller{
+		cfg:        cfg,
+		namespaces: make(map[uint32]*namespace),
+		smart:      cfg.SMART,
+		activeSlot: 1,
+	}
+
+	if c.cfg.FirmwareSlots == 0 {
+		c.cfg.FirmwareSlots = 1
+	}
+	copy(c.fwSlots[0][:], padString(cfg.FirmwareRevision, 8))
+
+	if len(c.cfg.LbaFormats) == 0 {
+		c.cfg.LbaFormats = []nvme.LbaFormat{{Lbads: 9}}
+	}
+
+	var allocated uint64
+	for _, nc := range cfg.Namespaces {
+		if len(nc.LbaFormats) == 0 {
+			nc.LbaFormats = c.cfg.LbaFormats
+		}
+		ns := &namespace{cfg: nc, blocks: make(map[uint64][]byte), attached: true}
+		c.namespaces[nc.Nsid] = ns
+
+		allocated += ns.sizeBytes()
+		if nc.Nsid > c.cfg.MaxNamespaces {
+			c.cfg.MaxNamespaces = nc.Nsid
+		}
+	}
+
+	if c.cfg.Capacity == 0 {
+		c.cfg.Capacity = allocated
+	}
+
+	if c.cfg.MaxNamespaces == 0 {
+		c.cfg.MaxNamespaces = 1
+	}
+
+	c.features = defaultFeatures(&c.cfg)
+
+	return c
+}
+
+// NewDevice returns an NVMeDevice backed by a new controller simulating cfg.
+func NewDevice(name string, cfg Config) *nvme.NVMeDevice {
+	return nvme.NewNVMeDeviceWithTransport(name, New(cfg))
+}
+
+func (c *Controller) Open() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.open {
+		return fmt.Errorf("simulated controller already open")
+	}
+	c.open = true
+
+	return nil
+}
+
+func (c *Controller) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.open = false
+
+	return nil
+}
+
+func (c *Controller) SubmitAdmin(cmd *nvme.Command) error {
+	return c.submit(0, cmd, c.admin)
+}
+
+// SubmitIO executes an NVM command. A zero cmd.Nsid addresses namespace 1, as if the device had
+// been opened through its namespace node.
+func (c *Controller) SubmitIO(cmd *nvme.Command) error {
+	if cmd.Nsid == 0 {
+		cmd.Nsid = 1
+	}
+
+	return c.submit(1, cmd, c.io)
+}
+
+func (c *Controller) submit(sqid uint16, cmd *nvme.Command, handler func(*nvme.Command) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.open {
+		return fmt.Errorf("simulated controller not open")
+	}
+
+	c.cid++
+	cmd.Result = 0
+
+	err := handler(cmd)
+	if st, ok := err.(*nvme.StatusError); ok {
+		c.logError(sqid, cmd, st)
+	}
+
+	return err
+}
+
+func (c *Controller) admin(cmd *nvme.Command) error {
+	if err := c.sanitizeError(); err != nil && !sanitizeAllowed(cmd) {
+		return err
+	}
+
+	switch cmd.Opcode {
+	case nvme.NVME_ADMIN_IDENTIFY:
+		return c.identify(cmd)
+	case nvme.NVME_ADMIN_GET_LOG_PAGE:
+		return c.getLogPage(cmd)
+	case nvme.NVME_ADMIN_GET_FEATURES:
+		return c.getFeatures(cmd)
+	case nvme.NVME_ADMIN_SET_FEATURES:
+		return c.setFeatures(cmd)
+	case nvme.NVME_ADMIN_FIRMWARE_DOWNLOAD:
+		return c.firmwareDownload(cmd)
+	case nvme.NVME_ADMIN_FIRMWARE_COMMIT:
+		return c.firmwareCommit(cmd)
+	case nvme.NVME_ADMIN_DEVICE_SELF_TEST:
+		return c.deviceSelfTest(cmd)
+	case nvme.NVME_ADMIN_SANITIZE:
+		if c.cfg.Sanicap != 0 {
+			return c.startSanitize(cmd)
+		}
+	case nvme.NVME_ADMIN_FORMAT_NVM:
+		return c.format(cmd)
+	case nvme.NVME_ADMIN_NS_MANAGEMENT:
+		if c.cfg.NsManagement {
+			return c.nsManagement(cmd)
+		}
+	case nvme.NVME_ADMIN_NS_ATTACHMENT:
+		if c.cfg.NsManagement {
+			return c.nsAttachment(cmd)
+		}
+	}
+
+	return errInvalidOpcode
+}
+
+func (c *Controller) io(cmd *nvme.Command) error {
+	if err := c.sanitizeError(); err != nil {
+		return err
+	}
+
+	// The simulated cache is always clean, so a flush only needs a valid NSID
+	if cmd.Opcode == nvme.NVME_NVM_CMD_FLUSH && cmd.Nsid == nvme.NSID_ALL {
+		return nil
+	}
+
+	ns, ok := c.namespaces[cmd.Nsid]
+	if !ok || !ns.attached {
+		return errInvalidNamespace
+	}
+
+	switch cmd.Opcode {
+	case nvme.NVME_NVM_CMD_FLUSH:
+		return nil
+	case nvme.NVME_NVM_CMD_READ:
+		return c.read(ns, cmd)
+	case nvme.NVME_NVM_CMD_WRITE:
+		return c.write(ns, cmd)
+	case nvme.NVME_NVM_CMD_WRITE_UNCORRECTABLE:
+		return c.writeUncorrectable(ns, cmd)
+	case nvme.NVME_NVM_CMD_COMPARE:
+		return c.compare(ns, cmd)
+	case nvme.NVME_NVM_CMD_WRITE_ZEROES:
+		return c.writeZeroes(ns, cmd)
+	case nvme.NVME_NVM_CMD_VERIFY:
+		slba, nlb, err := c.lbaRange(ns, cmd, false)
+		if err != nil {
+			return err
+		}
+		return c.checkReadable(ns, slba, nlb)
+	case nvme.NVME_NVM_CMD_DATASET_MANAGEMENT:
+		return c.datasetManagement(ns, cmd)
+	case nvme.NVME_NVM_CMD_RESERVATION_REGISTER, nvme.NVME_NVM_CMD_RESERVATION_REPORT,
+		nvme.NVME_NVM_CMD_RESERVATION_ACQUIRE, nvme.NVME_NVM_CMD_RESERVATION_RELEASE:
+		if c.cfg.Reservations {
+			return c.reservation(ns, cmd)
+		}
+	case nvme.NVME_NVM_CMD_COPY:
+		if c.cfg.CopyFormats != 0 {
+			return c.copy(ns, cmd)
+		}
+	}
+
+	return errInvalidOpcode
+}
+
+func padString(s string, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = ' '
+	}
+	copy(b, s)
+
+	return b
+}
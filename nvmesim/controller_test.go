@@ -0,0 +1,147 @@
+package nvmesim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+func newOpenController(t *testing.T, cfg Config) *Controller {
+	t.Helper()
+
+	c := New(cfg)
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestControllerOpen(t *testing.T) {
+	c := New(DefaultConfig())
+
+	cmd := nvme.Command{Opcode: nvme.NVME_ADMIN_IDENTIFY, Data: make([]byte, 4096), Cdw10: uint32(nvme.IDENTIFY_CNS_CTRL)}
+	if err := c.SubmitAdmin(&cmd); err == nil {
+		t.Error("command submitted to a closed controller succeeded")
+	}
+
+	if err := c.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Open(); err == nil {
+		t.Error("second open succeeded")
+	}
+
+	if err := c.SubmitAdmin(&cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	if err := c.SubmitIO(&nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ}); err == nil {
+		t.Error("command submitted after close succeeded")
+	}
+}
+
+func TestSubmitIO(t *testing.T) {
+	cfg := DefaultConfig()
+	c := newOpenController(t, cfg)
+
+	// A zero NSID addresses namespace 1
+	data := bytes.Repeat([]byte{0xa5}, 2*512)
+	cmd := nvme.Command{Opcode: nvme.NVME_NVM_CMD_WRITE, Data: data, Cdw10: 10, Cdw12: 1}
+	if err := c.SubmitIO(&cmd); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Nsid != 1 {
+		t.Errorf("NSID %d, want 1", cmd.Nsid)
+	}
+
+	buf := make([]byte, 3*512)
+	cmd = nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Nsid: 1, Data: buf, Cdw10: 10, Cdw12: 2}
+	if err := c.SubmitIO(&cmd); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf[:2*512], data) || !bytes.Equal(buf[2*512:], make([]byte, 512)) {
+		t.Error("read returned unexpected data")
+	}
+	if c.smart.HostReads != 1 || c.smart.HostWrites != 1 {
+		t.Errorf("host reads %d writes %d, want 1 1", c.smart.HostReads, c.smart.HostWrites)
+	}
+
+	for _, tc := range []struct {
+		name string
+		cmd  nvme.Command
+		want error
+	}{
+		{"missing namespace", nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Nsid: 2, Data: make([]byte, 512)}, errInvalidNamespace},
+		{"unknown opcode", nvme.Command{Opcode: 0x80}, errInvalidOpcode},
+		{"past the end", nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Data: make([]byte, 1024), Cdw10: uint32(cfg.Namespaces[0].Size - 1), Cdw12: 1}, errLbaOutOfRange},
+		{"short buffer", nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Data: make([]byte, 512), Cdw12: 1}, errDataTransfer},
+		{"above MDTS", nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Data: make([]byte, 4096<<cfg.Mdts+512), Cdw12: 4096 << cfg.Mdts / 512}, errInvalidField},
+	} {
+		if err := c.SubmitIO(&tc.cmd); err != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ErrorLogEntries = 1
+	c := newOpenController(t, cfg)
+
+	c.SubmitAdmin(&nvme.Command{Opcode: 0xc0})
+	c.SubmitIO(&nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Data: make([]byte, 512), Cdw10: uint32(cfg.Namespaces[0].Size)})
+	c.SubmitIO(&nvme.Command{Opcode: nvme.NVME_NVM_CMD_READ, Nsid: 5, Data: make([]byte, 512), Cdw10: 7})
+
+	// Two entries are kept, newest first
+	log := c.errorLog()
+	if len(log) != 128 {
+		t.Fatalf("error log of %d bytes, want 128", len(log))
+	}
+
+	newest, older := log[:64], log[64:]
+	if binary.LittleEndian.Uint64(newest) != 3 || binary.LittleEndian.Uint64(older) != 2 {
+		t.Errorf("error counts %d %d, want 3 2", binary.LittleEndian.Uint64(newest), binary.LittleEndian.Uint64(older))
+	}
+	if binary.LittleEndian.Uint16(newest[8:]) != 1 || binary.LittleEndian.Uint16(newest[10:]) != c.cid {
+		t.Errorf("SQID %d CID %d, want 1 %d", binary.LittleEndian.Uint16(newest[8:]), binary.LittleEndian.Uint16(newest[10:]), c.cid)
+	}
+	if st := binary.LittleEndian.Uint16(newest[12:]) >> 1; st != errInvalidNamespace.Status() {
+		t.Errorf("status %#x, want %#x", st, errInvalidNamespace.Status())
+	}
+	if nsid, lba := binary.LittleEndian.Uint32(newest[24:]), binary.LittleEndian.Uint64(newest[16:]); nsid != 5 || lba != 7 {
+		t.Errorf("NSID %d LBA %d, want 5 7", nsid, lba)
+	}
+	if lba := binary.LittleEndian.Uint64(older[16:]); lba != cfg.Namespaces[0].Size {
+		t.Errorf("LBA %d, want %d", lba, cfg.Namespaces[0].Size)
+	}
+}
+
+func TestGetLogPageOffset(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SMART.PowerOnHours = 0x1122
+	c := newOpenController(t, cfg)
+
+	// Power On Hours at byte 128 of the SMART log, read from offset 128
+	buf := make([]byte, 16)
+	cmd := nvme.Command{Opcode: nvme.NVME_ADMIN_GET_LOG_PAGE, Data: buf, Cdw10: uint32(nvme.LOGPAGE_SMART_HEALTH_INFO) | 3<<16, Cdw12: 128}
+	if err := c.SubmitAdmin(&cmd); err != nil {
+		t.Fatal(err)
+	}
+	if v := binary.LittleEndian.Uint64(buf); v != 0x1122 {
+		t.Errorf("power on hours %#x, want 0x1122", v)
+	}
+
+	cmd.Cdw12 = 2
+	if err := c.SubmitAdmin(&cmd); err != errInvalidField {
+		t.Errorf("unaligned offset: got %v, want %v", err, errInvalidField)
+	}
+
+	cmd = nvme.Command{Opcode: nvme.NVME_ADMIN_GET_LOG_PAGE, Data: buf, Cdw10: 0xc0 | 3<<16}
+	if err := c.SubmitAdmin(&cmd); err != errInvalidLogPage {
+		t.Errorf("unknown log page: got %v, want %v", err, errInvalidLogPage)
+	}
+}
@@ -0,0 +1,87 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// copy implements Copy for all descriptor formats. Protection information is not simulated, so
+// the tag fields of the descriptors are ignored.
+func (c *Controller) copy(dst *namespace, cmd *nvme.Command) error {
+	nr := int(cmd.Cdw12&0xff) + 1
+	format := uint8(cmd.Cdw12 >> 8 & 0xf)
+
+	if format > nvme.COPY_DESC_FORMAT3 || c.cfg.CopyFormats&(1<<format) == 0 {
+		return errInvalidField
+	}
+
+	size := 32
+	if format == nvme.COPY_DESC_FORMAT1 || format == nvme.COPY_DESC_FORMAT3 {
+		size = 40
+	}
+	if len(cmd.Data) < nr*size {
+		return errDataTransfer
+	}
+
+	if nr > int(c.cfg.CopyMsrc)+1 {
+		return errCmdSizeLimit
+	}
+
+	// Gather the source blocks first, so that overlapping source and destination ranges copy the
+	// original data
+	var blocks [][]byte
+	for i := 0; i < nr; i++ {
+		d := cmd.Data[i*size:]
+		src := dst
+		if format == nvme.COPY_DESC_FORMAT2 || format == nvme.COPY_DESC_FORMAT3 {
+			ns, ok := c.namespaces[binary.LittleEndian.Uint32(d)]
+			if !ok || !ns.attached {
+				return errInvalidNamespace
+			}
+			if ns.blockSize() != dst.blockSize() {
+				return errInvalidFormat
+			}
+			src = ns
+		}
+
+		slba := binary.LittleEndian.Uint64(d[8:])
+		nlb := uint64(binary.LittleEndian.Uint16(d[16:])) + 1
+		if c.cfg.CopyMssrl != 0 && nlb > uint64(c.cfg.CopyMssrl) {
+			return errCmdSizeLimit
+		}
+		if slba+nlb > src.cfg.Size {
+			return errLbaOutOfRange
+		}
+
+		if err := c.checkReadable(src, slba, nlb); err != nil {
+			return err
+		}
+
+		for lba := slba; lba < slba+nlb; lba++ {
+			blocks = append(blocks, src.blocks[lba])
+		}
+	}
+
+	if c.cfg.CopyMcl != 0 && len(blocks) > int(c.cfg.CopyMcl) {
+		return errCmdSizeLimit
+	}
+
+	sdlba := uint64(cmd.Cdw11)<<32 | uint64(cmd.Cdw10)
+	if sdlba+uint64(len(blocks)) > dst.cfg.Size {
+		return errLbaOutOfRange
+	}
+
+	for i, blk := range blocks {
+		delete(dst.uncorr, sdlba+uint64(i))
+		if blk == nil {
+			delete(dst.blocks, sdlba+uint64(i))
+		} else {
+			dst.blocks[sdlba+uint64(i)] = append([]byte(nil), blk...)
+		}
+	}
+
+	c.sanitize.sstat &^= 1 << 8
+
+	return nil
+}
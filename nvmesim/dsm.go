@@ -0,0 +1,39 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// datasetManagement implements Dataset Management. Only deallocation has an effect: deallocated
+// blocks read as zeroes, as reported by DLFEAT.
+func (c *Controller) datasetManagement(ns *namespace, cmd *nvme.Command) error {
+	nr := int(cmd.Cdw10&0xff) + 1
+	if len(cmd.Data) < nr*16 {
+		return errDataTransfer
+	}
+
+	type lbaRange struct{ slba, nlb uint64 }
+	ranges := make([]lbaRange, nr)
+	for i := range ranges {
+		r := cmd.Data[i*16:]
+		ranges[i] = lbaRange{binary.LittleEndian.Uint64(r[8:]), uint64(binary.LittleEndian.Uint32(r[4:]))}
+		if ranges[i].slba+ranges[i].nlb > ns.cfg.Size || ranges[i].slba+ranges[i].nlb < ranges[i].slba {
+			return errLbaOutOfRange
+		}
+	}
+
+	if cmd.Cdw11&nvme.DSM_ATTR_AD == 0 {
+		return nil
+	}
+
+	for _, r := range ranges {
+		for lba := r.slba; lba < r.slba+r.nlb; lba++ {
+			delete(ns.blocks, lba)
+			delete(ns.uncorr, lba)
+		}
+	}
+
+	return nil
+}
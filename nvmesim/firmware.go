@@ -0,0 +1,119 @@
+package nvmesim
+
+import (
+	"bytes"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+func (c *Controller) firmwareDownload(cmd *nvme.Command) error {
+	size := (int(cmd.Cdw10) + 1) * 4
+	offset := int(cmd.Cdw11) * 4
+
+	if len(cmd.Data) < size {
+		return errDataTransfer
+	}
+
+	// Pieces must be downloaded in order without gaps or overlaps
+	if offset != len(c.fwImage) {
+		return errOverlappingRange
+	}
+
+	c.fwImage = append(c.fwImage, cmd.Data[:size]...)
+
+	return nil
+}
+
+// firmwareCommit implements Firmware Commit. The first 8 bytes of a downloaded image are taken as
+// its firmware revision, trailing NULs removed.
+func (c *Controller) firmwareCommit(cmd *nvme.Command) error {
+	slot := uint8(cmd.Cdw10 & 0x7)
+	action := uint8(cmd.Cdw10 >> 3 & 0x7)
+
+	if slot > c.cfg.FirmwareSlots {
+		return errInvalidFwSlot
+	}
+
+	if slot == 0 {
+		// Prefer a slot other than the running one
+		slot = c.activeSlot%c.cfg.FirmwareSlots + 1
+		if slot == 1 && c.cfg.Slot1ReadOnly && c.cfg.FirmwareSlots > 1 {
+			slot = 2
+		}
+	}
+
+	switch action {
+	case nvme.FW_COMMIT_CA_REPLACE, nvme.FW_COMMIT_CA_REPLACE_ACTIVATE:
+		if len(c.fwImage) == 0 {
+			return errInvalidFwImage
+		}
+		if err := c.replaceFirmware(slot); err != nil {
+			return err
+		}
+	case nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE:
+		// A downloaded image replaces the slot image first
+		if len(c.fwImage) != 0 {
+			if err := c.replaceFirmware(slot); err != nil {
+				return err
+			}
+		}
+	case nvme.FW_COMMIT_CA_ACTIVATE:
+	default:
+		return errInvalidField
+	}
+
+	if c.fwSlots[slot-1] == [8]byte{} {
+		return errInvalidFwImage
+	}
+
+	event := &nvme.PelFirmwareCommitEvent{
+		OldFirmware:  c.fwSlots[c.activeSlot-1],
+		NewFirmware:  c.fwSlots[slot-1],
+		CommitAction: action,
+		Slot:         slot,
+	}
+	if action == nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE && !c.cfg.FirmwareActivateNoReset {
+		event.Sct, event.Sc = errFwNeedsConvReset.SCT, errFwNeedsConvReset.SC
+	}
+	c.addEvent(nvme.PEL_EVENT_FW_COMMIT, event)
+
+	switch action {
+	case nvme.FW_COMMIT_CA_REPLACE_ACTIVATE, nvme.FW_COMMIT_CA_ACTIVATE:
+		c.nextSlot = slot
+	case nvme.FW_COMMIT_CA_ACTIVATE_IMMEDIATE:
+		if !c.cfg.FirmwareActivateNoReset {
+			c.nextSlot = slot
+			return errFwNeedsConvReset
+		}
+		c.activeSlot = slot
+		c.nextSlot = 0
+	}
+
+	return nil
+}
+
+// replaceFirmware stores the downloaded image in slot.
+func (c *Controller) replaceFirmware(slot uint8) error {
+	if slot == 1 && c.cfg.Slot1ReadOnly {
+		return errInvalidFwSlot
+	}
+
+	rev := bytes.TrimRight(c.fwImage[:min(8, len(c.fwImage))], "\x00")
+	copy(c.fwSlots[slot-1][:], padString(string(rev), 8))
+	c.fwImage = nil
+
+	return nil
+}
+
+// Reset simulates a controller reset, which activates the firmware slot selected for the next
+// reset, if any.
+func (c *Controller) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nextSlot != 0 {
+		c.activeSlot = c.nextSlot
+		c.nextSlot = 0
+	}
+	c.fwImage = nil
+}
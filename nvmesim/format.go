@@ -0,0 +1,62 @@
+package nvmesim
+
+import (
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// formatSteps is the number of Identify Namespace reads of a formatted namespace during which FPI
+// reports the format as in progress, if Config.FormatProgress is set. The format itself completes
+// immediately, which lets hosts observe its progress deterministically.
+const formatSteps = 4
+
+// format implements Format NVM. The namespace keeps its capacity in bytes, so its size in logical
+// blocks follows the new block size. All user data is erased regardless of the secure erase
+// setting.
+func (c *Controller) format(cmd *nvme.Command) error {
+	idx := uint8(cmd.Cdw10&0xf) | uint8(cmd.Cdw10>>12&0x3)<<4
+	pi := cmd.Cdw10 >> 5 & 0x7
+	ses := uint8(cmd.Cdw10 >> 9 & 0x7)
+
+	if ses > nvme.FORMAT_SES_CRYPTO {
+		return errInvalidField
+	}
+
+	// Protection information is not simulated
+	if pi != 0 {
+		return errInvalidFormat
+	}
+
+	var targets []*namespace
+	if cmd.Nsid == nvme.NSID_ALL {
+		for _, ns := range c.namespaces {
+			targets = append(targets, ns)
+		}
+	} else if ns, ok := c.namespaces[cmd.Nsid]; ok && ns.attached {
+		targets = append(targets, ns)
+	} else {
+		return errInvalidNamespace
+	}
+
+	for _, ns := range targets {
+		if int(idx) >= len(ns.cfg.LbaFormats) {
+			return errInvalidFormat
+		}
+	}
+
+	c.addEvent(nvme.PEL_EVENT_FORMAT_START, &nvme.PelFormatStartEvent{Nsid: cmd.Nsid, FormatCdw10: cmd.Cdw10})
+
+	for _, ns := range targets {
+		size := ns.sizeBytes()
+		ns.cfg.FormatIndex = idx
+		ns.cfg.Size = size / uint64(ns.blockSize())
+		ns.blocks = make(map[uint64][]byte)
+		ns.uncorr = nil
+		if c.cfg.FormatProgress {
+			ns.fmtSteps = formatSteps
+		}
+	}
+
+	c.addEvent(nvme.PEL_EVENT_FORMAT_COMPLETION, &nvme.PelFormatCompletionEvent{Nsid: cmd.Nsid, SmallestFpi: 100})
+
+	return nil
+}
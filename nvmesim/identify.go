@@ -0,0 +1,212 @@
+package nvmesim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+func (c *Controller) identify(cmd *nvme.Command) error {
+	var resp any
+
+	switch uint8(cmd.Cdw10) {
+	case nvme.IDENTIFY_CNS_CTRL:
+		resp = c.identController()
+	case nvme.IDENTIFY_CNS_NSID, nvme.IDENTIFY_CNS_ALLOC_NS:
+		active := uint8(cmd.Cdw10) == nvme.IDENTIFY_CNS_NSID
+		if cmd.Nsid == nvme.NSID_ALL {
+			resp = c.commonNamespace()
+			break
+		}
+		if cmd.Nsid == 0 || cmd.Nsid > c.cfg.MaxNamespaces {
+			return errInvalidNamespace
+		}
+		// Valid but unallocated or inactive namespaces return a zero filled data structure
+		resp = &nvme.NvmeIdentNamespace{}
+		if ns, ok := c.namespaces[cmd.Nsid]; ok && (ns.attached || !active) {
+			resp = ns.identNamespace(&c.cfg)
+		}
+	case nvme.IDENTIFY_CNS_ACTIVE_NS_LIST:
+		resp = c.nsList(cmd.Nsid, true)
+	case nvme.IDENTIFY_CNS_ALLOC_NS_LIST:
+		resp = c.nsList(cmd.Nsid, false)
+	case nvme.IDENTIFY_CNS_CTRL_LIST_NS:
+		ns, ok := c.namespaces[cmd.Nsid]
+		if !ok {
+			return errInvalidNamespace
+		}
+		resp = ctrlList(ns.attached && cmd.Cdw10>>16 <= uint32(simCntlid))
+	case nvme.IDENTIFY_CNS_CTRL_LIST:
+		resp = ctrlList(cmd.Cdw10>>16 <= uint32(simCntlid))
+	default:
+		return errInvalidField
+	}
+
+	return encode(cmd.Data, resp)
+}
+
+func (c *Controller) identController() *nvme.NvmeIdentController {
+	id := &nvme.NvmeIdentController{
+		VendorID: c.cfg.VendorID,
+		Ssvid:    c.cfg.SubsysVendorID,
+		IEEE:     c.cfg.IEEE,
+		Mdts:     c.cfg.Mdts,
+		Cntlid:   simCntlid,
+		Ver:      0x00020000,
+		Acl:      3,
+		Aerl:     3,
+		Frmw:     c.cfg.FirmwareSlots << 1,
+		Lpa:      nvme.LPA_SMART_PER_NS | nvme.LPA_CMD_EFFECTS | nvme.LPA_EXTENDED_DATA | nvme.LPA_SUPPORTED_LOGS,
+		Elpe:     c.cfg.ErrorLogEntries,
+		Wctemp:   c.cfg.WarningTemp,
+		Cctemp:   c.cfg.CriticalTemp,
+		Sqes:     0x66,
+		Cqes:     0x44,
+		Maxcmd:   64,
+		Edstt:    1,
+		Nn:       c.cfg.MaxNamespaces,
+		Oacs:     nvme.OACS_FORMAT_NVM | nvme.OACS_FIRMWARE | nvme.OACS_SELF_TEST,
+		Oncs:     nvme.ONCS_COMPARE | nvme.ONCS_WRITE_UNCORR | nvme.ONCS_DSM | nvme.ONCS_WRITE_ZEROES | nvme.ONCS_SAVE_SELECT | nvme.ONCS_VERIFY,
+		Fna:      nvme.FNA_CRYPTO_ERASE,
+		Sanicap:  c.cfg.Sanicap,
+	}
+
+	copy(id.SerialNumber[:], padString(c.cfg.SerialNumber, len(id.SerialNumber)))
+	copy(id.ModelNumber[:], padString(c.cfg.ModelNumber, len(id.ModelNumber)))
+	copy(id.Firmware[:], c.fwSlots[c.activeSlot-1][:])
+	copy(id.Subnqn[:], "nqn.2014-08.org.nvmexpress:sim:"+c.cfg.SerialNumber)
+
+	if c.cfg.Slot1ReadOnly {
+		id.Frmw |= nvme.FRMW_SLOT1_RO
+	}
+
+	if c.cfg.Telemetry {
+		id.Lpa |= nvme.LPA_TELEMETRY
+	}
+
+	if c.cfg.PersistentEvents {
+		id.Lpa |= nvme.LPA_PERSISTENT_EVT
+	}
+
+	if c.cfg.FirmwareActivateNoReset {
+		id.Frmw |= nvme.FRMW_NO_RESET
+	}
+
+	if c.cfg.VolatileCache {
+		id.Vwc = nvme.VWC_PRESENT | 0x3<<1 // Flush of all namespaces supported
+	}
+
+	if c.cfg.NsManagement {
+		id.Oacs |= nvme.OACS_NS_MGMT
+	}
+
+	if c.cfg.Reservations {
+		id.Oncs |= nvme.ONCS_RESERVATIONS
+		id.Ctratt |= nvme.CTRATT_HOST_ID_128
+	}
+
+	if c.cfg.CopyFormats != 0 {
+		id.Oncs |= nvme.ONCS_COPY
+		id.Ocfs = c.cfg.CopyFormats
+	}
+
+	id.Tnvmcap = nvme.Uint128From64(c.cfg.Capacity)
+	id.Unvmcap = nvme.Uint128From64(c.cfg.Capacity - c.allocated())
+
+	return id
+}
+
+func (ns *namespace) identNamespace(cfg *Config) *nvme.NvmeIdentNamespace {
+	id := &nvme.NvmeIdentNamespace{
+		Nsze:   ns.cfg.Size,
+		Ncap:   ns.cfg.Size,
+		Nuse:   uint64(len(ns.blocks)),
+		Nlbaf:  uint8(len(ns.cfg.LbaFormats) - 1),
+		Flbas:  ns.cfg.FormatIndex&0xf | (ns.cfg.FormatIndex>>4)<<5,
+		Dlfeat: nvme.DLFEAT_READ_ZEROES | nvme.DLFEAT_WRITE_ZEROES,
+	}
+
+	if ns.cfg.Shared {
+		id.Nmic = nvme.NMIC_SHARED
+	}
+
+	if cfg.FormatProgress {
+		id.Fpi = nvme.FPI_SUPPORTED
+		if ns.fmtSteps > 0 {
+			ns.fmtSteps--
+			id.Fpi |= uint8(ns.fmtSteps * 100 / formatSteps)
+		}
+	}
+
+	id.Nvmcap = nvme.Uint128From64(ns.cfg.Size * uint64(ns.blockSize()))
+
+	if cfg.Reservations {
+		id.Rescap = nvme.RESCAP_PTPL | nvme.RESCAP_WE | nvme.RESCAP_EA | nvme.RESCAP_WE_RO |
+			nvme.RESCAP_EA_RO | nvme.RESCAP_WE_AR | nvme.RESCAP_EA_AR | nvme.RESCAP_IEKEY_1_3
+	}
+
+	if cfg.CopyFormats != 0 {
+		id.Mssrl, id.Mcl, id.Msrc = cfg.CopyMssrl, cfg.CopyMcl, cfg.CopyMsrc
+	}
+
+	copy(id.Lbaf[:], ns.cfg.LbaFormats)
+
+	return id
+}
+
+// commonNamespace returns the capabilities common to all namespaces, which are reported for the
+// broadcast NSID.
+func (c *Controller) commonNamespace() *nvme.NvmeIdentNamespace {
+	id := &nvme.NvmeIdentNamespace{
+		Nlbaf:  uint8(len(c.cfg.LbaFormats) - 1),
+		Dlfeat: nvme.DLFEAT_READ_ZEROES,
+	}
+	copy(id.Lbaf[:], c.cfg.LbaFormats)
+
+	return id
+}
+
+// nsList returns up to 1024 allocated, or only active, NSIDs greater than start in increasing
+// order.
+func (c *Controller) nsList(start uint32, active bool) *[1024]uint32 {
+	var ids []uint32
+	for nsid, ns := range c.namespaces {
+		if nsid > start && (ns.attached || !active) {
+			ids = append(ids, nsid)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var list [1024]uint32
+	copy(list[:], ids)
+
+	return &list
+}
+
+// ctrlList returns a controller list that contains the simulated controller if present is set.
+func ctrlList(present bool) *[2048]uint16 {
+	var list [2048]uint16
+	if present {
+		list[0] = 1
+		list[1] = simCntlid
+	}
+
+	return &list
+}
+
+// encode serializes v in little-endian byte order into buf.
+func encode(buf []byte, v any) error {
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, v); err != nil {
+		return err
+	}
+
+	if len(buf) < b.Len() {
+		return errDataTransfer
+	}
+	copy(buf, b.Bytes())
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package nvmesim
+
+import (
+	"bytes"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// lbaRange decodes the SLBA and NLB fields shared by the NVM commands and validates them against
+// the namespace and, when the command transfers data, the data buffer and MDTS.
+func (c *Controller) lbaRange(ns *namespace, cmd *nvme.Command, xfer bool) (slba, nlb uint64, err error) {
+	slba = uint64(cmd.Cdw11)<<32 | uint64(cmd.Cdw10)
+	nlb = uint64(cmd.Cdw12&0xffff) + 1
+
+	if slba+nlb > ns.cfg.Size || slba+nlb < slba {
+		return 0, 0, errLbaOutOfRange
+	}
+
+	if xfer {
+		n := nlb * uint64(ns.blockSize())
+		if c.cfg.Mdts != 0 && n > 4096<<c.cfg.Mdts {
+			return 0, 0, errInvalidField
+		}
+		if uint64(len(cmd.Data)) < n {
+			return 0, 0, errDataTransfer
+		}
+	}
+
+	return slba, nlb, nil
+}
+
+func (c *Controller) read(ns *namespace, cmd *nvme.Command) error {
+	slba, nlb, err := c.lbaRange(ns, cmd, true)
+	if err != nil {
+		return err
+	}
+
+	if err := c.checkReadable(ns, slba, nlb); err != nil {
+		return err
+	}
+
+	bs := uint64(ns.blockSize())
+	for i := uint64(0); i < nlb; i++ {
+		dst := cmd.Data[i*bs : (i+1)*bs]
+		if blk, ok := ns.blocks[slba+i]; ok {
+			copy(dst, blk)
+		} else {
+			clear(dst)
+		}
+	}
+
+	c.smart.HostReads++
+	c.bytesRead += nlb * bs / 512
+	c.smart.DataUnitsRead += c.bytesRead / 1000
+	c.bytesRead %= 1000
+
+	return nil
+}
+
+func (c *Controller) write(ns *namespace, cmd *nvme.Command) error {
+	slba, nlb, err := c.lbaRange(ns, cmd, true)
+	if err != nil {
+		return err
+	}
+
+	bs := uint64(ns.blockSize())
+	for i := uint64(0); i < nlb; i++ {
+		blk := make([]byte, bs)
+		copy(blk, cmd.Data[i*bs:(i+1)*bs])
+		ns.blocks[slba+i] = blk
+		delete(ns.uncorr, slba+i)
+	}
+
+	c.sanitize.sstat &^= 1 << 8
+	c.smart.HostWrites++
+	c.bytesWrite += nlb * bs / 512
+	c.smart.DataUnitsWritten += c.bytesWrite / 1000
+	c.bytesWrite %= 1000
+
+	return nil
+}
+
+func (c *Controller) compare(ns *namespace, cmd *nvme.Command) error {
+	slba, nlb, err := c.lbaRange(ns, cmd, true)
+	if err != nil {
+		return err
+	}
+
+	if err := c.checkReadable(ns, slba, nlb); err != nil {
+		return err
+	}
+
+	bs := uint64(ns.blockSize())
+	zero := make([]byte, bs)
+	for i := uint64(0); i < nlb; i++ {
+		blk, ok := ns.blocks[slba+i]
+		if !ok {
+			blk = zero
+		}
+		if !bytes.Equal(blk, cmd.Data[i*bs:(i+1)*bs]) {
+			return errCompareFailed
+		}
+	}
+
+	return nil
+}
+
+// writeZeroes drops the blocks when DEAC is set, which DLFEAT reports as reading zeroes, and
+// otherwise stores zeroed blocks so that they count as utilized.
+func (c *Controller) writeZeroes(ns *namespace, cmd *nvme.Command) error {
+	slba, nlb, err := c.lbaRange(ns, cmd, false)
+	if err != nil {
+		return err
+	}
+
+	deac := cmd.Cdw12&(1<<25) != 0
+	for i := uint64(0); i < nlb; i++ {
+		delete(ns.uncorr, slba+i)
+		if deac {
+			delete(ns.blocks, slba+i)
+		} else {
+			ns.blocks[slba+i] = make([]byte, ns.blockSize())
+		}
+	}
+
+	c.sanitize.sstat &^= 1 << 8
+
+	return nil
+}
+
+// checkReadable fails with an Unrecovered Read Error, counted as a media error, if a block of the
+// range was marked by Write Uncorrectable.
+func (c *Controller) checkReadable(ns *namespace, slba, nlb uint64) error {
+	for lba := slba; lba < slba+nlb; lba++ {
+		if ns.uncorr[lba] {
+			c.smart.MediaErrors++
+			return errUnrecoveredRead
+		}
+	}
+
+	return nil
+}
+
+func (c *Controller) writeUncorrectable(ns *namespace, cmd *nvme.Command) error {
+	slba, nlb, err := c.lbaRange(ns, cmd, false)
+	if err != nil {
+		return err
+	}
+
+	if ns.uncorr == nil {
+		ns.uncorr = make(map[uint64]bool)
+	}
+	for lba := slba; lba < slba+nlb; lba++ {
+		ns.uncorr[lba] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+func (c *Controller) getLogPage(cmd *nvme.Command) error {
+	lid := uint8(cmd.Cdw10)
+	numd := (uint64(cmd.Cdw11&0xffff)<<16 | uint64(cmd.Cdw10>>16)) + 1
+	offset := uint64(cmd.Cdw13)<<32 | uint64(cmd.Cdw12)
+
+	var log []byte
+
+	switch lid {
+	case nvme.LOGPAGE_SUPPORTED_LOG_PAGES:
+		log = c.supportedLogs()
+	case nvme.LOGPAGE_CMD_SUPPORTED_EFFECTS:
+		log = c.cmdEffectsLog()
+	case nvme.LOGPAGE_ERROR_INFO:
+		log = c.errorLog()
+	case nvme.LOGPAGE_SMART_HEALTH_INFO:
+		log = c.smartLog()
+	case nvme.LOGPAGE_FIRMWARE_SLOT_INFO:
+		log = c.fwSlotLog()
+	case nvme.LOGPAGE_DEVICE_SELF_TEST:
+		log = c.selfTestLog()
+	case nvme.LOGPAGE_TELEMETRY_HOST, nvme.LOGPAGE_TELEMETRY_CTRL:
+		if !c.cfg.Telemetry {
+			return errInvalidLogPage
+		}
+		log = c.telemetryLog(cmd)
+	case nvme.LOGPAGE_PERSISTENT_EVENT_LOG:
+		if !c.cfg.PersistentEvents {
+			return errInvalidLogPage
+		}
+		var err error
+		if log, err = c.persistentEventLog(cmd); err != nil {
+			return err
+		}
+	case nvme.LOGPAGE_RESERVATION_NOTIFICATION:
+		if !c.cfg.Reservations {
+			return errInvalidLogPage
+		}
+		log = make([]byte, 64) // Always empty, see reservation
+	case nvme.LOGPAGE_SANITIZE_STATUS:
+		if c.cfg.Sanicap == 0 {
+			return errInvalidLogPage
+		}
+		log = c.sanitizeLog()
+	default:
+		return errInvalidLogPage
+	}
+
+	if offset%4 != 0 || offset > uint64(len(log)) {
+		return errInvalidField
+	}
+
+	if numd*4 > uint64(len(cmd.Data)) {
+		return errDataTransfer
+	}
+
+	n := copy(cmd.Data[:numd*4], log[offset:])
+	clear(cmd.Data[n : numd*4])
+
+	return nil
+}
+
+func (c *Controller) errorLog() []byte {
+	log := make([]byte, 64*(int(c.cfg.ErrorLogEntries)+1))
+
+	for i, e := range c.errors {
+		b := log[i*64:]
+		binary.LittleEndian.PutUint64(b[0:], e.count)
+		binary.LittleEndian.PutUint16(b[8:], e.sqid)
+		binary.LittleEndian.PutUint16(b[10:], e.cid)
+		binary.LittleEndian.PutUint16(b[12:], e.status<<1)
+		binary.LittleEndian.PutUint16(b[14:], 0xffff) // Parameter error location not reported
+		binary.LittleEndian.PutUint64(b[16:], e.lba)
+		binary.LittleEndian.PutUint32(b[24:], e.nsid)
+	}
+
+	return log
+}
+
+func (c *Controller) smartLog() []byte {
+	s := &c.smart
+	log := make([]byte, 512)
+
+	log[0] = s.CritWarning
+	binary.LittleEndian.PutUint16(log[1:], s.Temperature)
+	log[3] = s.AvailSpare
+	log[4] = s.SpareThresh
+	log[5] = s.PercentUsed
+	log[6] = s.EnduranceGrpCritWarn
+
+	for i, v := range []uint64{
+		s.DataUnitsRead, s.DataUnitsWritten, s.HostReads, s.HostWrites, s.CtrlBusyTime,
+		s.PowerCycles, s.PowerOnHours, s.UnsafeShutdowns, s.MediaErrors, c.errorCount,
+	} {
+		binary.LittleEndian.PutUint64(log[32+16*i:], v)
+	}
+
+	binary.LittleEndian.PutUint32(log[192:], s.WarningTempTime)
+	binary.LittleEndian.PutUint32(log[196:], s.CritCompTime)
+	for i, t := range s.TempSensor {
+		binary.LittleEndian.PutUint16(log[200+2*i:], t)
+	}
+	for i := range s.ThmTempTransCount {
+		binary.LittleEndian.PutUint32(log[216+4*i:], s.ThmTempTransCount[i])
+		binary.LittleEndian.PutUint32(log[224+4*i:], s.ThmTempTotalTime[i])
+	}
+
+	return log
+}
+
+func (c *Controller) fwSlotLog() []byte {
+	log := make([]byte, 512)
+
+	log[0] = c.activeSlot | c.nextSlot<<4
+	for i := 0; i < int(c.cfg.FirmwareSlots); i++ {
+		copy(log[8+8*i:], c.fwSlots[i][:])
+	}
+
+	return log
+}
+
+func (c *Controller) supportedLogs() []byte {
+	log := make([]byte, 1024)
+
+	lids := []uint8{
+		nvme.LOGPAGE_SUPPORTED_LOG_PAGES, nvme.LOGPAGE_ERROR_INFO, nvme.LOGPAGE_SMART_HEALTH_INFO,
+		nvme.LOGPAGE_FIRMWARE_SLOT_INFO, nvme.LOGPAGE_CMD_SUPPORTED_EFFECTS, nvme.LOGPAGE_DEVICE_SELF_TEST,
+	}
+	if c.cfg.Sanicap != 0 {
+		lids = append(lids, nvme.LOGPAGE_SANITIZE_STATUS)
+	}
+	if c.cfg.Telemetry {
+		lids = append(lids, nvme.LOGPAGE_TELEMETRY_HOST, nvme.LOGPAGE_TELEMETRY_CTRL)
+	}
+	if c.cfg.PersistentEvents {
+		lids = append(lids, nvme.LOGPAGE_PERSISTENT_EVENT_LOG)
+	}
+	if c.cfg.Reservations {
+		lids = append(lids, nvme.LOGPAGE_RESERVATION_NOTIFICATION)
+	}
+
+	for _, lid := range lids {
+		binary.LittleEndian.PutUint32(log[4*int(lid):], nvme.SUPPORTED_LOG_LSUPP)
+	}
+
+	return log
+}
+
+func (c *Controller) cmdEffectsLog() []byte {
+	log := make([]byte, 4096)
+
+	admin := map[uint8]uint32{
+		nvme.NVME_ADMIN_GET_LOG_PAGE:      0,
+		nvme.NVME_ADMIN_IDENTIFY:          0,
+		nvme.NVME_ADMIN_SET_FEATURES:      0,
+		nvme.NVME_ADMIN_GET_FEATURES:      0,
+		nvme.NVME_ADMIN_FIRMWARE_COMMIT:   nvme.CMD_EFFECTS_CCC,
+		nvme.NVME_ADMIN_FIRMWARE_DOWNLOAD: 0,
+		nvme.NVME_ADMIN_DEVICE_SELF_TEST:  0,
+		nvme.NVME_ADMIN_FORMAT_NVM:        nvme.CMD_EFFECTS_LBCC | nvme.CMD_EFFECTS_NCC | uint32(nvme.CMD_EFFECTS_CSE_ALL)<<16,
+	}
+	if c.cfg.NsManagement {
+		admin[nvme.NVME_ADMIN_NS_MANAGEMENT] = nvme.CMD_EFFECTS_NIC
+		admin[nvme.NVME_ADMIN_NS_ATTACHMENT] = nvme.CMD_EFFECTS_NIC
+	}
+	if c.cfg.Sanicap != 0 {
+		admin[nvme.NVME_ADMIN_SANITIZE] = nvme.CMD_EFFECTS_LBCC | uint32(nvme.CMD_EFFECTS_CSE_ALL)<<16
+	}
+
+	io := map[uint8]uint32{
+		nvme.NVME_NVM_CMD_READ:                0,
+		nvme.NVME_NVM_CMD_WRITE:               nvme.CMD_EFFECTS_LBCC,
+		nvme.NVME_NVM_CMD_FLUSH:               0,
+		nvme.NVME_NVM_CMD_WRITE_UNCORRECTABLE: nvme.CMD_EFFECTS_LBCC,
+		nvme.NVME_NVM_CMD_COMPARE:             0,
+		nvme.NVME_NVM_CMD_WRITE_ZEROES:        nvme.CMD_EFFECTS_LBCC,
+		nvme.NVME_NVM_CMD_DATASET_MANAGEMENT:  nvme.CMD_EFFECTS_LBCC,
+		nvme.NVME_NVM_CMD_VERIFY:              0,
+	}
+
+	if c.cfg.CopyFormats != 0 {
+		io[nvme.NVME_NVM_CMD_COPY] = nvme.CMD_EFFECTS_LBCC
+	}
+	if c.cfg.Reservations {
+		io[nvme.NVME_NVM_CMD_RESERVATION_REGISTER] = 0
+		io[nvme.NVME_NVM_CMD_RESERVATION_REPORT] = 0
+		io[nvme.NVME_NVM_CMD_RESERVATION_ACQUIRE] = 0
+		io[nvme.NVME_NVM_CMD_RESERVATION_RELEASE] = 0
+	}
+
+	for op, e := range admin {
+		binary.LittleEndian.PutUint32(log[4*int(op):], nvme.CMD_EFFECTS_CSUPP|e)
+	}
+	for op, e := range io {
+		binary.LittleEndian.PutUint32(log[2048+4*int(op):], nvme.CMD_EFFECTS_CSUPP|e)
+	}
+
+	return log
+}
@@ -0,0 +1,130 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// simCntlid is the controller identifier of the simulated controller.
+const simCntlid uint16 = 1
+
+// allocated returns the NVM capacity allocated to namespaces, in bytes.
+func (c *Controller) allocated() uint64 {
+	var total uint64
+	for _, ns := range c.namespaces {
+		total += ns.sizeBytes()
+	}
+
+	return total
+}
+
+func (c *Controller) nsManagement(cmd *nvme.Command) error {
+	switch uint8(cmd.Cdw10 & 0xf) {
+	case nvme.NS_MGMT_SEL_CREATE:
+		return c.createNamespace(cmd)
+	case nvme.NS_MGMT_SEL_DELETE:
+		if cmd.Nsid == nvme.NSID_ALL {
+			clear(c.namespaces)
+		} else if _, ok := c.namespaces[cmd.Nsid]; ok {
+			delete(c.namespaces, cmd.Nsid)
+		} else {
+			return errInvalidNamespace
+		}
+		c.addEvent(nvme.PEL_EVENT_CHANGE_NS, &nvme.PelChangeNamespaceEvent{NsMgmtCdw10: cmd.Cdw10, Nsid: cmd.Nsid})
+		return nil
+	}
+
+	return errInvalidField
+}
+
+func (c *Controller) createNamespace(cmd *nvme.Command) error {
+	if len(cmd.Data) < 384 {
+		return errDataTransfer
+	}
+
+	nsze := binary.LittleEndian.Uint64(cmd.Data[0:])
+	ncap := binary.LittleEndian.Uint64(cmd.Data[8:])
+	flbas := cmd.Data[26]
+	idx := flbas&0xf | (flbas>>5&0x3)<<4
+
+	if nsze == 0 || ncap > nsze {
+		return errInvalidField
+	}
+
+	if int(idx) >= len(c.cfg.LbaFormats) {
+		return errInvalidFormat
+	}
+
+	nc := NamespaceConfig{
+		Size:        nsze,
+		LbaFormats:  c.cfg.LbaFormats,
+		FormatIndex: idx,
+		Shared:      cmd.Data[30]&nvme.NMIC_SHARED != 0,
+	}
+	ns := &namespace{cfg: nc, blocks: make(map[uint64][]byte)}
+
+	if ns.sizeBytes() > c.cfg.Capacity-c.allocated() {
+		return errInsufficientCap
+	}
+
+	for nsid := uint32(1); nsid <= c.cfg.MaxNamespaces; nsid++ {
+		if _, ok := c.namespaces[nsid]; !ok {
+			ns.cfg.Nsid = nsid
+			c.namespaces[nsid] = ns
+			cmd.Result = nsid
+			c.addEvent(nvme.PEL_EVENT_CHANGE_NS, &nvme.PelChangeNamespaceEvent{
+				NsMgmtCdw10: cmd.Cdw10,
+				Nsze:        nsze,
+				Ncap:        nsze,
+				Flbas:       cmd.Data[26],
+				Nmic:        cmd.Data[30],
+				Nsid:        nsid,
+			})
+			return nil
+		}
+	}
+
+	return errNsidUnavailable
+}
+
+func (c *Controller) nsAttachment(cmd *nvme.Command) error {
+	ns, ok := c.namespaces[cmd.Nsid]
+	if !ok {
+		return errInvalidNamespace
+	}
+
+	if len(cmd.Data) < 4096 {
+		return errDataTransfer
+	}
+
+	found := false
+	n := int(binary.LittleEndian.Uint16(cmd.Data))
+	for i := 0; i < n && i < 2047; i++ {
+		if binary.LittleEndian.Uint16(cmd.Data[2+2*i:]) != simCntlid {
+			return errCtrlListInvalid
+		}
+		found = true
+	}
+
+	if !found {
+		return errCtrlListInvalid
+	}
+
+	switch uint8(cmd.Cdw10 & 0xf) {
+	case nvme.NS_ATTACH_SEL_ATTACH:
+		if ns.attached {
+			return errAlreadyAttached
+		}
+		ns.attached = true
+	case nvme.NS_ATTACH_SEL_DETACH:
+		if !ns.attached {
+			return errNotAttached
+		}
+		ns.attached = false
+	default:
+		return errInvalidField
+	}
+
+	return nil
+}
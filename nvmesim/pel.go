@@ -0,0 +1,83 @@
+package nvmesim
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// pelEventTypes are the persistent event types the simulator logs.
+var pelEventTypes = []uint8{
+	nvme.PEL_EVENT_FW_COMMIT, nvme.PEL_EVENT_CHANGE_NS, nvme.PEL_EVENT_FORMAT_START,
+	nvme.PEL_EVENT_FORMAT_COMPLETION, nvme.PEL_EVENT_SANITIZE_START, nvme.PEL_EVENT_SANITIZE_COMPLETION,
+}
+
+// addEvent appends an event with data v, encoded in little-endian byte order, to the Persistent
+// Event log. Events are time stamped with the current value of the Timestamp feature.
+func (c *Controller) addEvent(t uint8, v any) {
+	if !c.cfg.PersistentEvents {
+		return
+	}
+
+	var data bytes.Buffer
+	binary.Write(&data, binary.LittleEndian, v)
+
+	e := make([]byte, 24, 24+data.Len())
+	e[0] = t
+	e[2] = 24 - 3 // Event header length excludes the first 3 bytes
+	binary.LittleEndian.PutUint16(e[4:], simCntlid)
+	copy(e[6:14], c.features[nvme.FEATURE_TIMESTAMP].curData)
+	binary.LittleEndian.PutUint16(e[22:], uint16(data.Len()))
+
+	c.events = append(c.events, append(e, data.Bytes()...))
+}
+
+// persistentEventLog implements the reporting context of the Persistent Event log: establishing
+// it takes a snapshot of the log, which later reads return until it is released.
+func (c *Controller) persistentEventLog(cmd *nvme.Command) ([]byte, error) {
+	switch uint8(cmd.Cdw10 >> 8 & 0x3) {
+	case nvme.PEL_ACTION_READ:
+		if c.pelContext == nil {
+			return nil, errCmdSeqError
+		}
+	case nvme.PEL_ACTION_ESTABLISH:
+		if c.pelContext != nil {
+			return nil, errCmdSeqError
+		}
+		c.pelContext = c.pelSnapshot()
+	case nvme.PEL_ACTION_RELEASE:
+		c.pelContext = nil
+		return make([]byte, nvme.PEL_HEADER_SIZE), nil
+	default:
+		return nil, errInvalidField
+	}
+
+	return c.pelContext, nil
+}
+
+func (c *Controller) pelSnapshot() []byte {
+	log := make([]byte, nvme.PEL_HEADER_SIZE)
+	for _, e := range c.events {
+		log = append(log, e...)
+	}
+
+	log[0] = nvme.LOGPAGE_PERSISTENT_EVENT_LOG
+	binary.LittleEndian.PutUint32(log[4:], uint32(len(c.events)))
+	binary.LittleEndian.PutUint64(log[8:], uint64(len(log)))
+	binary.LittleEndian.PutUint16(log[18:], nvme.PEL_HEADER_SIZE)
+	copy(log[20:28], c.features[nvme.FEATURE_TIMESTAMP].curData)
+	binary.LittleEndian.PutUint64(log[28:], c.smart.PowerOnHours)
+	binary.LittleEndian.PutUint64(log[44:], c.smart.PowerCycles)
+	binary.LittleEndian.PutUint16(log[52:], c.cfg.VendorID)
+	binary.LittleEndian.PutUint16(log[54:], c.cfg.SubsysVendorID)
+	copy(log[56:76], padString(c.cfg.SerialNumber, 20))
+	copy(log[76:116], padString(c.cfg.ModelNumber, 40))
+	copy(log[116:372], "nqn.2014-08.org.nvmexpress:sim:"+c.cfg.SerialNumber)
+
+	for _, t := range pelEventTypes {
+		log[480+int(t/8)] |= 1 << (t % 8)
+	}
+
+	return log
+}
@@ -0,0 +1,189 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// reservation is the reservation state of a namespace. The simulated subsystem has a single
+// controller and host, so there is at most one registrant and no notifications are ever logged.
+type reservation struct {
+	registered bool
+	key        uint64
+	rtype      uint8 // Type of the reservation held by the host, 0 if none
+	generation uint32
+	ptpl       bool
+}
+
+func (c *Controller) reservation(ns *namespace, cmd *nvme.Command) error {
+	switch cmd.Opcode {
+	case nvme.NVME_NVM_CMD_RESERVATION_REGISTER:
+		return c.reservationRegister(ns, cmd)
+	case nvme.NVME_NVM_CMD_RESERVATION_ACQUIRE:
+		return c.reservationAcquire(ns, cmd)
+	case nvme.NVME_NVM_CMD_RESERVATION_RELEASE:
+		return c.reservationRelease(ns, cmd)
+	}
+
+	return c.reservationReport(ns, cmd)
+}
+
+func (c *Controller) reservationRegister(ns *namespace, cmd *nvme.Command) error {
+	if len(cmd.Data) < 16 {
+		return errDataTransfer
+	}
+
+	r := &ns.resv
+	crkey := binary.LittleEndian.Uint64(cmd.Data[0:])
+	nrkey := binary.LittleEndian.Uint64(cmd.Data[8:])
+	iekey := cmd.Cdw10&(1<<3) != 0
+
+	cptpl := uint8(cmd.Cdw10 >> 30)
+	if cptpl == 1 {
+		return errInvalidField
+	}
+
+	switch uint8(cmd.Cdw10 & 0x7) {
+	case nvme.RESV_REGISTER:
+		if r.registered {
+			if r.key != nrkey {
+				return errReservationConflict
+			}
+		} else {
+			r.registered, r.key = true, nrkey
+			r.generation++
+		}
+	case nvme.RESV_REGISTER_UNREGISTER:
+		if !r.registered || !iekey && crkey != r.key {
+			return errReservationConflict
+		}
+		*r = reservation{generation: r.generation + 1, ptpl: r.ptpl}
+	case nvme.RESV_REGISTER_REPLACE:
+		if !r.registered || !iekey && crkey != r.key {
+			return errReservationConflict
+		}
+		r.key = nrkey
+		r.generation++
+	default:
+		return errInvalidField
+	}
+
+	if cptpl != nvme.RESV_PTPL_NO_CHANGE {
+		r.ptpl = cptpl == nvme.RESV_PTPL_SET
+	}
+
+	return nil
+}
+
+func (c *Controller) reservationAcquire(ns *namespace, cmd *nvme.Command) error {
+	if len(cmd.Data) < 16 {
+		return errDataTransfer
+	}
+
+	r := &ns.resv
+	crkey := binary.LittleEndian.Uint64(cmd.Data[0:])
+	prkey := binary.LittleEndian.Uint64(cmd.Data[8:])
+	rtype := uint8(cmd.Cdw10 >> 8)
+
+	if rtype == nvme.RESV_TYPE_NONE || rtype > nvme.RESV_TYPE_EXCLUSIVE_ACCESS_ALL {
+		return errInvalidField
+	}
+
+	if !r.registered || crkey != r.key {
+		return errReservationConflict
+	}
+
+	switch uint8(cmd.Cdw10 & 0x7) {
+	case nvme.RESV_ACQUIRE:
+		if r.rtype != 0 && r.rtype != rtype {
+			return errReservationConflict
+		}
+	case nvme.RESV_ACQUIRE_PREEMPT, nvme.RESV_ACQUIRE_PREEMPT_AND_ABORT:
+		// The host is the only possible registrant to preempt
+		if prkey != r.key {
+			return errReservationConflict
+		}
+		r.generation++
+	default:
+		return errInvalidField
+	}
+
+	r.rtype = rtype
+
+	return nil
+}
+
+func (c *Controller) reservationRelease(ns *namespace, cmd *nvme.Command) error {
+	if len(cmd.Data) < 8 {
+		return errDataTransfer
+	}
+
+	r := &ns.resv
+	if !r.registered || binary.LittleEndian.Uint64(cmd.Data) != r.key {
+		return errReservationConflict
+	}
+
+	switch uint8(cmd.Cdw10 & 0x7) {
+	case nvme.RESV_RELEASE:
+		if r.rtype == 0 {
+			return nil
+		}
+		if r.rtype != uint8(cmd.Cdw10>>8) {
+			return errInvalidField
+		}
+		r.rtype = 0
+	case nvme.RESV_RELEASE_CLEAR:
+		*r = reservation{generation: r.generation + 1, ptpl: r.ptpl}
+	default:
+		return errInvalidField
+	}
+
+	return nil
+}
+
+func (c *Controller) reservationReport(ns *namespace, cmd *nvme.Command) error {
+	size := (int(cmd.Cdw10) + 1) * 4
+	if len(cmd.Data) < size {
+		return errDataTransfer
+	}
+
+	r := &ns.resv
+	extended := cmd.Cdw11&0x1 != 0
+
+	hdr, entry := 24, 24
+	if extended {
+		hdr, entry = 64, 64
+	}
+
+	report := make([]byte, hdr+entry)
+	binary.LittleEndian.PutUint32(report[0:], r.generation)
+	report[4] = r.rtype
+	if r.ptpl {
+		report[9] = 1
+	}
+
+	if r.registered {
+		binary.LittleEndian.PutUint16(report[5:], 1)
+
+		e := report[hdr:]
+		binary.LittleEndian.PutUint16(e[0:], simCntlid)
+		if r.rtype != 0 {
+			e[2] = 1
+		}
+
+		// The host identifier is left zero, as the Host Identifier feature is not simulated
+		if extended {
+			binary.LittleEndian.PutUint64(e[8:], r.key)
+		} else {
+			binary.LittleEndian.PutUint64(e[16:], r.key)
+		}
+	} else {
+		report = report[:hdr]
+	}
+
+	clear(cmd.Data[:size])
+	copy(cmd.Data[:size], report)
+
+	return nil
+}
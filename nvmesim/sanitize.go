@@ -0,0 +1,166 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// sanitizeSteps is the number of Sanitize Status log reads a sanitize operation takes to complete,
+// which lets hosts observe its progress deterministically.
+const sanitizeSteps = 4
+
+type sanitizeState struct {
+	sstat      uint16
+	step       int
+	scdw10     uint32
+	restricted bool // Set after a failed operation until it is exited or a sanitize succeeds
+}
+
+func (s *sanitizeState) inProgress() bool {
+	return uint8(s.sstat&0x7) == nvme.SANITIZE_STATE_IN_PROGRESS
+}
+
+// sanitizeAllowed reports whether the admin command may run while a sanitize operation is in
+// progress or has failed.
+func sanitizeAllowed(cmd *nvme.Command) bool {
+	switch cmd.Opcode {
+	case nvme.NVME_ADMIN_IDENTIFY, nvme.NVME_ADMIN_GET_LOG_PAGE, nvme.NVME_ADMIN_GET_FEATURES,
+		nvme.NVME_ADMIN_SANITIZE:
+		return true
+	}
+
+	return false
+}
+
+// sanitizeError returns the status that aborts commands not allowed because of a sanitize
+// operation, or nil.
+func (c *Controller) sanitizeError() error {
+	if c.sanitize.inProgress() {
+		return errSanitizeInProgress
+	}
+
+	if c.sanitize.restricted {
+		return errSanitizeFailed
+	}
+
+	return nil
+}
+
+func (c *Controller) startSanitize(cmd *nvme.Command) error {
+	if c.sanitize.inProgress() {
+		return errSanitizeInProgress
+	}
+
+	action := uint8(cmd.Cdw10 & 0x7)
+	noDealloc := cmd.Cdw10&(1<<9) != 0
+
+	switch action {
+	case nvme.SANITIZE_ACTION_EXIT_FAILURE:
+		if !c.sanitize.restricted {
+			return errInvalidField
+		}
+		c.sanitize.restricted = false
+		return nil
+	case nvme.SANITIZE_ACTION_BLOCK_ERASE:
+		if !c.hasSanicap(nvme.SANICAP_BLOCK_ERASE) {
+			return errInvalidField
+		}
+	case nvme.SANITIZE_ACTION_OVERWRITE:
+		if !c.hasSanicap(nvme.SANICAP_OVERWRITE) {
+			return errInvalidField
+		}
+	case nvme.SANITIZE_ACTION_CRYPTO_ERASE:
+		if !c.hasSanicap(nvme.SANICAP_CRYPTO_ERASE) {
+			return errInvalidField
+		}
+	default:
+		return errInvalidField
+	}
+
+	if noDealloc && c.hasSanicap(nvme.SANICAP_NO_DEALLOC_INHB) {
+		return errInvalidField
+	}
+
+	c.sanitize = sanitizeState{
+		sstat:  uint16(nvme.SANITIZE_STATE_IN_PROGRESS),
+		scdw10: cmd.Cdw10,
+	}
+
+	c.addEvent(nvme.PEL_EVENT_SANITIZE_START, &nvme.PelSanitizeStartEvent{
+		Sanicap:       c.cfg.Sanicap,
+		SanitizeCdw10: cmd.Cdw10,
+		SanitizeCdw11: cmd.Cdw11,
+	})
+
+	return nil
+}
+
+func (c *Controller) hasSanicap(mask uint32) bool {
+	return c.cfg.Sanicap&mask == mask
+}
+
+// advanceSanitize moves an in-progress sanitize operation one step closer to completion. User data
+// is erased when it completes; the overwrite pattern is not retained, so reads return zeroes.
+func (c *Controller) advanceSanitize() {
+	s := &c.sanitize
+	if !s.inProgress() {
+		return
+	}
+
+	s.step++
+	if s.step < sanitizeSteps {
+		return
+	}
+
+	if c.cfg.SanitizeFailure {
+		s.sstat = uint16(nvme.SANITIZE_STATE_FAILED)
+		s.restricted = true
+		c.addEvent(nvme.PEL_EVENT_SANITIZE_COMPLETION, &nvme.PelSanitizeCompletionEvent{Sprog: 0xffff, Sstat: s.sstat})
+		return
+	}
+
+	for _, ns := range c.namespaces {
+		ns.blocks = make(map[uint64][]byte)
+		ns.uncorr = nil
+	}
+
+	passes := uint16(0)
+	if uint8(s.scdw10&0x7) == nvme.SANITIZE_ACTION_OVERWRITE {
+		passes = uint16(s.scdw10 >> 4 & 0xf)
+		if passes == 0 {
+			passes = 16
+		}
+	}
+
+	state := nvme.SANITIZE_STATE_COMPLETED
+	if s.scdw10&(1<<9) != 0 {
+		state = nvme.SANITIZE_STATE_COMPLETED_NO_DEALLOC
+	}
+
+	// Global Data Erased is set until user data is written again
+	s.sstat = uint16(state) | (passes&0x1f)<<3 | 1<<8
+	s.restricted = false
+
+	c.addEvent(nvme.PEL_EVENT_SANITIZE_COMPLETION, &nvme.PelSanitizeCompletionEvent{Sprog: 0xffff, Sstat: s.sstat})
+}
+
+func (c *Controller) sanitizeLog() []byte {
+	log := make([]byte, 512)
+
+	sprog := uint16(0xffff)
+	if c.sanitize.inProgress() {
+		sprog = uint16(c.sanitize.step * 65536 / sanitizeSteps)
+	}
+
+	binary.LittleEndian.PutUint16(log[0:], sprog)
+	binary.LittleEndian.PutUint16(log[2:], c.sanitize.sstat)
+	binary.LittleEndian.PutUint32(log[4:], c.sanitize.scdw10)
+	for i := 0; i < 6; i++ {
+		binary.LittleEndian.PutUint32(log[8+4*i:], 0xffffffff)
+	}
+
+	c.advanceSanitize()
+
+	return log
+}
@@ -0,0 +1,118 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// selfTestSteps is the number of Device Self-test log reads a self-test takes to complete, by
+// self-test code.
+var selfTestSteps = map[uint8]int{
+	nvme.SELF_TEST_SHORT:    2,
+	nvme.SELF_TEST_EXTENDED: 5,
+}
+
+type selfTestResult struct {
+	dsts    uint8
+	segment uint8
+	poh     uint64
+	nsid    uint32
+}
+
+type selfTestState struct {
+	code    uint8 // 0 if no self-test is in progress
+	nsid    uint32
+	step    int
+	results []selfTestResult // Newest first, at most 20
+}
+
+func (s *selfTestState) finish(result, segment uint8, poh uint64) {
+	r := selfTestResult{dsts: result | s.code<<4, segment: segment, poh: poh, nsid: s.nsid}
+	s.results = append([]selfTestResult{r}, s.results...)
+	if len(s.results) > 20 {
+		s.results = s.results[:20]
+	}
+	s.code = 0
+}
+
+func (c *Controller) deviceSelfTest(cmd *nvme.Command) error {
+	code := uint8(cmd.Cdw10 & 0xf)
+
+	if code == nvme.SELF_TEST_ABORT {
+		if c.selfTest.code != 0 {
+			c.selfTest.finish(nvme.SELF_TEST_RESULT_ABORTED, 0, c.smart.PowerOnHours)
+		}
+		return nil
+	}
+
+	if _, ok := selfTestSteps[code]; !ok {
+		return errInvalidField
+	}
+
+	if cmd.Nsid != 0 && cmd.Nsid != nvme.NSID_ALL {
+		if ns, ok := c.namespaces[cmd.Nsid]; !ok || !ns.attached {
+			return errInvalidNamespace
+		}
+	}
+
+	if c.selfTest.code != 0 {
+		return errSelfTestInProgress
+	}
+
+	c.selfTest.code = code
+	c.selfTest.nsid = cmd.Nsid
+	c.selfTest.step = 0
+
+	return nil
+}
+
+// advanceSelfTest moves the self-test in progress one step closer to completion. With
+// Config.SelfTestFailure set, it fails in segment 2.
+func (c *Controller) advanceSelfTest() {
+	s := &c.selfTest
+	if s.code == 0 {
+		return
+	}
+
+	s.step++
+	if s.step < selfTestSteps[s.code] {
+		return
+	}
+
+	if c.cfg.SelfTestFailure {
+		s.finish(nvme.SELF_TEST_RESULT_SEGMENT_FAILED, 2, c.smart.PowerOnHours)
+		return
+	}
+
+	s.finish(nvme.SELF_TEST_RESULT_PASSED, 0, c.smart.PowerOnHours)
+}
+
+func (c *Controller) selfTestLog() []byte {
+	s := &c.selfTest
+	log := make([]byte, 564)
+
+	if s.code != 0 {
+		log[0] = s.code
+		log[1] = uint8(s.step * 100 / selfTestSteps[s.code])
+	}
+
+	for i := 0; i < 20; i++ {
+		b := log[4+28*i:]
+		if i >= len(s.results) {
+			b[0] = nvme.SELF_TEST_RESULT_UNUSED
+			continue
+		}
+
+		r := s.results[i]
+		b[0] = r.dsts
+		b[1] = r.segment
+		b[2] = nvme.SELF_TEST_VDI_NSID
+		binary.LittleEndian.PutUint64(b[4:], r.poh)
+		binary.LittleEndian.PutUint32(b[12:], r.nsid)
+	}
+
+	c.advanceSelfTest()
+
+	return log
+}
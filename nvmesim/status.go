@@ -0,0 +1,70 @@
+package nvmesim
+
+import (
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// newStatus returns a completion status for a failed simulated command. The simulator is
+// deterministic, so retrying would not help and DNR is always set.
+func newStatus(sct, sc uint8) *nvme.StatusError {
+	return &nvme.StatusError{SCT: sct, SC: sc, DNR: true}
+}
+
+var (
+	errInvalidOpcode       = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_INVALID_OPCODE)
+	errInvalidField        = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_INVALID_FIELD)
+	errDataTransfer        = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_DATA_XFER_ERROR)
+	errInvalidNamespace    = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_INVALID_NS)
+	errLbaOutOfRange       = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_LBA_RANGE)
+	errReservationConflict = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_RESERVATION_CONFLICT)
+	errUnrecoveredRead     = newStatus(nvme.NVME_SCT_MEDIA, nvme.NVME_SC_UNRECOVERED_READ)
+	errCompareFailed       = newStatus(nvme.NVME_SCT_MEDIA, nvme.NVME_SC_COMPARE_FAILED)
+	errSanitizeFailed      = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_SANITIZE_FAILED)
+	errSanitizeInProgress  = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_SANITIZE_IN_PROGRESS)
+	errInvalidFwSlot       = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_INVALID_FW_SLOT)
+	errInvalidFwImage      = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_INVALID_FW_IMAGE)
+	errOverlappingRange    = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_OVERLAPPING_RANGE)
+	errSelfTestInProgress  = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_SELF_TEST_IN_PROGRESS)
+	errCmdSeqError         = newStatus(nvme.NVME_SCT_GENERIC, nvme.NVME_SC_CMD_SEQ_ERROR)
+	errInvalidLogPage      = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_INVALID_LOG_PAGE)
+	errFwNeedsConvReset    = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_FW_NEEDS_CONV_RESET)
+	errNotSaveable         = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_FEATURE_NOT_SAVEABLE)
+	errCmdSizeLimit        = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_CMD_SIZE_LIMIT)
+	errInvalidFormat       = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_INVALID_FORMAT)
+	errInsufficientCap     = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_NS_INSUFFICIENT_CAP)
+	errNsidUnavailable     = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_NS_ID_UNAVAILABLE)
+	errAlreadyAttached     = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_NS_ALREADY_ATTACHED)
+	errNotAttached         = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_NS_NOT_ATTACHED)
+	errCtrlListInvalid     = newStatus(nvme.NVME_SCT_CMD_SPECIFIC, nvme.NVME_SC_CTRL_LIST_INVALID)
+)
+
+type errorEntry struct {
+	count  uint64
+	sqid   uint16
+	cid    uint16
+	status uint16
+	nsid   uint32
+	lba    uint64
+}
+
+// logError records a failed command in the Error Information log, newest entry first.
+func (c *Controller) logError(sqid uint16, cmd *nvme.Command, st *nvme.StatusError) {
+	c.errorCount++
+
+	e := errorEntry{
+		count:  c.errorCount,
+		sqid:   sqid,
+		cid:    c.cid,
+		status: st.Status(),
+		nsid:   cmd.Nsid,
+	}
+
+	if sqid != 0 {
+		e.lba = uint64(cmd.Cdw11)<<32 | uint64(cmd.Cdw10)
+	}
+
+	c.errors = append([]errorEntry{e}, c.errors...)
+	if len(c.errors) > int(c.cfg.ErrorLogEntries)+1 {
+		c.errors = c.errors[:int(c.cfg.ErrorLogEntries)+1]
+	}
+}
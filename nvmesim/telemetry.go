@@ -0,0 +1,43 @@
+package nvmesim
+
+import (
+	"encoding/binary"
+
+	"github.com/AaronFei/go-nvme/nvme"
+)
+
+// Last blocks of the simulated telemetry data areas 1 to 3
+var telemetryLastBlocks = [3]uint16{4, 20, 52}
+
+// telemetryLog returns a telemetry log page. Data area bytes hold the low byte of their block
+// number XORed with the data generation number, so captures can be checked for completeness.
+func (c *Controller) telemetryLog(cmd *nvme.Command) []byte {
+	lid := uint8(cmd.Cdw10)
+	lsp := uint8(cmd.Cdw10 >> 8 & 0x7f)
+
+	dgn := c.telemetryCtrlDgn
+	if lid == nvme.LOGPAGE_TELEMETRY_HOST {
+		if lsp&nvme.TELEMETRY_LSP_CREATE != 0 {
+			c.telemetryHostDgn++
+		}
+		dgn = c.telemetryHostDgn
+	}
+
+	last := telemetryLastBlocks[2]
+	log := make([]byte, (int(last)+1)*nvme.TELEMETRY_BLOCK_SIZE)
+
+	log[0] = lid
+	copy(log[5:8], c.cfg.IEEE[:])
+	for i, lb := range telemetryLastBlocks {
+		binary.LittleEndian.PutUint16(log[8+2*i:], lb)
+	}
+	binary.LittleEndian.PutUint32(log[16:], uint32(last))
+	log[381] = c.telemetryHostDgn
+	log[383] = c.telemetryCtrlDgn
+
+	for i := nvme.TELEMETRY_BLOCK_SIZE; i < len(log); i++ {
+		log[i] = uint8(i/nvme.TELEMETRY_BLOCK_SIZE) ^ dgn
+	}
+
+	return log
+}